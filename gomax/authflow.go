@@ -0,0 +1,217 @@
+package gomax
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/fresh-milkshake/gomax/internal/constants"
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Описывает шаг пошаговой авторизации AuthFlow — какое действие поток ждёт следующим.
+type AuthStep string
+
+const (
+	// AuthStepStart — поток не начат, ожидается Begin.
+	AuthStepStart AuthStep = "start"
+	// AuthStepCode — код отправлен, ожидается SubmitCode или Resend.
+	AuthStepCode AuthStep = "code"
+	// AuthStepPassword — у аккаунта включена 2FA, ожидается SubmitPassword.
+	AuthStepPassword AuthStep = "password"
+	// AuthStepRegister — номер не зарегистрирован, ожидается Register.
+	AuthStepRegister AuthStep = "register"
+	// AuthStepDone — авторизация завершена, токен сохранён в хранилище сессии.
+	AuthStepDone AuthStep = "done"
+)
+
+// Сериализуемое состояние AuthFlow. Его можно сохранить в JSON между HTTP‑запросами
+// или перезапусками процесса и продолжить поток через ResumeAuthFlow.
+// Содержит временные токены авторизации — храните его как секрет.
+type AuthFlowState struct {
+	Step  AuthStep `json:"step"`
+	Phone string   `json:"phone,omitempty"`
+	// TempToken — временный токен AUTH_REQUEST, обновляется при Resend.
+	TempToken string `json:"tempToken,omitempty"`
+	// RegisterToken — токен регистрации для шага AuthStepRegister.
+	RegisterToken string `json:"registerToken,omitempty"`
+	// Password — запрос облачного пароля для шага AuthStepPassword.
+	Password *types.PasswordChallenge `json:"password,omitempty"`
+}
+
+// Пошаговая авторизация без блокирующих колбэков: каждый шаг — отдельный вызов,
+// между которыми состояние можно сохранить (State) и восстановить (ResumeAuthFlow).
+// Клиенту достаточно соединения, установленного Connect; после AuthStepDone
+// выданный токен сохранён, и Start выполнит вход без повторной авторизации.
+type AuthFlow struct {
+	client *MaxClient
+
+	mu    sync.Mutex
+	state AuthFlowState
+}
+
+// Создаёт новый поток авторизации в состоянии AuthStepStart.
+func (c *MaxClient) NewAuthFlow() *AuthFlow {
+	return &AuthFlow{client: c, state: AuthFlowState{Step: AuthStepStart}}
+}
+
+// Восстанавливает поток авторизации из ранее сохранённого состояния.
+func (c *MaxClient) ResumeAuthFlow(state AuthFlowState) *AuthFlow {
+	if state.Step == "" {
+		state.Step = AuthStepStart
+	}
+	return &AuthFlow{client: c, state: state}
+}
+
+// Возвращает копию текущего состояния потока для сохранения.
+func (f *AuthFlow) State() AuthFlowState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state := f.state
+	if state.Password != nil {
+		challenge := *state.Password
+		state.Password = &challenge
+	}
+	return state
+}
+
+// Возвращает шаг, который поток ждёт следующим.
+func (f *AuthFlow) Step() AuthStep {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state.Step
+}
+
+// Запрашивает код подтверждения для номера и переводит поток в AuthStepCode.
+// Номер должен совпадать с ClientConfig.Phone, иначе возвращается AuthFlowPhoneError.
+// Можно вызвать повторно на любом шаге, чтобы начать авторизацию заново.
+func (f *AuthFlow) Begin(ctx context.Context, phone string) error {
+	if err := f.checkPhone(phone); err != nil {
+		return err
+	}
+	tempToken, err := f.client.RequestCode(ctx, phone, "ru")
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = AuthFlowState{
+		Step:      AuthStepCode,
+		Phone:     phone,
+		TempToken: tempToken,
+	}
+	return nil
+}
+
+// Повторно отправляет код подтверждения и обновляет временный токен.
+func (f *AuthFlow) Resend(ctx context.Context) error {
+	state, err := f.expect(AuthStepCode)
+	if err != nil {
+		return err
+	}
+	tempToken, err := f.client.ResendCode(ctx, state.Phone, "ru")
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state.TempToken = tempToken
+	return nil
+}
+
+// Подтверждает код. В зависимости от ответа сервера поток переходит в AuthStepDone,
+// AuthStepPassword (нужен облачный пароль) или AuthStepRegister (номер не зарегистрирован).
+// При неверном коде шаг не меняется и SubmitCode можно повторить.
+func (f *AuthFlow) SubmitCode(ctx context.Context, code string) (AuthStep, error) {
+	state, err := f.expect(AuthStepCode)
+	if err != nil {
+		return state.Step, err
+	}
+	resp, err := f.client.checkCode(ctx, code, state.TempToken)
+	if err != nil {
+		return state.Step, err
+	}
+
+	next := AuthFlowState{Phone: state.Phone}
+	switch {
+	case resp.token(constants.TokenTypeLogin) != "":
+		if err := f.client.saveToken(ctx, resp.token(constants.TokenTypeLogin)); err != nil {
+			return state.Step, err
+		}
+		next.Step = AuthStepDone
+	case resp.PasswordChallenge != nil:
+		next.Step = AuthStepPassword
+		next.Password = resp.PasswordChallenge
+	case resp.token(constants.TokenTypeRegister) != "":
+		next.Step = AuthStepRegister
+		next.RegisterToken = resp.token(constants.TokenTypeRegister)
+	default:
+		return state.Step, fmt.Errorf("login token not received")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = next
+	return next.Step, nil
+}
+
+// Подтверждает облачный пароль и завершает поток. Подсказку к паролю можно взять
+// из State().Password.Hint. При неверном пароле шаг не меняется.
+func (f *AuthFlow) SubmitPassword(ctx context.Context, password string) error {
+	state, err := f.expect(AuthStepPassword)
+	if err != nil {
+		return err
+	}
+	if err := f.client.CheckPassword(ctx, state.Password.TrackID, password); err != nil {
+		return err
+	}
+	f.finish()
+	return nil
+}
+
+// Завершает регистрацию нового пользователя с указанным именем.
+func (f *AuthFlow) Register(ctx context.Context, firstName string, lastName *string) error {
+	state, err := f.expect(AuthStepRegister)
+	if err != nil {
+		return err
+	}
+	if err := f.client.confirmRegistration(ctx, state.RegisterToken, firstName, lastName); err != nil {
+		return err
+	}
+	f.finish()
+	return nil
+}
+
+// Проверяет текущий шаг и возвращает снимок состояния.
+func (f *AuthFlow) expect(step AuthStep) (AuthFlowState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.state.Step != step {
+		return f.state, &AuthFlowStepError{Expected: step, Actual: f.state.Step}
+	}
+	// Восстановленное состояние могло быть начато другим клиентом.
+	if err := f.checkPhone(f.state.Phone); err != nil {
+		return f.state, err
+	}
+	if step == AuthStepPassword && f.state.Password == nil {
+		return f.state, fmt.Errorf("auth flow state has no password challenge")
+	}
+	return f.state, nil
+}
+
+// Проверяет, что поток авторизует номер самого клиента.
+func (f *AuthFlow) checkPhone(phone string) error {
+	if phone != f.client.cfg.Phone {
+		return &AuthFlowPhoneError{Phone: phone, ClientPhone: f.client.cfg.Phone}
+	}
+	return nil
+}
+
+// Переводит поток в AuthStepDone, стирая временные токены.
+func (f *AuthFlow) finish() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = AuthFlowState{Step: AuthStepDone, Phone: f.state.Phone}
+}
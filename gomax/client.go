@@ -2,6 +2,7 @@ package gomax
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -11,10 +12,10 @@ import (
 	"github.com/fresh-milkshake/gomax/enums"
 	"github.com/fresh-milkshake/gomax/filters"
 	"github.com/fresh-milkshake/gomax/internal/constants"
-	"github.com/fresh-milkshake/gomax/internal/database"
 	"github.com/fresh-milkshake/gomax/internal/payloads"
 	"github.com/fresh-milkshake/gomax/internal/utils"
 	"github.com/fresh-milkshake/gomax/logger"
+	"github.com/fresh-milkshake/gomax/sessionstore"
 	"github.com/fresh-milkshake/gomax/types"
 
 	"github.com/charmbracelet/log"
@@ -28,6 +29,10 @@ type messageHandler struct {
 	filter  *filters.Filter
 }
 
+// Хранилище сессии MaxClient (deviceId, токен, тип устройства).
+// Готовые реализации — в пакете sessionstore.
+type SessionStore = sessionstore.Store
+
 // Задаёт параметры подключения MaxClient к WebSocket API Max и поведение клиента.
 type ClientConfig struct {
 	Phone             string
@@ -47,10 +52,53 @@ type ClientConfig struct {
 	RetryInitialDelay time.Duration
 	RetryMaxDelay     time.Duration
 
+	// PingInterval задаёт период отправки PING для контроля живости соединения.
+	// Если не указан, используется constants.DefaultPingInterval.
+	PingInterval time.Duration
+
+	// MaxMissedPings задаёт число подряд неотвеченных PING,
+	// после которого соединение считается мёртвым и принудительно переподключается.
+	MaxMissedPings int
+
+	// RequestTimeout задаёт время ожидания ответа на запрос к WebSocket API.
+	// Если у контекста вызова есть дедлайн, используется он. По умолчанию constants.DefaultTimeout.
+	RequestTimeout time.Duration
+
+	// ReconnectPolicy задаёт backoff и ограничения переподключения при Reconnect == true.
+	// Незаданные поля заполняются значениями по умолчанию, InitialDelay берётся из ReconnectDelay.
+	ReconnectPolicy ReconnectPolicy
+
+	// EventBuffer задаёт размер буфера каждой подписки Subscribe.
+	// По умолчанию constants.DefaultEventBuffer.
+	EventBuffer int
+
+	// EventOverflow определяет, что делать с событием, если буфер подписки заполнен.
+	// По умолчанию OverflowDropNewest: чтение из сокета никогда не блокируется подписчиком.
+	EventOverflow OverflowPolicy
+
+	// UploadChunkSize задаёт размер части (в байтах) при потоковой загрузке файлов и видео.
+	// В памяти одновременно держится не больше одной части. По умолчанию constants.DefaultUploadChunkSize.
+	UploadChunkSize int
+
+	// SessionStore хранит deviceId, токен и тип устройства между запусками; ключ сессии —
+	// Phone, поэтому одно хранилище (и один WorkDir) можно делить между аккаунтами. По умолчанию sessionstore.Default(WorkDir): SQLite‑база session.db, а в сборках
+	// без cgo — session.json. Переданное хранилище клиент не закрывает в Close.
+	SessionStore SessionStore
+
 	// CodeProvider предоставляет код подтверждения из SMS/звонка.
 	// Если не указан, MaxClient запросит код у пользователя через stdin.
 	CodeProvider func(ctx context.Context) (string, error)
 
+	// PasswordProvider предоставляет облачный пароль, если у аккаунта включена
+	// двухфакторная аутентификация; hint — подсказка, заданная пользователем.
+	// Если не указан, MaxClient запросит пароль у пользователя через stdin.
+	PasswordProvider func(ctx context.Context, hint string) (string, error)
+
+	// QRProvider получает QR‑ссылку, trackId, срок действия и статусы опроса при входе
+	// WEB‑клиента. Готовые варианты: TerminalQRPresenter, PNGFileQRPresenter, SVGQRPresenter.
+	// Если не указан, QR печатается в stdout.
+	QRProvider QRProvider
+
 	// Logger позволяет передать кастомный логгер *log.Logger.
 	// Если не указан, используется логгер по умолчанию (stderr, InfoLevel).
 	// Для записи в файл или другие назначения создайте свой логгер:
@@ -66,7 +114,10 @@ type MaxClient struct {
 	cfg ClientConfig
 
 	logger     *log.Logger
-	db         *database.DB
+	store      SessionStore
+	ownStore   bool
+	session    *sessionstore.Data
+	legacy     *sessionstore.Data
 	httpClient *http.Client
 	deviceID   uuid.UUID
 	token      string
@@ -74,18 +125,45 @@ type MaxClient struct {
 	connMu      sync.RWMutex
 	ws          *websocket.Conn
 	isConnected bool
+	// handshakeOnly выставляется на время reconnect: пока клиент не готов,
+	// sendLoop пишет в сокет только кадры handshake.
+	handshakeOnly bool
 
 	reconnectMu  sync.Mutex
 	reconnecting bool
 
+	connStateMu sync.Mutex
+	connState   ConnectionState
+	connStateCh chan struct{}
+
+	pingMu          sync.Mutex
+	pingRTT         time.Duration
+	missedPings     int
+	heartbeatCancel context.CancelFunc
+
+	done     chan struct{}
+	doneOnce sync.Once
+	termErr  error
+
+	gapMu       sync.Mutex
+	chatCursors map[int64]*chatCursor
+
 	seqMu sync.Mutex
 	seq   int
 
 	pendingMu sync.Mutex
-	pending   map[int]chan map[string]any
+	pending   map[int]*pendingRequest
 
-	incoming chan map[string]any
-	outgoing chan map[string]any
+	presenceMu      sync.RWMutex
+	presence        map[int64]*presenceEntry
+	presenceTracked map[int64]struct{}
+
+	typingMu      sync.Mutex
+	typingKeepers map[int64]map[*typingKeeper]struct{}
+
+	subsMu   sync.RWMutex
+	subs     map[*subscription]struct{}
+	outgoing chan *Frame
 
 	telemetryMu   sync.Mutex
 	sessionID     int
@@ -93,6 +171,7 @@ type MaxClient struct {
 	currentScreen int
 
 	bgWG     sync.WaitGroup
+	bgCtx    context.Context
 	bgCancel context.CancelFunc
 
 	stateMu  sync.RWMutex
@@ -102,14 +181,21 @@ type MaxClient struct {
 	Channels []types.Channel
 
 	onStartHandlers         []func(context.Context)
+	onStateChangeHandlers   []func(context.Context, ConnectionState, ConnectionState)
+	onDisconnectHandlers    []func(context.Context, error)
+	onReconnectHandlers     []func(context.Context)
 	onMessageHandlers       []messageHandler
 	onMessageEditHandlers   []messageHandler
 	onMessageDeleteHandlers []messageHandler
 	onChatUpdate            []func(context.Context, *types.Chat)
+	onContactUpdate         []func(context.Context, *types.Contact)
 	onReactionChange        []func(context.Context, string, int64, *types.ReactionInfo)
+	onTypingHandlers        []func(context.Context, *types.TypingEvent)
+	onReadMarkHandlers      []func(context.Context, *types.ReadMark)
+	onPresenceHandlers      []func(context.Context, UserPresence)
 
 	fileUploadWaitersMu sync.Mutex
-	fileUploadWaiters   map[int64]chan map[string]any
+	fileUploadWaiters   map[int64]chan *Frame
 }
 
 // UserAgent задаёт параметры клиентского окружения,
@@ -153,33 +239,51 @@ func NewMaxClient(cfg ClientConfig) (*MaxClient, error) {
 		cfg.RetryMaxDelay = time.Duration(constants.DefaultRetryMaxDelay * float64(time.Second))
 	}
 	if cfg.ReconnectDelay == 0 {
-		cfg.ReconnectDelay = 5 * time.Second
+		cfg.ReconnectDelay = time.Duration(constants.DefaultReconnectDelay * float64(time.Second))
+	}
+	fillReconnectPolicyDefaults(&cfg.ReconnectPolicy, cfg.ReconnectDelay)
+	if cfg.RequestTimeout == 0 {
+		cfg.RequestTimeout = time.Duration(constants.DefaultTimeout * float64(time.Second))
+	}
+	if cfg.EventBuffer <= 0 {
+		cfg.EventBuffer = constants.DefaultEventBuffer
+	}
+	if cfg.PingInterval == 0 {
+		cfg.PingInterval = time.Duration(constants.DefaultPingInterval * float64(time.Second))
+	}
+	if cfg.MaxMissedPings == 0 {
+		cfg.MaxMissedPings = constants.DefaultMaxMissedPings
 	}
 	if !constants.PhoneRegex.MatchString(cfg.Phone) {
 		return nil, &InvalidPhoneError{Phone: cfg.Phone}
 	}
-	db, err := database.Open(cfg.WorkDir)
-	if err != nil {
-		return nil, err
+	store, ownStore := cfg.SessionStore, false
+	if store == nil {
+		var err error
+		if store, err = sessionstore.Default(cfg.WorkDir); err != nil {
+			return nil, err
+		}
+		ownStore = true
 	}
 
-	devID, err := db.DeviceID()
+	session, devID, legacy, err := loadSession(store, cfg.Phone)
 	if err != nil {
-		_ = db.Close()
+		if ownStore {
+			_ = store.Close()
+		}
 		return nil, err
 	}
 
 	token := cfg.Token
 	if token == "" {
-		if t, err := db.AuthToken(); err == nil {
-			token = t
-		}
+		token = session.Token
+	} else {
+		legacy = nil
 	}
 
-	storedDeviceType, _ := db.DeviceType()
 	if cfg.UserAgent.DeviceType == "" {
-		if token != "" && storedDeviceType != "" {
-			cfg.UserAgent.DeviceType = storedDeviceType
+		if token != "" && session.DeviceType != "" {
+			cfg.UserAgent.DeviceType = session.DeviceType
 		}
 	}
 	fillUserAgentDefaults(&cfg.UserAgent)
@@ -196,28 +300,140 @@ func NewMaxClient(cfg ClientConfig) (*MaxClient, error) {
 	return &MaxClient{
 		cfg:               cfg,
 		logger:            clientLogger,
-		db:                db,
+		store:             store,
+		ownStore:          ownStore,
+		session:           session,
+		legacy:            legacy,
 		httpClient:        httpClient,
 		deviceID:          devID,
 		token:             token,
-		pending:           make(map[int]chan map[string]any),
-		incoming:          make(chan map[string]any, 128),
-		outgoing:          make(chan map[string]any, 128),
-		fileUploadWaiters: make(map[int64]chan map[string]any),
+		pending:           make(map[int]*pendingRequest),
+		subs:              make(map[*subscription]struct{}),
+		typingKeepers:     make(map[int64]map[*typingKeeper]struct{}),
+		presence:          make(map[int64]*presenceEntry),
+		presenceTracked:   make(map[int64]struct{}),
+		outgoing:          make(chan *Frame, 128),
+		fileUploadWaiters: make(map[int64]chan *Frame),
+		connStateCh:       make(chan struct{}),
+		done:              make(chan struct{}),
+		chatCursors:       make(map[int64]*chatCursor),
 		sessionID:         int(time.Now().UnixMilli()),
 		actionID:          1,
 		currentScreen:     150,
 	}, nil
 }
 
-// Подключает клиента к WebSocket API Max, выполняет авторизацию и запускает фоновые циклы обработки.
-func (c *MaxClient) Start(ctx context.Context) error {
-	c.logger.Info("Starting MaxClient", "uri", c.cfg.URI, "phone", c.cfg.Phone)
+// Загружает сессию аккаунта из хранилища. Сессию, сохранённую до появления
+// нескольких аккаунтов, переносит на этот номер, только если других аккаунтов
+// в хранилище нет; перенесённые данные возвращаются в legacy, чтобы Start мог
+// проверить номер и вернуть их обратно. Для новой сессии или невалидного
+// deviceId генерирует новый идентификатор устройства и сразу сохраняет его.
+func loadSession(store SessionStore, phone string) (*sessionstore.Data, uuid.UUID, *sessionstore.Data, error) {
+	ctx := context.Background()
+	session, err := store.Load(ctx, phone)
+	if err != nil {
+		return nil, uuid.UUID{}, nil, err
+	}
+	var legacy *sessionstore.Data
+	if session == nil {
+		if legacy, err = loadLegacySession(ctx, store); err != nil {
+			return nil, uuid.UUID{}, nil, err
+		}
+		if legacy != nil {
+			migrated := *legacy
+			if err := store.Save(ctx, phone, &migrated); err != nil {
+				return nil, uuid.UUID{}, nil, fmt.Errorf("failed to migrate legacy session: %w", err)
+			}
+			if err := store.Delete(ctx, sessionstore.LegacyAccount); err != nil {
+				return nil, uuid.UUID{}, nil, fmt.Errorf("failed to migrate legacy session: %w", err)
+			}
+			session = &migrated
+		}
+	}
+	if session == nil {
+		session = &sessionstore.Data{DeviceType: constants.DeviceTypeWeb}
+	}
+	if devID, err := uuid.Parse(session.DeviceID); err == nil {
+		return session, devID, legacy, nil
+	}
+
+	devID := uuid.New()
+	session.DeviceID = devID.String()
+	if err := store.Save(ctx, phone, session); err != nil {
+		return nil, uuid.UUID{}, nil, fmt.Errorf("failed to save device id: %w", err)
+	}
+	return session, devID, legacy, nil
+}
+
+// Возвращает сессию без номера, если кроме неё в хранилище нет ни одного
+// аккаунта. Иначе неизвестно, какому номеру она принадлежала, и переносить её нельзя.
+func loadLegacySession(ctx context.Context, store SessionStore) (*sessionstore.Data, error) {
+	accounts, err := store.Accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		if account != sessionstore.LegacyAccount {
+			return nil, nil
+		}
+	}
+	return store.Load(ctx, sessionstore.LegacyAccount)
+}
+
+// Проверяет после SYNC, что перенесённая сессия без номера принадлежит номеру
+// клиента. Если нет — возвращает её на прежнее место, сбрасывает токен и
+// сообщает false: Start выполнит вход заново.
+func (c *MaxClient) confirmLegacySession(ctx context.Context) bool {
+	legacy := c.legacy
+	if legacy == nil {
+		return true
+	}
+	c.legacy = nil
+
+	c.stateMu.RLock()
+	me := c.Me
+	c.stateMu.RUnlock()
+	if me == nil || samePhone(me.Phone, c.cfg.Phone) {
+		return true
+	}
+
+	c.logger.Warn("Legacy session belongs to another phone, dropping it", "phone", c.cfg.Phone)
+	if err := c.store.Save(ctx, sessionstore.LegacyAccount, legacy); err != nil {
+		c.logger.Error("Failed to restore legacy session", "err", err)
+	}
+	c.token = ""
+	c.session.Token = ""
+	if err := c.store.Save(ctx, c.cfg.Phone, c.session); err != nil {
+		c.logger.Error("Failed to save session", "err", err)
+	}
+	return false
+}
+
+// Сравнивает номера телефонов только по цифрам, без учёта «+» и разделителей.
+func samePhone(a, b string) bool {
+	digits := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, s)
+	}
+	return digits(a) == digits(b)
+}
+
+// Подключается к WebSocket API Max и выполняет handshake без авторизации —
+// этого достаточно для пошагового входа через AuthFlow. Start вызывает Connect сам,
+// если соединение ещё не установлено. Как и в Start, ctx задаёт время жизни фоновых циклов.
+func (c *MaxClient) Connect(ctx context.Context) error {
+	c.logger.Info("Connecting MaxClient", "uri", c.cfg.URI)
 	ctx, cancel := context.WithCancel(ctx)
 	c.bgCancel = cancel
 
+	c.setState(ctx, StateConnecting)
 	if err := c.dialWebSocket(ctx); err != nil {
 		c.logger.Error("Failed to dial WebSocket", "err", err)
+		c.abortConnect(ctx)
 		return err
 	}
 
@@ -231,96 +447,95 @@ func (c *MaxClient) Start(ctx context.Context) error {
 		c.sendLoop(ctx)
 	}()
 
+	c.setState(ctx, StateHandshaking)
 	if err := c.sessionInit(ctx); err != nil {
 		c.logger.Error("SESSION_INIT failed", "err", err)
-		cancel()
-		c.connMu.Lock()
-		if c.ws != nil {
-			_ = c.ws.Close()
-		}
-		c.connMu.Unlock()
-		c.bgWG.Wait()
+		c.abortConnect(ctx)
 		return err
 	}
 
 	select {
 	case <-time.After(100 * time.Millisecond):
 	case <-ctx.Done():
+		c.abortConnect(ctx)
 		return ctx.Err()
 	}
 
+	c.bgCtx = ctx
+	return nil
+}
+
+// Подключает клиента к WebSocket API Max, выполняет авторизацию и запускает фоновые циклы обработки.
+func (c *MaxClient) Start(ctx context.Context) error {
+	c.logger.Info("Starting MaxClient", "uri", c.cfg.URI, "phone", c.cfg.Phone)
+	if c.bgCtx == nil {
+		if err := c.Connect(ctx); err != nil {
+			return err
+		}
+	}
+	ctx = c.bgCtx
+
 	if c.token == "" {
+		c.setState(ctx, StateAuthenticating)
 		if c.cfg.Registration {
 			c.logger.Info("Starting registration flow")
 			if err := c.Register(ctx, c.cfg.FirstName, c.cfg.LastName); err != nil {
 				c.logger.Error("Registration failed", "err", err)
-				cancel()
-				c.connMu.Lock()
-				if c.ws != nil {
-					_ = c.ws.Close()
-				}
-				c.connMu.Unlock()
-				c.bgWG.Wait()
+				c.abortConnect(ctx)
 				return err
 			}
 		} else {
 			c.logger.Info("Starting login flow")
 			if err := c.Login(ctx); err != nil {
 				c.logger.Error("Login failed", "err", err)
-				cancel()
-				c.connMu.Lock()
-				if c.ws != nil {
-					_ = c.ws.Close()
-				}
-				c.connMu.Unlock()
-				c.bgWG.Wait()
+				c.abortConnect(ctx)
 				return err
 			}
 		}
 	}
 
-	if err := c.sync(ctx); err != nil {
-		if maxErr, ok := err.(*Error); ok && maxErr.Code == "login.token" {
-			c.logger.Info("Token invalid, performing re-login")
-			c.token = ""
-			if c.cfg.Registration {
-				c.logger.Info("Starting registration flow")
-				if err := c.Register(ctx, c.cfg.FirstName, c.cfg.LastName); err != nil {
-					c.logger.Error("Registration failed", "err", err)
-					return err
-				}
-			} else {
-				c.logger.Info("Starting login flow")
-				if err := c.Login(ctx); err != nil {
-					c.logger.Error("Login failed", "err", err)
-					return err
-				}
-			}
-			if err := c.sync(ctx); err != nil {
-				c.logger.Error("SYNC failed after re-login", "err", err)
-				cancel()
-				c.connMu.Lock()
-				if c.ws != nil {
-					_ = c.ws.Close()
-				}
-				c.connMu.Unlock()
-				c.bgWG.Wait()
+	c.setState(ctx, StateSyncing)
+	err := c.sync(ctx)
+	relogin := err == nil && !c.confirmLegacySession(ctx)
+	if maxErr, ok := err.(*Error); ok && maxErr.Code == "login.token" {
+		c.logger.Info("Token invalid, performing re-login")
+		relogin = true
+	}
+	if relogin {
+		c.token = ""
+		c.legacy = nil
+		c.setState(ctx, StateAuthenticating)
+		if c.cfg.Registration {
+			c.logger.Info("Starting registration flow")
+			if err := c.Register(ctx, c.cfg.FirstName, c.cfg.LastName); err != nil {
+				c.logger.Error("Registration failed", "err", err)
+				c.abortConnect(ctx)
 				return err
 			}
 		} else {
-			c.logger.Error("SYNC failed", "err", err)
-			cancel()
-			c.connMu.Lock()
-			if c.ws != nil {
-				_ = c.ws.Close()
+			c.logger.Info("Starting login flow")
+			if err := c.Login(ctx); err != nil {
+				c.logger.Error("Login failed", "err", err)
+				c.abortConnect(ctx)
+				return err
 			}
-			c.connMu.Unlock()
-			c.bgWG.Wait()
+		}
+		c.setState(ctx, StateSyncing)
+		if err := c.sync(ctx); err != nil {
+			c.logger.Error("SYNC failed after re-login", "err", err)
+			c.abortConnect(ctx)
 			return err
 		}
+	} else if err != nil {
+		c.logger.Error("SYNC failed", "err", err)
+		c.abortConnect(ctx)
+		return err
 	}
 
 	c.logger.Info("Client started successfully")
+	c.setState(ctx, StateReady)
+
+	c.startHeartbeat(ctx)
 
 	if c.cfg.SendFakeTelemetry {
 		c.bgWG.Add(1)
@@ -334,6 +549,25 @@ func (c *MaxClient) Start(ctx context.Context) error {
 	return nil
 }
 
+// Прерывает неудачный Connect или Start: останавливает фоновые циклы, закрывает соединение
+// и сбрасывает bgCtx, чтобы повторный Start заново установил соединение.
+func (c *MaxClient) abortConnect(ctx context.Context) {
+	if c.bgCancel != nil {
+		c.bgCancel()
+	}
+	c.connMu.Lock()
+	if c.ws != nil {
+		_ = c.ws.Close()
+	}
+	c.ws = nil
+	c.isConnected = false
+	c.handshakeOnly = false
+	c.connMu.Unlock()
+	c.bgWG.Wait()
+	c.bgCtx, c.bgCancel = nil, nil
+	c.setState(ctx, StateClosed)
+}
+
 // Корректно завершает работу MaxClient: останавливает фоновые goroutines,
 // закрывает WebSocket-соединение и базу данных сессии.
 func (c *MaxClient) Close() error {
@@ -349,13 +583,10 @@ func (c *MaxClient) Close() error {
 	c.isConnected = false
 	c.connMu.Unlock()
 	c.bgWG.Wait()
+	c.finish(&ClientClosedError{})
+	c.setState(context.Background(), StateClosed)
 
-	c.pendingMu.Lock()
-	for seq, ch := range c.pending {
-		delete(c.pending, seq)
-		close(ch)
-	}
-	c.pendingMu.Unlock()
+	c.abortPending(&ClientClosedError{})
 
 	c.fileUploadWaitersMu.Lock()
 	for fileID, ch := range c.fileUploadWaiters {
@@ -364,9 +595,9 @@ func (c *MaxClient) Close() error {
 	}
 	c.fileUploadWaitersMu.Unlock()
 
-	if c.db != nil {
-		if err := c.db.Close(); err != nil {
-			c.logger.Error("Failed to close database", "err", err)
+	if c.ownStore {
+		if err := c.store.Close(); err != nil {
+			c.logger.Error("Failed to close session store", "err", err)
 			return err
 		}
 	}
@@ -445,17 +676,16 @@ func (c *MaxClient) resetTelemetrySession() {
 }
 
 // Отправляет сообщение без ожидания ответа (fire-and-forget).
-func (c *MaxClient) sendAsync(ctx context.Context, opcode enums.Opcode, payload map[string]any) {
-	msg := map[string]any{
-		"ver":     constants.ProtocolVersion,
-		"cmd":     constants.ProtocolCommand,
-		"seq":     c.nextSeq(),
-		"opcode":  int(opcode),
-		"payload": payload,
+func (c *MaxClient) sendAsync(ctx context.Context, opcode enums.Opcode, payload any) {
+	frame, err := newFrame(c.nextSeq(), opcode, payload)
+	if err != nil {
+		c.logger.Warn("Failed to encode payload", "opcode", opcode, "err", err)
+		return
 	}
+	frame.handshake = isHandshake(ctx)
 
 	select {
-	case c.outgoing <- msg:
+	case c.outgoing <- frame:
 	case <-ctx.Done():
 	}
 }
@@ -515,7 +745,7 @@ func (c *MaxClient) sync(ctx context.Context) error {
 		return err
 	}
 
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeLogin, map[string]any{
+	err = c.request(ctx, enums.OpcodeLogin, map[string]any{
 		"interactive":  true,
 		"token":        c.token,
 		"chatsSync":    0,
@@ -524,14 +754,9 @@ func (c *MaxClient) sync(ctx context.Context) error {
 		"draftsSync":   0,
 		"chatsCount":   40,
 		"userAgent":    uaMap,
-	})
+	}, nil)
 	if err != nil {
-		c.logger.Error("SYNC failed", "err", err)
-		return err
-	}
-
-	if err := HandleError(resp); err != nil {
-		c.logger.Warn("SYNC response contains error", "err", err)
+		c.logger.Warn("SYNC failed", "err", err)
 		return err
 	}
 
@@ -539,21 +764,10 @@ func (c *MaxClient) sync(ctx context.Context) error {
 	return nil
 }
 
+// Заново устанавливает соединение: dial, SESSION_INIT, при необходимости логин и SYNC.
+// Ответы читает работающий recvLoop, который подхватывает новый сокет.
+// До перехода в StateReady в новый сокет уходят только кадры самого reconnect (см. sendLoop).
 func (c *MaxClient) reconnect(ctx context.Context) error {
-	c.reconnectMu.Lock()
-	if c.reconnecting {
-		c.reconnectMu.Unlock()
-		return fmt.Errorf("reconnection already in progress")
-	}
-	c.reconnecting = true
-	c.reconnectMu.Unlock()
-
-	defer func() {
-		c.reconnectMu.Lock()
-		c.reconnecting = false
-		c.reconnectMu.Unlock()
-	}()
-
 	c.logger.Info("Starting reconnection")
 
 	c.connMu.Lock()
@@ -562,14 +776,19 @@ func (c *MaxClient) reconnect(ctx context.Context) error {
 		c.ws = nil
 	}
 	c.isConnected = false
+	c.handshakeOnly = true
 	c.connMu.Unlock()
 
+	hctx := withHandshake(ctx)
+
+	c.setState(ctx, StateConnecting)
 	if err := c.dialWebSocket(ctx); err != nil {
 		c.logger.Error("Failed to dial WebSocket during reconnection", "err", err)
 		return fmt.Errorf("dial failed: %w", err)
 	}
 
-	if err := c.sessionInit(ctx); err != nil {
+	c.setState(ctx, StateHandshaking)
+	if err := c.sessionInit(hctx); err != nil {
 		c.logger.Error("SESSION_INIT failed during reconnection", "err", err)
 		c.connMu.Lock()
 		if c.ws != nil {
@@ -582,15 +801,16 @@ func (c *MaxClient) reconnect(ctx context.Context) error {
 
 	c.resetTelemetrySession()
 	if c.cfg.SendFakeTelemetry {
-		if err := c.sendColdStart(ctx); err != nil {
+		if err := c.sendColdStart(hctx); err != nil {
 			c.logger.Debug("Cold start telemetry failed after reconnect", "err", err)
 		}
 	}
 
 	if c.token == "" {
+		c.setState(ctx, StateAuthenticating)
 		if c.cfg.Registration {
 			c.logger.Info("Performing registration during reconnection")
-			if err := c.Register(ctx, c.cfg.FirstName, c.cfg.LastName); err != nil {
+			if err := c.Register(hctx, c.cfg.FirstName, c.cfg.LastName); err != nil {
 				c.logger.Error("Registration failed during reconnection", "err", err)
 				c.connMu.Lock()
 				if c.ws != nil {
@@ -602,7 +822,7 @@ func (c *MaxClient) reconnect(ctx context.Context) error {
 			}
 		} else {
 			c.logger.Info("Performing login during reconnection")
-			if err := c.Login(ctx); err != nil {
+			if err := c.Login(hctx); err != nil {
 				c.logger.Error("Login failed during reconnection", "err", err)
 				c.connMu.Lock()
 				if c.ws != nil {
@@ -615,13 +835,15 @@ func (c *MaxClient) reconnect(ctx context.Context) error {
 		}
 	}
 
-	if err := c.sync(ctx); err != nil {
+	c.setState(ctx, StateSyncing)
+	if err := c.sync(hctx); err != nil {
 		if maxErr, ok := err.(*Error); ok && maxErr.Code == "login.token" {
 			c.logger.Info("Token invalid during reconnection, performing re-login")
 			c.token = ""
+			c.setState(ctx, StateAuthenticating)
 			if c.cfg.Registration {
 				c.logger.Info("Starting registration flow during reconnection")
-				if err := c.Register(ctx, c.cfg.FirstName, c.cfg.LastName); err != nil {
+				if err := c.Register(hctx, c.cfg.FirstName, c.cfg.LastName); err != nil {
 					c.logger.Error("Registration failed during reconnection", "err", err)
 					c.connMu.Lock()
 					if c.ws != nil {
@@ -633,7 +855,7 @@ func (c *MaxClient) reconnect(ctx context.Context) error {
 				}
 			} else {
 				c.logger.Info("Starting login flow during reconnection")
-				if err := c.Login(ctx); err != nil {
+				if err := c.Login(hctx); err != nil {
 					c.logger.Error("Login failed during reconnection", "err", err)
 					c.connMu.Lock()
 					if c.ws != nil {
@@ -644,7 +866,8 @@ func (c *MaxClient) reconnect(ctx context.Context) error {
 					return fmt.Errorf("login failed: %w", err)
 				}
 			}
-			if err := c.sync(ctx); err != nil {
+			c.setState(ctx, StateSyncing)
+			if err := c.sync(hctx); err != nil {
 				c.logger.Error("SYNC failed after re-login during reconnection", "err", err)
 				c.connMu.Lock()
 				if c.ws != nil {
@@ -666,46 +889,43 @@ func (c *MaxClient) reconnect(ctx context.Context) error {
 		}
 	}
 
+	c.connMu.Lock()
+	c.handshakeOnly = false
+	c.connMu.Unlock()
+	c.setState(ctx, StateReady)
+	c.startHeartbeat(ctx)
+	c.replayPending(ctx)
+
 	c.logger.Info("Reconnection completed successfully")
 	return nil
 }
 
 // Отправляет запрос с заданным opcode и payload
 // и ожидает подтверждающий ответ по тому же seq без разбора содержимого.
-func (c *MaxClient) sendAndWait(ctx context.Context, opcode enums.Opcode, payload map[string]any) error {
-	seq := c.nextSeq()
-	respCh := make(chan map[string]any, 1)
-
-	c.pendingMu.Lock()
-	c.pending[seq] = respCh
-	c.pendingMu.Unlock()
-
-	defer func() {
-		c.pendingMu.Lock()
-		delete(c.pending, seq)
-		c.pendingMu.Unlock()
-	}()
+func (c *MaxClient) sendAndWait(ctx context.Context, opcode enums.Opcode, payload any) error {
+	_, err := c.roundTrip(ctx, opcode, payload, false)
+	return err
+}
 
-	msg := map[string]any{
-		"ver":     constants.ProtocolVersion,
-		"cmd":     constants.ProtocolCommand,
-		"seq":     seq,
-		"opcode":  int(opcode),
-		"payload": payload,
-	}
+// Ожидает смены состояния соединения (например, появления нового сокета после reconnect).
+// Возвращает false, если контекст отменён.
+func (c *MaxClient) waitConnChange(ctx context.Context) bool {
+	c.connStateMu.Lock()
+	changed := c.connStateCh
+	c.connStateMu.Unlock()
 
-	select {
-	case c.outgoing <- msg:
-	case <-ctx.Done():
-		return ctx.Err()
+	c.connMu.RLock()
+	hasConn := c.ws != nil
+	c.connMu.RUnlock()
+	if hasConn {
+		return true
 	}
 
 	select {
-	case <-respCh:
-
-		return nil
+	case <-changed:
+		return true
 	case <-ctx.Done():
-		return ctx.Err()
+		return false
 	}
 }
 
@@ -717,7 +937,10 @@ func (c *MaxClient) recvLoop(ctx context.Context) {
 		ws := c.ws
 		c.connMu.RUnlock()
 		if ws == nil {
-			return
+			if !c.waitConnChange(ctx) {
+				return
+			}
+			continue
 		}
 
 		_, data, err := ws.ReadMessage()
@@ -727,145 +950,136 @@ func (c *MaxClient) recvLoop(ctx context.Context) {
 				strings.Contains(errStr, "use of closed network connection") ||
 				strings.Contains(errStr, "connection reset by peer")
 
-			c.connMu.Lock()
-			c.isConnected = false
-			c.connMu.Unlock()
-
 			if isNormalClose {
 				c.logger.Debug("WebSocket connection closed", "err", err)
 			} else {
 				c.logger.Warn("WebSocket read error", "err", err)
 			}
 
-			if !c.cfg.Reconnect {
+			if ctx.Err() != nil {
 				return
 			}
 
-			c.logger.Info("Attempting to reconnect", "delay", c.cfg.ReconnectDelay)
-			for {
-				select {
-				case <-ctx.Done():
-					c.logger.Debug("Reconnection cancelled due to context cancellation")
-					return
-				case <-time.After(c.cfg.ReconnectDelay):
-				}
-
-				if err := c.reconnect(ctx); err != nil {
-					c.logger.Warn("Reconnection attempt failed", "err", err, "retrying in", c.cfg.ReconnectDelay)
-					continue
-				}
-
-				c.logger.Info("Reconnection successful, resuming recvLoop")
-				c.connMu.RLock()
-				ws = c.ws
-				c.connMu.RUnlock()
-				if ws == nil {
-					return
-				}
-				break
+			// Сокет мог быть уже заменён reconnect‑ом, тогда ошибка относится к старому соединению.
+			c.connMu.Lock()
+			stale := c.ws != ws
+			if !stale {
+				_ = ws.Close()
+				c.ws = nil
+				c.isConnected = false
+			}
+			c.connMu.Unlock()
+			if stale {
+				continue
 			}
-			continue
-		}
-		var msg map[string]any
-		if err := utils.JSONUnmarshal(data, &msg); err != nil {
-			c.logger.Warn("Failed to unmarshal message", "err", err)
-			continue
-		}
-
-		opcodeVal, _ := msg["opcode"].(float64)
-		opcode := enums.Opcode(opcodeVal)
 
-		if opcode == enums.OpcodeSync || opcode == enums.OpcodeLogin {
-			c.handleSyncResponse(msg)
-		}
+			c.failPending(err, c.cfg.Reconnect)
 
-		if seqVal, ok := msg["seq"].(float64); ok {
-			seq := int(seqVal)
-			c.pendingMu.Lock()
-			ch, ok := c.pending[seq]
-			if ok {
-				delete(c.pending, seq)
+			if !c.cfg.Reconnect {
+				c.finish(&NetworkError{Err: err})
+				c.setState(ctx, StateClosed)
+				c.fireDisconnect(ctx, err)
+				return
 			}
-			c.pendingMu.Unlock()
-			if ok {
 
-				select {
-				case ch <- msg:
-				default:
-					c.logger.Warn("Pending channel full or receiver not waiting", "seq", seq)
-				}
+			c.reconnectMu.Lock()
+			alreadyReconnecting := c.reconnecting
+			c.reconnecting = true
+			c.reconnectMu.Unlock()
+			if alreadyReconnecting {
 				continue
 			}
-		}
 
-		if opcode == enums.OpcodeNotifAttach {
-			c.handleFileUploadNotification(msg)
-		}
+			c.setState(ctx, StateReconnecting)
+			c.fireDisconnect(ctx, err)
 
-		if opcode == enums.OpcodeNotifMessage {
-			c.handleMessageNotification(ctx, msg)
+			c.bgWG.Add(1)
+			go c.reconnectLoop(ctx, err)
+			continue
+		}
+		frame := &Frame{}
+		if err := utils.JSONUnmarshal(data, frame); err != nil {
+			c.logger.Warn("Failed to unmarshal frame", "err", err)
+			continue
 		}
 
-		if opcode == enums.OpcodeNotifMsgReactionsChanged {
-			c.handleReactionChange(ctx, msg)
+		if frame.Opcode == enums.OpcodeSync || frame.Opcode == enums.OpcodeLogin {
+			c.handleSyncResponse(frame)
 		}
 
-		if opcode == enums.OpcodeNotifChat {
-			c.handleChatUpdate(ctx, msg)
+		if c.resolvePending(frame.Seq, frame) {
+			continue
 		}
 
-		select {
-		case c.incoming <- msg:
-		case <-ctx.Done():
-			return
+		switch frame.Opcode {
+		case enums.OpcodeNotifAttach:
+			c.handleFileUploadNotification(frame)
+		case enums.OpcodeNotifMessage:
+			c.handleMessageNotification(ctx, frame)
+		case enums.OpcodeNotifMsgReactionsChanged:
+			c.handleReactionChange(ctx, frame)
+		case enums.OpcodeNotifChat:
+			c.handleChatUpdate(ctx, frame)
+		case enums.OpcodeNotifTyping:
+			c.handleTyping(ctx, frame)
+		case enums.OpcodeNotifMark:
+			c.handleReadMark(ctx, frame)
+		case enums.OpcodeNotifPresence:
+			c.handlePresence(ctx, frame)
+		case enums.OpcodeNotifContact:
+			c.handleContactUpdate(ctx, frame)
 		}
+
+		c.publish(ctx, frame)
 	}
 }
 
 // Завершает ожидание загрузки файла или видео по полученному NOTIF_ATTACH.
-func (c *MaxClient) handleFileUploadNotification(msg map[string]any) {
-	payload, _ := msg["payload"].(map[string]any)
-	fileID, _ := payload["fileId"].(float64)
-	videoID, _ := payload["videoId"].(float64)
+func (c *MaxClient) handleFileUploadNotification(frame *Frame) {
+	var payload struct {
+		FileID  int64 `json:"fileId"`
+		VideoID int64 `json:"videoId"`
+	}
+	if err := frame.Decode(&payload); err != nil {
+		c.logger.Warn("Failed to decode NOTIF_ATTACH", "err", err)
+		return
+	}
 
 	c.fileUploadWaitersMu.Lock()
 	defer c.fileUploadWaitersMu.Unlock()
 
-	if fileID > 0 {
-		ch, ok := c.fileUploadWaiters[int64(fileID)]
-		if ok {
-			delete(c.fileUploadWaiters, int64(fileID))
-
-			select {
-			case ch <- msg:
-			default:
-
-			}
+	for _, id := range []int64{payload.FileID, payload.VideoID} {
+		if id <= 0 {
+			continue
 		}
-	}
-	if videoID > 0 {
-		ch, ok := c.fileUploadWaiters[int64(videoID)]
-		if ok {
-			delete(c.fileUploadWaiters, int64(videoID))
-
-			select {
-			case ch <- msg:
-			default:
+		ch, ok := c.fileUploadWaiters[id]
+		if !ok {
+			continue
+		}
+		delete(c.fileUploadWaiters, id)
 
-			}
+		select {
+		case ch <- frame:
+		default:
 		}
 	}
 }
 
 // Обрабатывает NOTIF_MESSAGE, обновляет сообщения
 // и вызывает зарегистрированные обработчики новых, отредактированных и удалённых сообщений.
-func (c *MaxClient) handleMessageNotification(ctx context.Context, msg map[string]any) {
-	payload, _ := msg["payload"].(map[string]any)
+func (c *MaxClient) handleMessageNotification(ctx context.Context, frame *Frame) {
 	message := &types.Message{}
-	if err := utils.FromMap(payload, message); err != nil {
+	if err := frame.Decode(message); err != nil {
+		c.logger.Warn("Failed to decode NOTIF_MESSAGE", "err", err)
 		return
 	}
 
+	if message.Status == nil && message.ChatID != nil && !c.trackMessage(*message.ChatID, message) {
+		c.logger.Debug("Skipping already delivered message", "chatID", *message.ChatID, "messageID", message.ID)
+		return
+	}
+	c.countUnread(message)
+
 	if message.Status != nil {
 		if *message.Status == enums.MessageStatusEdited {
 			for _, h := range c.onMessageEditHandlers {
@@ -891,44 +1105,42 @@ func (c *MaxClient) handleMessageNotification(ctx context.Context, msg map[strin
 
 // Обрабатывает NOTIF_MSG_REACTIONS_CHANGED
 // и собирает агрегированную информацию о реакциях к сообщению.
-func (c *MaxClient) handleReactionChange(ctx context.Context, msg map[string]any) {
-	payload, _ := msg["payload"].(map[string]any)
-	chatID, _ := payload["chatId"].(float64)
-	messageID, _ := payload["messageId"].(string)
-
-	totalCount, _ := payload["totalCount"].(float64)
-	yourReaction, _ := payload["yourReaction"].(string)
-	countersData, _ := payload["counters"].([]interface{})
-
-	counters := make([]types.ReactionCounter, 0, len(countersData))
-	for _, cData := range countersData {
-		cMap, _ := cData.(map[string]any)
-		counter := types.ReactionCounter{}
-		if err := utils.FromMap(cMap, &counter); err == nil {
-			counters = append(counters, counter)
-		}
+func (c *MaxClient) handleReactionChange(ctx context.Context, frame *Frame) {
+	var payload struct {
+		ChatID    int64  `json:"chatId"`
+		MessageID string `json:"messageId"`
+		types.ReactionInfo
+	}
+	if err := frame.Decode(&payload); err != nil {
+		c.logger.Warn("Failed to decode NOTIF_MSG_REACTIONS_CHANGED", "err", err)
+		return
 	}
 
-	reactionInfo := &types.ReactionInfo{
-		TotalCount:   int(totalCount),
-		YourReaction: &yourReaction,
-		Counters:     counters,
+	reactionInfo := &payload.ReactionInfo
+	if reactionInfo.YourReaction == nil {
+		empty := ""
+		reactionInfo.YourReaction = &empty
+	}
+	if reactionInfo.Counters == nil {
+		reactionInfo.Counters = []types.ReactionCounter{}
 	}
 
 	for _, handler := range c.onReactionChange {
-		go handler(ctx, messageID, int64(chatID), reactionInfo)
+		go handler(ctx, payload.MessageID, payload.ChatID, reactionInfo)
 	}
 }
 
 // Обрабатывает NOTIF_CHAT, обновляет кэш чатов
 // и вызывает соответствующие обработчики.
-func (c *MaxClient) handleChatUpdate(ctx context.Context, msg map[string]any) {
-	payload, _ := msg["payload"].(map[string]any)
-	chatData, _ := payload["chat"].(map[string]any)
-	chat := &types.Chat{}
-	if err := utils.FromMap(chatData, chat); err != nil {
+func (c *MaxClient) handleChatUpdate(ctx context.Context, frame *Frame) {
+	var payload struct {
+		Chat *types.Chat `json:"chat"`
+	}
+	if err := frame.Decode(&payload); err != nil || payload.Chat == nil {
+		c.logger.Warn("Failed to decode NOTIF_CHAT", "err", err)
 		return
 	}
+	chat := payload.Chat
 
 	c.updateChatCache(chat)
 
@@ -937,45 +1149,69 @@ func (c *MaxClient) handleChatUpdate(ctx context.Context, msg map[string]any) {
 	}
 }
 
+// Обрабатывает NOTIF_CONTACT и вызывает обработчики OnContactUpdate.
+func (c *MaxClient) handleContactUpdate(ctx context.Context, frame *Frame) {
+	var payload struct {
+		Contact *types.Contact `json:"contact"`
+	}
+	if err := frame.Decode(&payload); err != nil || payload.Contact == nil {
+		c.logger.Warn("Failed to decode NOTIF_CONTACT", "err", err)
+		return
+	}
+
+	for _, handler := range c.onContactUpdate {
+		go handler(ctx, payload.Contact)
+	}
+}
+
+// Описывает payload ответов SYNC/LOGIN. Чаты остаются сырыми,
+// так как их итоговый тип (чат, диалог, канал) зависит от поля type.
+type syncResponsePayload struct {
+	Chats   []json.RawMessage `json:"chats"`
+	Profile *struct {
+		Contact *types.Me `json:"contact"`
+	} `json:"profile"`
+	Error string `json:"error"`
+}
+
 // Обрабатывает ответы SYNC/LOGIN и обновляет кэш чатов, диалогов и текущий профиль.
-func (c *MaxClient) handleSyncResponse(msg map[string]any) {
-	c.logger.Debug("Processing SYNC response", "opcode", msg["opcode"], "seq", msg["seq"])
-	payload, _ := msg["payload"].(map[string]any)
+func (c *MaxClient) handleSyncResponse(frame *Frame) {
+	c.logger.Debug("Processing SYNC response", "opcode", frame.Opcode, "seq", frame.Seq)
+	var payload syncResponsePayload
+	if err := frame.Decode(&payload); err != nil {
+		c.logger.Warn("Failed to decode SYNC response", "err", err)
+		return
+	}
 
 	c.stateMu.Lock()
 	defer c.stateMu.Unlock()
 
-	seqVal, hasSeq := msg["seq"].(float64)
-	if hasSeq && seqVal > 0 {
+	if frame.Seq > 0 {
 		c.Chats = make([]types.Chat, 0)
 		c.Dialogs = make([]types.Dialog, 0)
 		c.Channels = make([]types.Channel, 0)
 		c.logger.Debug("Cleared cache before SYNC response processing")
 	}
 
-	chatsData, _ := payload["chats"].([]interface{})
-	hasError := payload["error"] != nil
+	hasError := payload.Error != ""
 	c.logger.Debug("SYNC response received",
-		"chatsCount", len(chatsData),
-		"hasProfile", payload["profile"] != nil,
+		"chatsCount", len(payload.Chats),
+		"hasProfile", payload.Profile != nil,
 		"hasError", hasError)
 	if hasError {
-		c.logger.Warn("SYNC response contains error", "error", payload["error"])
+		c.logger.Warn("SYNC response contains error", "error", payload.Error)
 	}
-	for _, chatData := range chatsData {
-		chatMap, _ := chatData.(map[string]any)
-		chatType, _ := chatMap["type"].(string)
-
+	for _, raw := range payload.Chats {
 		chat := &types.Chat{}
-		if err := utils.FromMap(chatMap, chat); err != nil {
+		if err := utils.JSONUnmarshal(raw, chat); err != nil {
 			c.logger.Warn("Failed to parse chat from SYNC", "err", err)
 			continue
 		}
 
-		switch enums.ChatType(chatType) {
+		switch chat.Type {
 		case enums.ChatTypeDialog:
 			dialog := &types.Dialog{}
-			if err := utils.FromMap(chatMap, dialog); err == nil {
+			if err := utils.JSONUnmarshal(raw, dialog); err == nil {
 				c.Dialogs = append(c.Dialogs, *dialog)
 				c.logger.Debug("Added dialog from SYNC", "id", dialog.ID)
 			}
@@ -988,14 +1224,9 @@ func (c *MaxClient) handleSyncResponse(msg map[string]any) {
 		}
 	}
 
-	profile, _ := payload["profile"].(map[string]any)
-	contact, _ := profile["contact"].(map[string]any)
-	if contact != nil {
-		me := &types.Me{}
-		if err := utils.FromMap(contact, me); err == nil {
-			c.Me = me
-			c.logger.Debug("Updated profile from SYNC", "phone", me.Phone)
-		}
+	if payload.Profile != nil && payload.Profile.Contact != nil {
+		c.Me = payload.Profile.Contact
+		c.logger.Debug("Updated profile from SYNC", "phone", c.Me.Phone)
 	}
 
 	c.logger.Debug("SYNC processing completed", "totalChats", len(c.Chats), "totalDialogs", len(c.Dialogs), "totalChannels", len(c.Channels))
@@ -1007,21 +1238,28 @@ func (c *MaxClient) sendLoop(ctx context.Context) {
 	defer c.logger.Debug("Send loop stopped")
 	for {
 		select {
-		case msg := <-c.outgoing:
-			data, err := utils.JSONMarshal(msg)
+		case frame := <-c.outgoing:
+			data, err := utils.JSONMarshal(frame)
 			if err != nil {
-				c.logger.Warn("Failed to marshal message", "err", err)
+				c.logger.Warn("Failed to marshal frame", "err", err)
 				continue
 			}
 			c.connMu.RLock()
 			ws := c.ws
+			if c.handshakeOnly && !frame.handshake {
+				// Новый сокет ещё не авторизован: кадр обрабатывается как при отсутствии
+				// соединения, и идемпотентный запрос будет переотправлен после reconnect.
+				ws = nil
+			}
 			c.connMu.RUnlock()
 			if ws == nil {
-				c.logger.Debug("WebSocket is nil, stopping send loop")
-				return
+				c.logger.Debug("WebSocket is not connected, dropping frame", "opcode", frame.Opcode)
+				c.failPendingSeq(frame.Seq, &WebSocketNotConnectedError{})
+				continue
 			}
 			if err := ws.WriteMessage(websocket.TextMessage, data); err != nil {
-				c.logger.Warn("Failed to write message", "err", err)
+				c.logger.Warn("Failed to write frame", "err", err)
+				c.failPendingSeq(frame.Seq, err)
 				errStr := err.Error()
 				isConnectionError := strings.Contains(errStr, "use of closed network connection") ||
 					strings.Contains(errStr, "connection reset by peer") ||
@@ -1045,6 +1283,37 @@ func (c *MaxClient) OnStart(handler func(context.Context)) {
 	c.onStartHandlers = append(c.onStartHandlers, handler)
 }
 
+// Регистрирует обработчик смены состояния соединения.
+// Обработчик получает предыдущее и новое состояние.
+func (c *MaxClient) OnStateChange(handler func(ctx context.Context, from ConnectionState, to ConnectionState)) {
+	c.onStateChangeHandlers = append(c.onStateChangeHandlers, handler)
+}
+
+// Регистрирует обработчик потери соединения с сервером.
+// Не вызывается при штатном закрытии клиента через Close.
+func (c *MaxClient) OnDisconnect(handler func(context.Context, error)) {
+	c.onDisconnectHandlers = append(c.onDisconnectHandlers, handler)
+}
+
+// Регистрирует обработчик, вызываемый после успешного переподключения и повторного SYNC.
+func (c *MaxClient) OnReconnect(handler func(context.Context)) {
+	c.onReconnectHandlers = append(c.onReconnectHandlers, handler)
+}
+
+// Вызывает обработчики OnDisconnect с ошибкой, из‑за которой оборвалось соединение.
+func (c *MaxClient) fireDisconnect(ctx context.Context, err error) {
+	for _, h := range c.onDisconnectHandlers {
+		go h(ctx, err)
+	}
+}
+
+// Вызывает обработчики OnReconnect.
+func (c *MaxClient) fireReconnect(ctx context.Context) {
+	for _, h := range c.onReconnectHandlers {
+		go h(ctx)
+	}
+}
+
 // Регистрирует обработчик входящих сообщений с необязательным фильтром по содержимому.
 func (c *MaxClient) OnMessage(handler func(context.Context, *types.Message), filter *filters.Filter) {
 	c.onMessageHandlers = append(c.onMessageHandlers, messageHandler{
@@ -342,6 +342,194 @@ func TestOnReactionChange_Handler(t *testing.T) {
 	}
 }
 
+// TestOnTyping_Handler проверяет обработку уведомлений о наборе текста.
+func TestOnTyping_Handler(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: workDir,
+		Token:   testAuthToken,
+		Logger:  logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	received := make(chan *types.TypingEvent, 1)
+	client.OnTyping(func(ctx context.Context, event *types.TypingEvent) {
+		received <- event
+	})
+
+	ctx := mockserver.TestContext(t)
+	err = client.Start(ctx)
+	require.NoError(t, err)
+
+	err = server.SendNotification(mockserver.NotifTypingResponse(testChatID, testUserID, true))
+	require.NoError(t, err)
+
+	select {
+	case event := <-received:
+		assert.Equal(t, testChatID, event.ChatID)
+		assert.Equal(t, testUserID, event.UserID)
+		assert.True(t, event.Typing)
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnTyping handler was not called")
+	}
+}
+
+// TestOnReadMark_Handler проверяет доставку отметок о прочтении
+// и поддержку счётчика непрочитанных в кэше чатов.
+func TestOnReadMark_Handler(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodeLogin, func(msg map[string]any) map[string]any {
+		return mockserver.SyncResponse(0, nil, []map[string]any{
+			mockserver.TestChat(testChatID, "CHAT", "Support"),
+		})
+	})
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: workDir,
+		Token:   testAuthToken,
+		Logger:  logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	received := make(chan *types.ReadMark, 2)
+	client.OnReadMark(func(ctx context.Context, mark *types.ReadMark) {
+		received <- mark
+	})
+
+	ctx := mockserver.TestContext(t)
+	err = client.Start(ctx)
+	require.NoError(t, err)
+	me := client.Profile().ID
+
+	for i := 1; i <= 2; i++ {
+		err = server.SendNotification(mockserver.NotifMessageResponse(map[string]any{
+			"id":     int64(i),
+			"chatId": testChatID,
+			"sender": testUserID,
+			"text":   "question",
+			"time":   time.Now().UnixMilli(),
+		}))
+		require.NoError(t, err)
+	}
+	require.True(t, mockserver.WaitForCondition(t, 2*time.Second, func() bool {
+		return client.ChatList()[0].NewMessages == 2
+	}))
+
+	err = server.SendNotification(mockserver.NotifMarkResponse(testChatID, testUserID, 1000))
+	require.NoError(t, err)
+	select {
+	case mark := <-received:
+		assert.Equal(t, testChatID, mark.ChatID)
+		assert.Equal(t, testUserID, mark.UserID)
+		assert.Equal(t, int64(1000), mark.Mark)
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnReadMark handler was not called")
+	}
+	assert.Equal(t, 2, client.ChatList()[0].NewMessages)
+
+	err = server.SendNotification(mockserver.NotifMarkResponse(testChatID, me, 2000))
+	require.NoError(t, err)
+	select {
+	case mark := <-received:
+		assert.Equal(t, me, mark.UserID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnReadMark handler was not called")
+	}
+	assert.Equal(t, 0, client.ChatList()[0].NewMessages)
+}
+
+// TestOnReadMark_DialogUnread проверяет счётчик непрочитанных для личного диалога.
+func TestOnReadMark_DialogUnread(t *testing.T) {
+	const dialogID int64 = 777
+	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodeLogin, func(msg map[string]any) map[string]any {
+		return mockserver.SyncResponse(0, nil, []map[string]any{
+			mockserver.TestChat(dialogID, mockserver.ChatTypeDialog, ""),
+		})
+	})
+
+	client, err := NewMaxClient(ClientConfig{
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: t.TempDir(),
+		Token:   testAuthToken,
+		Logger:  logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	require.NoError(t, client.Start(ctx))
+	require.Len(t, client.DialogList(), 1)
+	me := client.Profile().ID
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, server.SendNotification(mockserver.NotifMessageResponse(map[string]any{
+			"id":     int64(i),
+			"chatId": dialogID,
+			"sender": testUserID,
+			"text":   "hi",
+			"time":   time.Now().UnixMilli(),
+		})))
+	}
+	require.True(t, mockserver.WaitForCondition(t, 2*time.Second, func() bool {
+		return client.DialogList()[0].NewMessages == 3
+	}))
+
+	require.NoError(t, server.SendNotification(mockserver.NotifMarkResponse(dialogID, me, 2000)))
+	require.True(t, mockserver.WaitForCondition(t, 2*time.Second, func() bool {
+		return client.DialogList()[0].NewMessages == 0
+	}))
+}
+
+// TestOnContactUpdate_Handler проверяет обработку уведомлений об изменении контактов.
+func TestOnContactUpdate_Handler(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: workDir,
+		Token:   testAuthToken,
+		Logger:  logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	received := make(chan *types.Contact, 1)
+	client.OnContactUpdate(func(ctx context.Context, contact *types.Contact) {
+		received <- contact
+	})
+
+	ctx := mockserver.TestContext(t)
+	err = client.Start(ctx)
+	require.NoError(t, err)
+
+	contact := mockserver.TestContact(testUserID, "Updated", "Name", "+79995555555")
+	err = server.SendNotification(mockserver.NotifContactResponse(contact))
+	require.NoError(t, err)
+
+	select {
+	case got := <-received:
+		assert.Equal(t, testUserID, got.ID)
+		require.NotEmpty(t, got.Names)
+		require.NotNil(t, got.Names[0].FirstName)
+		assert.Equal(t, "Updated", *got.Names[0].FirstName)
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnContactUpdate handler was not called")
+	}
+}
+
 // TestMultipleMessageHandlers проверяет работу нескольких обработчиков.
 func TestMultipleMessageHandlers(t *testing.T) {
 	server := mockserver.StartMockServerWithDefaults(t)
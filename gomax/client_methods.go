@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"mime/multipart"
 	"net/http"
 	"os"
 	"strconv"
@@ -18,8 +17,8 @@ import (
 	"github.com/fresh-milkshake/gomax/internal/constants"
 	"github.com/fresh-milkshake/gomax/internal/payloads"
 	"github.com/fresh-milkshake/gomax/internal/utils"
+	"github.com/fresh-milkshake/gomax/sessionstore"
 	"github.com/fresh-milkshake/gomax/types"
-	"github.com/mdp/qrterminal/v3"
 )
 
 // Инициирует авторизацию по номеру телефона:
@@ -30,32 +29,18 @@ func (c *MaxClient) RequestCode(ctx context.Context, phone string, language stri
 		Type:     enums.AuthTypeStartAuth,
 		Language: language,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return "", err
-	}
-
-	c.logger.Debug("Requesting auth code", "phone", phone, "language", language, "payload", payloadMap)
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeAuthRequest, payloadMap)
-	if err != nil {
-		c.logger.Error("RequestCode sendAndWaitResponse failed", "err", err)
-		return "", err
-	}
+	c.logger.Debug("Requesting auth code", "phone", phone, "language", language)
 
-	c.logger.Debug("RequestCode response received", "resp", resp)
-
-	if err := HandleError(resp); err != nil {
-		c.logger.Error("RequestCode HandleError failed", "err", err, "response", resp)
+	var resp tokenResponse
+	if err := c.request(ctx, enums.OpcodeAuthRequest, pl, &resp); err != nil {
+		c.logger.Error("RequestCode failed", "err", err)
 		return "", err
 	}
 
-	payload, _ := resp["payload"].(map[string]any)
-	token, _ := payload["token"].(string)
-	if token == "" {
+	if resp.Token == "" {
 		return "", fmt.Errorf("token not received")
 	}
-	return token, nil
+	return resp.Token, nil
 }
 
 // Повторно запрашивает код подтверждения.
@@ -65,24 +50,14 @@ func (c *MaxClient) ResendCode(ctx context.Context, phone string, language strin
 		Type:     enums.AuthTypeResend,
 		Language: language,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return "", err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeAuthRequest, payloadMap)
-	if err != nil {
-		return "", err
-	}
-	if err := HandleError(resp); err != nil {
+	var resp tokenResponse
+	if err := c.request(ctx, enums.OpcodeAuthRequest, pl, &resp); err != nil {
 		return "", err
 	}
-	payload, _ := resp["payload"].(map[string]any)
-	token, _ := payload["token"].(string)
-	if token == "" {
+	if resp.Token == "" {
 		return "", fmt.Errorf("token not received")
 	}
-	return token, nil
+	return resp.Token, nil
 }
 
 // Выполняет полный процесс входа: запрашивает код, получает его от пользователя
@@ -93,12 +68,11 @@ func (c *MaxClient) Login(ctx context.Context) error {
 		if !validateVersion(c.cfg.UserAgent.AppVersion, constants.MinWebQRAppVersion) {
 			return fmt.Errorf("app version %s is below minimum %s for WEB QR login", c.cfg.UserAgent.AppVersion, constants.MinWebQRAppVersion)
 		}
-		authToken, err := c.loginByQR(ctx)
+		resp, err := c.loginByQR(ctx)
 		if err != nil {
 			return err
 		}
-		c.token = authToken
-		return c.db.UpdateToken(c.deviceID, c.cfg.UserAgent.DeviceType, authToken)
+		return c.completeLogin(ctx, resp)
 	}
 
 	tempToken, err := c.RequestCode(ctx, c.cfg.Phone, "ru")
@@ -168,171 +142,216 @@ func validateVersion(version string, minVersion string) bool {
 	return len(a) >= len(b)
 }
 
-// Выполняет QR‑авторизацию для WEB клиентов.
-func (c *MaxClient) loginByQR(ctx context.Context) (string, error) {
+// Выполняет QR‑авторизацию для WEB клиентов. О каждом этапе сообщает QRProvider
+// (по умолчанию QR печатается в stdout); истёкший код перевыпускается автоматически.
+// Возвращает ответ LOGIN_BY_QR с токеном входа или запросом облачного пароля.
+func (c *MaxClient) loginByQR(ctx context.Context) (*tokenAttrsResponse, error) {
 	c.logger.Info("Starting QR login flow")
 
-	// 1. Запрос QR‑данных
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeGetQR, map[string]any{})
-	if err != nil {
-		return "", err
-	}
-	if err := HandleError(resp); err != nil {
-		return "", err
-	}
-	payload, ok := resp["payload"].(map[string]any)
-	if !ok {
-		return "", fmt.Errorf("invalid QR response payload")
-	}
-	pollInterval, ok := asFloat(payload["pollingInterval"])
-	if !ok {
-		return "", fmt.Errorf("invalid pollingInterval in QR payload")
-	}
-	link, ok := payload["qrLink"].(string)
-	if !ok || link == "" {
-		return "", fmt.Errorf("invalid qrLink in QR payload")
-	}
-	trackID, ok := payload["trackId"].(string)
-	if !ok || trackID == "" {
-		return "", fmt.Errorf("invalid trackId in QR payload")
-	}
-	expiresAt, ok := asFloat(payload["expiresAt"])
-	if !ok {
-		return "", fmt.Errorf("invalid expiresAt in QR payload")
+	provider := c.cfg.QRProvider
+	if provider == nil {
+		provider = TerminalQRPresenter(os.Stdout)
 	}
 
-	if pollInterval == 0 || link == "" || trackID == "" || expiresAt == 0 {
-		return "", fmt.Errorf("invalid QR login payload")
+	var state QRState
+	var pollInterval time.Duration
+
+	// Запрашивает новый QR и сообщает о нём провайдеру.
+	issue := func() error {
+		var qr qrResponse
+		if err := c.request(ctx, enums.OpcodeGetQR, nil, &qr); err != nil {
+			return err
+		}
+		if qr.PollingInterval == 0 || qr.QRLink == "" || qr.TrackID == "" || qr.ExpiresAt == 0 {
+			return fmt.Errorf("invalid QR login payload")
+		}
+		pollInterval = time.Duration(qr.PollingInterval) * time.Millisecond
+		state = QRState{
+			Status:    QRStatusNew,
+			Link:      qr.QRLink,
+			TrackID:   qr.TrackID,
+			ExpiresAt: time.UnixMilli(int64(qr.ExpiresAt)),
+			Attempt:   state.Attempt + 1,
+		}
+		c.logger.Debug("QR code issued", "trackId", state.TrackID, "attempt", state.Attempt)
+		return provider(ctx, state)
 	}
 
-	cfg := qrterminal.Config{
-		Level:      qrterminal.M,
-		Writer:     os.Stdout,
-		BlackChar:  "██",
-		WhiteChar:  "░░", // видимый «пробел», чтобы терминал не обрезал края
-		HalfBlocks: false,
-		QuietZone:  1,
+	// 1. Запрос QR‑данных
+	if err := issue(); err != nil {
+		return nil, err
 	}
-	fmt.Println("Scan this QR code to login (or use the link below):")
-	qrterminal.GenerateWithConfig(link, cfg)
-	fmt.Printf("QR link: %s\n", link)
 
 	// 2. Ожидание подтверждения
 	for {
 		select {
 		case <-ctx.Done():
-			return "", ctx.Err()
-		case <-time.After(time.Duration(pollInterval) * time.Millisecond):
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
 		}
 
-		statusResp, err := c.sendAndWaitResponse(ctx, enums.OpcodeGetQRStatus, map[string]any{
-			"trackId": trackID,
-		})
-		if err != nil {
-			return "", err
-		}
-		if err := HandleError(statusResp); err != nil {
-			return "", err
+		var status qrStatusResponse
+		if err := c.request(ctx, enums.OpcodeGetQRStatus, map[string]any{
+			"trackId": state.TrackID,
+		}, &status); err != nil {
+			return nil, err
 		}
 
-		statusPayload, ok := statusResp["payload"].(map[string]any)
-		if !ok {
-			continue
-		}
-		status, ok := statusPayload["status"].(map[string]any)
-		if !ok {
-			continue
-		}
+		if status.Status != nil && status.Status.LoginAvailable {
+			state.Status = QRStatusConfirmed
+			if err := provider(ctx, state); err != nil {
+				return nil, err
+			}
 
-		if loginAvailable, _ := status["loginAvailable"].(bool); loginAvailable {
 			// 3. Запрос токена по trackId
-			finalResp, err := c.sendAndWaitResponse(ctx, enums.OpcodeLoginByQR, map[string]any{
-				"trackId": trackID,
-			})
-			if err != nil {
-				return "", err
-			}
-			if err := HandleError(finalResp); err != nil {
-				return "", err
-			}
-			finalPayload, ok := finalResp["payload"].(map[string]any)
-			if !ok {
-				return "", fmt.Errorf("invalid LOGIN_BY_QR payload")
+			var final tokenAttrsResponse
+			if err := c.request(ctx, enums.OpcodeLoginByQR, map[string]any{
+				"trackId": state.TrackID,
+			}, &final); err != nil {
+				return nil, err
 			}
-			tokenAttrs, ok := finalPayload["tokenAttrs"].(map[string]any)
-			if !ok {
-				return "", fmt.Errorf("LOGIN_BY_QR missing tokenAttrs")
+			if final.token(constants.TokenTypeLogin) == "" && final.PasswordChallenge == nil {
+				return nil, fmt.Errorf("login token not received in QR flow")
 			}
-			loginData, ok := tokenAttrs[constants.TokenTypeLogin].(map[string]any)
-			if !ok {
-				return "", fmt.Errorf("LOGIN_BY_QR missing LOGIN token")
+			return &final, nil
+		}
+
+		if status.Status != nil && status.Status.ExpiresAt > 0 {
+			state.ExpiresAt = time.UnixMilli(int64(status.Status.ExpiresAt))
+		}
+		if !time.Now().Before(state.ExpiresAt) {
+			state.Status = QRStatusExpired
+			if err := provider(ctx, state); err != nil {
+				return nil, err
 			}
-			token, _ := loginData["token"].(string)
-			if token == "" {
-				return "", fmt.Errorf("login token not received in QR flow")
+			if err := issue(); err != nil {
+				return nil, err
 			}
-			return token, nil
+			continue
 		}
 
-		nowMs := float64(time.Now().UnixMilli())
-		if expiresAt > 0 && nowMs >= expiresAt {
-			return "", fmt.Errorf("QR code expired")
+		state.Status = QRStatusPending
+		if err := provider(ctx, state); err != nil {
+			return nil, err
 		}
 	}
 }
 
-// asFloat аккуратно преобразует int/float64 в float64.
-func asFloat(v any) (float64, bool) {
-	switch t := v.(type) {
-	case float64:
-		return t, true
-	case float32:
-		return float64(t), true
-	case int:
-		return float64(t), true
-	case int64:
-		return float64(t), true
-	case int32:
-		return float64(t), true
-	default:
-		return 0, false
+// Подтверждает код верификации, обновляет auth‑токен клиента
+// и сохраняет его в базе сессии. Если у аккаунта включена двухфакторная
+// аутентификация, дополнительно запрашивает облачный пароль через PasswordProvider.
+func (c *MaxClient) SendCode(ctx context.Context, code string, token string) error {
+	resp, err := c.checkCode(ctx, code, token)
+	if err != nil {
+		return err
 	}
+	return c.completeLogin(ctx, resp)
 }
 
-// Подтверждает код верификации, обновляет auth‑токен клиента
-// и сохраняет его в базе сессии.
-func (c *MaxClient) SendCode(ctx context.Context, code string, token string) error {
+// Завершает вход по ответу сервера: сохраняет токен входа, а если у аккаунта включена
+// двухфакторная аутентификация — запрашивает облачный пароль и подтверждает его через CheckPassword.
+func (c *MaxClient) completeLogin(ctx context.Context, resp *tokenAttrsResponse) error {
+	authToken := resp.token(constants.TokenTypeLogin)
+	if authToken == "" && resp.PasswordChallenge != nil {
+		password, err := c.providePassword(ctx, resp.PasswordChallenge.Hint)
+		if err != nil {
+			return err
+		}
+		return c.CheckPassword(ctx, resp.PasswordChallenge.TrackID, password)
+	}
+	if authToken == "" {
+		return fmt.Errorf("login token not received")
+	}
+
+	return c.saveToken(ctx, authToken)
+}
+
+// Отправляет код верификации (AUTH) и возвращает выданные сервером токены
+// или запрос облачного пароля.
+func (c *MaxClient) checkCode(ctx context.Context, code string, token string) (*tokenAttrsResponse, error) {
 	pl := payloads.SendCodePayload{
 		Token:         token,
 		VerifyCode:    code,
 		AuthTokenType: enums.AuthTypeCheckCode,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return err
+	var resp tokenAttrsResponse
+	if err := c.request(ctx, enums.OpcodeAuth, pl, &resp); err != nil {
+		return nil, err
 	}
+	return &resp, nil
+}
 
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeAuth, payloadMap)
-	if err != nil {
-		return err
+// Подтверждает облачный пароль для трека входа, полученного после проверки кода,
+// обновляет auth‑токен клиента и сохраняет его в базе сессии.
+func (c *MaxClient) CheckPassword(ctx context.Context, trackID string, password string) error {
+	pl := payloads.TrackPasswordPayload{
+		TrackID:  trackID,
+		Password: password,
 	}
-
-	if err := HandleError(resp); err != nil {
+	var resp tokenAttrsResponse
+	if err := c.request(ctx, enums.OpcodeAuthLoginCheckPassword, pl, &resp); err != nil {
 		return err
 	}
 
-	payload, _ := resp["payload"].(map[string]any)
-	tokenAttrs, _ := payload["tokenAttrs"].(map[string]any)
-	login, _ := tokenAttrs[constants.TokenTypeLogin].(map[string]any)
-	authToken, _ := login["token"].(string)
+	authToken := resp.token(constants.TokenTypeLogin)
 	if authToken == "" {
-		return fmt.Errorf("login token not received")
+		return fmt.Errorf("login token not received after password check")
 	}
+	return c.saveToken(ctx, authToken)
+}
+
+// Получает облачный пароль из пользовательского колбэка или stdin, если колбэк не задан.
+func (c *MaxClient) providePassword(ctx context.Context, hint string) (string, error) {
+	if c.cfg.PasswordProvider != nil {
+		return c.cfg.PasswordProvider(ctx, hint)
+	}
+
+	if hint != "" {
+		fmt.Printf("Enter 2FA password (hint: %s): ", hint)
+	} else {
+		fmt.Print("Enter 2FA password: ")
+	}
+	os.Stdout.Sync()
+
+	reader := bufio.NewReader(os.Stdin)
+	text, err := reader.ReadString('\n')
+	if err != nil {
+		c.logger.Error("Failed to read 2FA password", "err", err)
+		return "", fmt.Errorf("failed to read 2FA password: %w", err)
+	}
+
+	password := strings.TrimRight(text, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("empty 2FA password")
+	}
+	return password, nil
+}
 
+// Запоминает auth‑токен в клиенте и сохраняет его в хранилище сессии.
+func (c *MaxClient) saveToken(ctx context.Context, authToken string) error {
 	c.token = authToken
-	if err := c.db.UpdateToken(c.deviceID, c.cfg.UserAgent.DeviceType, authToken); err != nil {
+	c.session.DeviceID = c.deviceID.String()
+	c.session.DeviceType = c.cfg.UserAgent.DeviceType
+	c.session.Token = authToken
+	return c.store.Save(ctx, c.cfg.Phone, c.session)
+}
+
+// Возвращает номера аккаунтов, сессии которых сохранены в хранилище клиента.
+func (c *MaxClient) ListAccounts(ctx context.Context) ([]string, error) {
+	return c.store.Accounts(ctx)
+}
+
+// Удаляет сохранённую сессию аккаунта из хранилища клиента. Если это аккаунт
+// самого клиента, сбрасывает и его токен: следующий Start потребует авторизации.
+// Сессия на сервере при этом не завершается.
+func (c *MaxClient) RemoveAccount(ctx context.Context, phone string) error {
+	if err := c.store.Delete(ctx, phone); err != nil {
 		return err
 	}
+	if phone == c.cfg.Phone {
+		c.token = ""
+		c.session = &sessionstore.Data{DeviceID: c.deviceID.String(), DeviceType: c.cfg.UserAgent.DeviceType}
+	}
 	return nil
 }
 
@@ -349,60 +368,39 @@ func (c *MaxClient) Register(ctx context.Context, firstName string, lastName *st
 		return err
 	}
 
-	plSend := payloads.SendCodePayload{
-		Token:         tempToken,
-		VerifyCode:    code,
-		AuthTokenType: enums.AuthTypeCheckCode,
-	}
-	sendMap, err := utils.ToMap(plSend)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeAuth, sendMap)
+	sendResp, err := c.checkCode(ctx, code, tempToken)
 	if err != nil {
 		return err
 	}
-	if err := HandleError(resp); err != nil {
-		return err
-	}
 
-	sendPayload, _ := resp["payload"].(map[string]any)
-	tokenAttrs, _ := sendPayload["tokenAttrs"].(map[string]any)
-	regData, _ := tokenAttrs[constants.TokenTypeRegister].(map[string]any)
-	registerToken, _ := regData["token"].(string)
+	registerToken := sendResp.token(constants.TokenTypeRegister)
 	if registerToken == "" {
 		return fmt.Errorf("registration token not received")
 	}
 
+	return c.confirmRegistration(ctx, registerToken, firstName, lastName)
+}
+
+// Завершает регистрацию (AUTH_CONFIRM) по регистрационному токену
+// и сохраняет выданный auth‑токен.
+func (c *MaxClient) confirmRegistration(ctx context.Context, registerToken string, firstName string, lastName *string) error {
 	pl := payloads.RegisterPayload{
 		FirstName: firstName,
 		LastName:  lastName,
 		Token:     registerToken,
 		TokenType: enums.AuthTypeRegister,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return err
-	}
-
-	resp, err = c.sendAndWaitResponse(ctx, enums.OpcodeAuthConfirm, payloadMap)
-	if err != nil {
-		return err
-	}
-
-	if err = HandleError(resp); err != nil {
+	var resp tokenResponse
+	if err := c.request(ctx, enums.OpcodeAuthConfirm, pl, &resp); err != nil {
 		return err
 	}
 
-	payload, _ := resp["payload"].(map[string]any)
-	authToken, _ := payload["token"].(string)
+	authToken := resp.Token
 	if authToken == "" {
 		return fmt.Errorf("registration token not received")
 	}
 
-	c.token = authToken
-	return c.db.UpdateToken(c.deviceID, c.cfg.UserAgent.DeviceType, authToken)
+	return c.saveToken(ctx, authToken)
 }
 
 // Отправляет текстовое сообщение в указанный чат с поддержкой markdown‑форматирования,
@@ -468,30 +466,26 @@ func (c *MaxClient) SendMessage(ctx context.Context, text string, chatID int64,
 		Notify: notify,
 	}
 
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeMsgSend, payloadMap)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
+	// Повтор безопасен: сервер дедуплицирует сообщения по cid.
 	msg := &types.Message{}
-	if err := utils.FromMap(payload, msg); err != nil {
+	if err := c.requestIdempotent(ctx, enums.OpcodeMsgSend, pl, msg); err != nil {
 		return nil, err
 	}
+	c.stopTyping(chatID)
 	return msg, nil
 }
 
 // Загружает вложение (фото, файл или видео) и возвращает подходящий payload для отправки сообщения.
 func (c *MaxClient) uploadAttachment(ctx context.Context, file files.BaseFile) (interface{}, error) {
+	// Файлы по URL запрашиваются заранее: имя и тип нужны до резервирования слота загрузки.
+	// Если загрузка оборвётся до чтения файла, Release закроет оставленное Fetch тело ответа.
+	if remote, ok := file.(remoteFile); ok {
+		defer remote.Release()
+		if err := remote.Fetch(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	switch f := file.(type) {
 	case *files.Photo:
 		return c.uploadPhoto(ctx, f)
@@ -500,7 +494,8 @@ func (c *MaxClient) uploadAttachment(ctx context.Context, file files.BaseFile) (
 	case *files.Video:
 		return c.uploadVideo(ctx, f)
 	default:
-		return nil, fmt.Errorf("unsupported file type")
+		// Пользовательские реализации BaseFile загружаются как обычные файлы.
+		return c.uploadFile(ctx, f)
 	}
 }
 
@@ -535,7 +530,7 @@ func (c *MaxClient) doHTTPRequestWithRetry(ctx context.Context, createRequest fu
 			}
 
 			if delay < maxDelay {
-				delay = time.Duration(float64(delay) * 2.0)
+				delay = time.Duration(float64(delay) * constants.DefaultBackoffMultiplier)
 				if delay > maxDelay {
 					delay = maxDelay
 				}
@@ -579,19 +574,15 @@ func (c *MaxClient) doHTTPRequestWithRetry(ctx context.Context, createRequest fu
 // и возвращает AttachPhotoPayload с токеном загруженного изображения.
 func (c *MaxClient) uploadPhoto(ctx context.Context, photo *files.Photo) (interface{}, error) {
 	pl := payloads.UploadPayload{Count: 1}
-	payloadMap, _ := utils.ToMap(pl)
 
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodePhotoUpload, payloadMap)
-	if err != nil {
-		return nil, err
+	var slot struct {
+		URL string `json:"url"`
 	}
-
-	if err := HandleError(resp); err != nil {
+	if err := c.request(ctx, enums.OpcodePhotoUpload, pl, &slot); err != nil {
 		return nil, err
 	}
 
-	payload, _ := resp["payload"].(map[string]any)
-	url, _ := payload["url"].(string)
+	url := slot.URL
 	if url == "" {
 		return nil, fmt.Errorf("upload URL not received")
 	}
@@ -601,33 +592,22 @@ func (c *MaxClient) uploadPhoto(ctx context.Context, photo *files.Photo) (interf
 		return nil, err
 	}
 
-	data, err := photo.Read()
+	openBody, length, contentType, err := multipartFileBody(ctx, photo, fmt.Sprintf("image.%s", ext))
 	if err != nil {
 		return nil, err
 	}
 
-	bodyStr := ""
-	contentType := ""
-	{
-		body := &strings.Builder{}
-		writer := multipart.NewWriter(body)
-		part, err := writer.CreateFormFile("file", fmt.Sprintf("image.%s", ext))
+	httpResp, err := c.doHTTPRequestWithRetry(ctx, func() (*http.Request, error) {
+		body, err := openBody()
 		if err != nil {
 			return nil, err
 		}
-		if _, err := part.Write(data); err != nil {
-			return nil, err
-		}
-		contentType = writer.FormDataContentType()
-		writer.Close()
-		bodyStr = body.String()
-	}
-
-	httpResp, err := c.doHTTPRequestWithRetry(ctx, func() (*http.Request, error) {
-		req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(bodyStr))
+		req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 		if err != nil {
+			body.Close()
 			return nil, err
 		}
+		req.ContentLength = length
 		req.Header.Set("Content-Type", contentType)
 		return req, nil
 	})
@@ -667,232 +647,76 @@ func (c *MaxClient) uploadPhoto(ctx context.Context, photo *files.Photo) (interf
 	}, nil
 }
 
-// Резервирует слот загрузки файла, отправляет его по выданному URL
+// Резервирует слот загрузки файла, потоково отправляет его по выданному URL
 // и ожидает подтверждения обработки через NOTIF_ATTACH, возвращая AttachFilePayload.
-func (c *MaxClient) uploadFile(ctx context.Context, file *files.File) (interface{}, error) {
+func (c *MaxClient) uploadFile(ctx context.Context, file files.BaseFile) (interface{}, error) {
 	pl := payloads.UploadPayload{Count: 1}
-	payloadMap, _ := utils.ToMap(pl)
 
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeFileUpload, payloadMap)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := HandleError(resp); err != nil {
+	var slot uploadSlotResponse
+	if err := c.request(ctx, enums.OpcodeFileUpload, pl, &slot); err != nil {
 		return nil, err
 	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	info, _ := payload["info"].([]interface{})
-	if len(info) == 0 {
+	if len(slot.Info) == 0 {
 		return nil, fmt.Errorf("upload info not received")
 	}
 
-	infoMap, _ := info[0].(map[string]any)
-	url, _ := infoMap["url"].(string)
-	fileID, _ := infoMap["fileId"].(float64)
+	url := slot.Info[0].URL
+	fileID := slot.Info[0].FileID
 	if url == "" || fileID == 0 {
 		return nil, fmt.Errorf("upload URL or file ID not received")
 	}
 
-	data, err := file.Read()
-	if err != nil {
+	waitCh := c.expectAttach(fileID)
+	if err := c.uploadStream(ctx, url, file); err != nil {
+		c.cancelAttach(fileID)
 		return nil, err
 	}
-
-	dataStr := string(data)
-	fileName := file.FileName()
-	contentRange := fmt.Sprintf("0-%d/%d", len(data)-1, len(data))
-
-	httpResp, err := c.doHTTPRequestWithRetry(ctx, func() (*http.Request, error) {
-		req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(dataStr))
-		if err != nil {
-			return nil, err
-		}
-		req.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
-		req.Header.Set("Content-Range", contentRange)
-		return req, nil
-	})
-	if err != nil {
+	if err := c.awaitAttach(ctx, fileID, waitCh, "file"); err != nil {
 		return nil, err
 	}
-	defer httpResp.Body.Close()
-
-	if httpResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("upload failed with status %d", httpResp.StatusCode)
-	}
-
-	waitCh := make(chan map[string]any, 1)
-	c.fileUploadWaitersMu.Lock()
-	c.fileUploadWaiters[int64(fileID)] = waitCh
-	c.fileUploadWaitersMu.Unlock()
-
-	select {
-	case <-waitCh:
-	case <-ctx.Done():
-		c.fileUploadWaitersMu.Lock()
-		if ch, ok := c.fileUploadWaiters[int64(fileID)]; ok {
-			delete(c.fileUploadWaiters, int64(fileID))
-			close(ch)
-		}
-		c.fileUploadWaitersMu.Unlock()
-		return nil, ctx.Err()
-	case <-time.After(time.Duration(constants.DefaultTimeout * float64(time.Second))):
-		c.fileUploadWaitersMu.Lock()
-		if ch, ok := c.fileUploadWaiters[int64(fileID)]; ok {
-			delete(c.fileUploadWaiters, int64(fileID))
-			close(ch)
-		}
-		c.fileUploadWaitersMu.Unlock()
-		return nil, fmt.Errorf("timeout waiting for file processing")
-	}
 
 	return payloads.AttachFilePayload{
 		Type:   enums.AttachTypeFile,
-		FileID: int64(fileID),
+		FileID: fileID,
 	}, nil
 }
 
-// Резервирует слот загрузки видео, отправляет бинарные данные
+// Резервирует слот загрузки видео, потоково отправляет бинарные данные
 // и ожидает подтверждения обработки через NOTIF_ATTACH, возвращая VideoAttachPayload.
 func (c *MaxClient) uploadVideo(ctx context.Context, video *files.Video) (interface{}, error) {
 	pl := payloads.UploadPayload{Count: 1}
-	payloadMap, _ := utils.ToMap(pl)
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeVideoUpload, payloadMap)
-	if err != nil {
-		return nil, err
-	}
 
-	if err := HandleError(resp); err != nil {
+	var slot uploadSlotResponse
+	if err := c.request(ctx, enums.OpcodeVideoUpload, pl, &slot); err != nil {
 		return nil, err
 	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	info, _ := payload["info"].([]interface{})
-	if len(info) == 0 {
+	if len(slot.Info) == 0 {
 		return nil, fmt.Errorf("upload info not received")
 	}
 
-	infoMap, _ := info[0].(map[string]any)
-	url, _ := infoMap["url"].(string)
-	videoID, _ := infoMap["videoId"].(float64)
-	token, _ := infoMap["token"].(string)
+	url := slot.Info[0].URL
+	videoID := slot.Info[0].VideoID
+	token := slot.Info[0].Token
 	if url == "" || videoID == 0 || token == "" {
 		return nil, fmt.Errorf("upload URL, video ID or token not received")
 	}
 
-	data, err := video.Read()
-	if err != nil {
+	waitCh := c.expectAttach(videoID)
+	if err := c.uploadStream(ctx, url, video); err != nil {
+		c.cancelAttach(videoID)
 		return nil, err
 	}
-
-	dataStr := string(data)
-	fileName := video.FileName()
-	contentRange := fmt.Sprintf("0-%d/%d", len(data)-1, len(data))
-
-	httpResp, err := c.doHTTPRequestWithRetry(ctx, func() (*http.Request, error) {
-		req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(dataStr))
-		if err != nil {
-			return nil, err
-		}
-		req.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
-		req.Header.Set("Content-Range", contentRange)
-		return req, nil
-	})
-	if err != nil {
+	if err := c.awaitAttach(ctx, videoID, waitCh, "video"); err != nil {
 		return nil, err
 	}
-	defer httpResp.Body.Close()
-
-	if httpResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("upload failed with status %d", httpResp.StatusCode)
-	}
-
-	waitCh := make(chan map[string]any, 1)
-	c.fileUploadWaitersMu.Lock()
-	c.fileUploadWaiters[int64(videoID)] = waitCh
-	c.fileUploadWaitersMu.Unlock()
-
-	select {
-	case <-waitCh:
-	case <-ctx.Done():
-		c.fileUploadWaitersMu.Lock()
-		if ch, ok := c.fileUploadWaiters[int64(videoID)]; ok {
-			delete(c.fileUploadWaiters, int64(videoID))
-			close(ch)
-		}
-		c.fileUploadWaitersMu.Unlock()
-		return nil, ctx.Err()
-	case <-time.After(time.Duration(constants.DefaultTimeout * float64(time.Second))):
-		c.fileUploadWaitersMu.Lock()
-		if ch, ok := c.fileUploadWaiters[int64(videoID)]; ok {
-			delete(c.fileUploadWaiters, int64(videoID))
-			close(ch)
-		}
-		c.fileUploadWaitersMu.Unlock()
-		return nil, fmt.Errorf("timeout waiting for video processing")
-	}
 
 	return payloads.VideoAttachPayload{
 		Type:    enums.AttachTypeVideo,
-		VideoID: int64(videoID),
+		VideoID: videoID,
 		Token:   token,
 	}, nil
 }
 
-// Отправляет команду с указанным opcode и payload
-// и блокирующе ждёт полного JSON‑ответа от сервера Max.
-func (c *MaxClient) sendAndWaitResponse(ctx context.Context, opcode enums.Opcode, payload map[string]any) (map[string]any, error) {
-	seq := c.nextSeq()
-	respCh := make(chan map[string]any, 1)
-
-	c.pendingMu.Lock()
-	c.pending[seq] = respCh
-	c.pendingMu.Unlock()
-
-	msg := map[string]any{
-		"ver":     constants.ProtocolVersion,
-		"cmd":     constants.ProtocolCommand,
-		"seq":     seq,
-		"opcode":  int(opcode),
-		"payload": payload,
-	}
-
-	select {
-	case c.outgoing <- msg:
-	case <-ctx.Done():
-		c.pendingMu.Lock()
-		if ch, ok := c.pending[seq]; ok {
-			delete(c.pending, seq)
-			close(ch)
-		}
-		c.pendingMu.Unlock()
-		return nil, ctx.Err()
-	}
-
-	select {
-	case resp := <-respCh:
-		return resp, nil
-	case <-ctx.Done():
-		c.pendingMu.Lock()
-		if ch, ok := c.pending[seq]; ok {
-			delete(c.pending, seq)
-			close(ch)
-		}
-		c.pendingMu.Unlock()
-		return nil, ctx.Err()
-	case <-time.After(time.Duration(constants.DefaultTimeout * float64(time.Second))):
-		c.pendingMu.Lock()
-		if ch, ok := c.pending[seq]; ok {
-			delete(c.pending, seq)
-			close(ch)
-		}
-		c.pendingMu.Unlock()
-		return nil, fmt.Errorf("timeout waiting for response")
-	}
-}
-
 // Редактирует ранее отправленное сообщение, изменяя текст, форматирование
 // и вложения, и возвращает обновлённый объект Message.
 func (c *MaxClient) EditMessage(ctx context.Context, chatID int64, messageID int64, text string, attachment files.BaseFile, attachments []files.BaseFile) (*types.Message, error) {
@@ -944,23 +768,8 @@ func (c *MaxClient) EditMessage(ctx context.Context, chatID int64, messageID int
 		Attaches:  attaches,
 	}
 
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeMsgEdit, payloadMap)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
 	msg := &types.Message{}
-	if err := utils.FromMap(payload, msg); err != nil {
+	if err := c.request(ctx, enums.OpcodeMsgEdit, pl, msg); err != nil {
 		return nil, err
 	}
 	return msg, nil
@@ -974,17 +783,7 @@ func (c *MaxClient) DeleteMessage(ctx context.Context, chatID int64, messageIDs
 		MessageIDs: messageIDs,
 		ForMe:      forMe,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeMsgDelete, payloadMap)
-	if err != nil {
-		return err
-	}
-
-	return HandleError(resp)
+	return c.request(ctx, enums.OpcodeMsgDelete, pl, nil)
 }
 
 // Загружает сообщения чата в заданном окне относительно отметки времени fromTime.
@@ -1001,33 +800,11 @@ func (c *MaxClient) FetchHistory(ctx context.Context, chatID int64, fromTime *in
 		Backward:    backward,
 		GetMessages: true,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeChatHistory, payloadMap)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := HandleError(resp); err != nil {
+	var resp messagesResponse
+	if err := c.requestIdempotent(ctx, enums.OpcodeChatHistory, pl, &resp); err != nil {
 		return nil, err
 	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	messagesData, _ := payload["messages"].([]interface{})
-
-	messages := make([]*types.Message, 0, len(messagesData))
-	for _, msgData := range messagesData {
-		msgMap, _ := msgData.(map[string]any)
-		msg := &types.Message{}
-		if err := utils.FromMap(msgMap, msg); err == nil {
-			messages = append(messages, msg)
-		}
-	}
-
-	return messages, nil
+	return resp.list(c.logger, chatID), nil
 }
 
 // Закрепляет указанное сообщение в чате и опционально уведомляет участников.
@@ -1037,17 +814,7 @@ func (c *MaxClient) PinMessage(ctx context.Context, chatID int64, messageID int6
 		NotifyPin:    notifyPin,
 		PinMessageID: messageID,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeChatUpdate, payloadMap)
-	if err != nil {
-		return err
-	}
-
-	return HandleError(resp)
+	return c.request(ctx, enums.OpcodeChatUpdate, pl, nil)
 }
 
 // Запрашивает метаданные видео‑вложения по chatID, messageID и videoID.
@@ -1057,23 +824,8 @@ func (c *MaxClient) GetVideoById(ctx context.Context, chatID int64, messageID in
 		MessageID: messageID,
 		VideoID:   videoID,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeVideoPlay, payloadMap)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
 	videoReq := &types.VideoRequest{}
-	if err := utils.FromMap(payload, videoReq); err != nil {
+	if err := c.requestIdempotent(ctx, enums.OpcodeVideoPlay, pl, videoReq); err != nil {
 		return nil, err
 	}
 	return videoReq, nil
@@ -1086,23 +838,8 @@ func (c *MaxClient) GetFileById(ctx context.Context, chatID int64, messageID int
 		MessageID: messageID,
 		FileID:    fileID,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeFileDownload, payloadMap)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
 	fileReq := &types.FileRequest{}
-	if err := utils.FromMap(payload, fileReq); err != nil {
+	if err := c.requestIdempotent(ctx, enums.OpcodeFileDownload, pl, fileReq); err != nil {
 		return nil, err
 	}
 	return fileReq, nil
@@ -1118,27 +855,11 @@ func (c *MaxClient) AddReaction(ctx context.Context, chatID int64, messageID str
 			ID:           reaction,
 		},
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeMsgReaction, payloadMap)
-	if err != nil {
+	var resp reactionResponse
+	if err := c.request(ctx, enums.OpcodeMsgReaction, pl, &resp); err != nil {
 		return nil, err
 	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	reactionInfoData, _ := payload["reactionInfo"].(map[string]any)
-	reactionInfo := &types.ReactionInfo{}
-	if err := utils.FromMap(reactionInfoData, reactionInfo); err != nil {
-		return nil, err
-	}
-	return reactionInfo, nil
+	return resp.info(), nil
 }
 
 // Получает агрегированные реакции для набора сообщений в чате.
@@ -1147,32 +868,19 @@ func (c *MaxClient) GetReactions(ctx context.Context, chatID int64, messageIDs [
 		ChatID:     chatID,
 		MessageIDs: messageIDs,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeMsgGetReactions, payloadMap)
-	if err != nil {
-		return nil, err
+	var resp struct {
+		MessagesReactions map[string]*types.ReactionInfo `json:"messagesReactions"`
 	}
-
-	if err := HandleError(resp); err != nil {
+	if err := c.requestIdempotent(ctx, enums.OpcodeMsgGetReactions, pl, &resp); err != nil {
 		return nil, err
 	}
 
-	payload, _ := resp["payload"].(map[string]any)
-	messagesReactions, _ := payload["messagesReactions"].(map[string]any)
-
-	result := make(map[string]*types.ReactionInfo)
-	for msgID, reactionData := range messagesReactions {
-		reactionMap, _ := reactionData.(map[string]any)
-		reactionInfo := &types.ReactionInfo{}
-		if err := utils.FromMap(reactionMap, reactionInfo); err == nil {
+	result := make(map[string]*types.ReactionInfo, len(resp.MessagesReactions))
+	for msgID, reactionInfo := range resp.MessagesReactions {
+		if reactionInfo != nil {
 			result[msgID] = reactionInfo
 		}
 	}
-
 	return result, nil
 }
 
@@ -1183,27 +891,11 @@ func (c *MaxClient) RemoveReaction(ctx context.Context, chatID int64, messageID
 		ChatID:    chatID,
 		MessageID: messageID,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeMsgCancelReaction, payloadMap)
-	if err != nil {
+	var resp reactionResponse
+	if err := c.request(ctx, enums.OpcodeMsgCancelReaction, pl, &resp); err != nil {
 		return nil, err
 	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	reactionInfoData, _ := payload["reactionInfo"].(map[string]any)
-	reactionInfo := &types.ReactionInfo{}
-	if err := utils.FromMap(reactionInfoData, reactionInfo); err != nil {
-		return nil, err
-	}
-	return reactionInfo, nil
+	return resp.info(), nil
 }
 
 // Получает информацию о пользователе по его ID, используя GetUsers.
@@ -1223,33 +915,16 @@ func (c *MaxClient) GetUsers(ctx context.Context, userIDs []int64) ([]*types.Use
 	pl := payloads.FetchContactsPayload{
 		ContactIDs: userIDs,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
+	var resp struct {
+		Contacts []*types.User `json:"contacts"`
 	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeContactInfo, payloadMap)
-	if err != nil {
+	if err := c.requestIdempotent(ctx, enums.OpcodeContactInfo, pl, &resp); err != nil {
 		return nil, err
 	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, err
+	if resp.Contacts == nil {
+		return []*types.User{}, nil
 	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	contactsData, _ := payload["contacts"].([]interface{})
-
-	users := make([]*types.User, 0, len(contactsData))
-	for _, contactData := range contactsData {
-		contactMap, _ := contactData.(map[string]any)
-		user := &types.User{}
-		if err := utils.FromMap(contactMap, user); err == nil {
-			users = append(users, user)
-		}
-	}
-
-	return users, nil
+	return resp.Contacts, nil
 }
 
 // Ищет пользователя по номеру телефона и возвращает объект User при успехе.
@@ -1257,75 +932,118 @@ func (c *MaxClient) SearchByPhone(ctx context.Context, phone string) (*types.Use
 	pl := payloads.SearchByPhonePayload{
 		Phone: phone,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
+	var resp struct {
+		Contact types.User `json:"contact"`
+	}
+	if err := c.requestIdempotent(ctx, enums.OpcodeContactInfoByPhone, pl, &resp); err != nil {
 		return nil, err
 	}
+	return &resp.Contact, nil
+}
 
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeContactInfoByPhone, payloadMap)
-	if err != nil {
+// Добавляет пользователя в список контактов текущего аккаунта.
+func (c *MaxClient) AddContact(ctx context.Context, contactID int64) (*types.Contact, error) {
+	pl := payloads.ContactActionPayload{
+		ContactID: contactID,
+		Action:    enums.ContactActionAdd,
+	}
+	var resp struct {
+		Contact types.Contact `json:"contact"`
+	}
+	if err := c.request(ctx, enums.OpcodeContactUpdate, pl, &resp); err != nil {
 		return nil, err
 	}
+	return &resp.Contact, nil
+}
 
-	if err := HandleError(resp); err != nil {
-		return nil, err
+// Удаляет пользователя из списка контактов текущего аккаунта.
+func (c *MaxClient) RemoveContact(ctx context.Context, contactID int64) error {
+	pl := payloads.ContactActionPayload{
+		ContactID: contactID,
+		Action:    enums.ContactActionRemove,
 	}
+	return c.request(ctx, enums.OpcodeContactUpdate, pl, nil)
+}
 
-	payload, _ := resp["payload"].(map[string]any)
-	contactData, _ := payload["contact"].(map[string]any)
-	user := &types.User{}
-	if err := utils.FromMap(contactData, user); err != nil {
+// Возвращает адресную книгу текущего аккаунта.
+func (c *MaxClient) ListContacts(ctx context.Context) ([]*types.Contact, error) {
+	pl := payloads.ListContactsPayload{}
+
+	var resp contactsResponse
+	if err := c.requestIdempotent(ctx, enums.OpcodeContactList, pl, &resp); err != nil {
 		return nil, err
 	}
-	return user, nil
+	return resp.list(), nil
 }
 
-// Добавляет пользователя в список контактов текущего аккаунта.
-func (c *MaxClient) AddContact(ctx context.Context, contactID int64) (*types.Contact, error) {
-	pl := payloads.ContactActionPayload{
+// Изменяет локальное имя контакта в адресной книге текущего аккаунта.
+func (c *MaxClient) UpdateContact(ctx context.Context, contactID int64, firstName string, lastName *string) (*types.Contact, error) {
+	pl := payloads.UpdateContactPayload{
 		ContactID: contactID,
-		Action:    enums.ContactActionAdd,
+		Action:    enums.ContactActionUpdate,
+		FirstName: firstName,
+		LastName:  lastName,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
+
+	var resp struct {
+		Contact types.Contact `json:"contact"`
+	}
+	if err := c.request(ctx, enums.OpcodeContactUpdate, pl, &resp); err != nil {
 		return nil, err
 	}
+	return &resp.Contact, nil
+}
+
+// Ищет контакты по имени или номеру телефона в адресной книге текущего аккаунта.
+func (c *MaxClient) SearchContacts(ctx context.Context, query string) ([]*types.Contact, error) {
+	pl := payloads.SearchContactsPayload{
+		Query: query,
+	}
 
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeContactUpdate, payloadMap)
-	if err != nil {
+	var resp contactsResponse
+	if err := c.requestIdempotent(ctx, enums.OpcodeContactSearch, pl, &resp); err != nil {
 		return nil, err
 	}
+	return resp.list(), nil
+}
 
-	if err := HandleError(resp); err != nil {
-		return nil, err
+// Возвращает контакты, общие у текущего аккаунта и пользователя userID.
+func (c *MaxClient) MutualContacts(ctx context.Context, userID int64) ([]*types.Contact, error) {
+	pl := payloads.MutualContactsPayload{
+		UserID: userID,
 	}
 
-	payload, _ := resp["payload"].(map[string]any)
-	contactData, _ := payload["contact"].(map[string]any)
-	contact := &types.Contact{}
-	if err := utils.FromMap(contactData, contact); err != nil {
+	var resp contactsResponse
+	if err := c.requestIdempotent(ctx, enums.OpcodeContactMutual, pl, &resp); err != nil {
 		return nil, err
 	}
-	return contact, nil
+	return resp.list(), nil
 }
 
-// Удаляет пользователя из списка контактов текущего аккаунта.
-func (c *MaxClient) RemoveContact(ctx context.Context, contactID int64) error {
-	pl := payloads.ContactActionPayload{
+// Возвращает фотографии профиля контакта.
+func (c *MaxClient) ContactPhotos(ctx context.Context, contactID int64) ([]*types.ContactPhoto, error) {
+	pl := payloads.ContactPhotosPayload{
 		ContactID: contactID,
-		Action:    enums.ContactActionRemove,
-	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return err
 	}
 
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeContactUpdate, payloadMap)
-	if err != nil {
-		return err
+	var resp struct {
+		Photos []*types.ContactPhoto `json:"photos"`
 	}
+	if err := c.requestIdempotent(ctx, enums.OpcodeContactPhotos, pl, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Photos == nil {
+		return []*types.ContactPhoto{}, nil
+	}
+	return resp.Photos, nil
+}
 
-	return HandleError(resp)
+// Задаёт порядок контактов в адресной книге текущего аккаунта.
+func (c *MaxClient) SortContacts(ctx context.Context, contactIDs []int64) error {
+	pl := payloads.SortContactsPayload{
+		ContactIDs: contactIDs,
+	}
+	return c.request(ctx, enums.OpcodeContactSort, pl, nil)
 }
 
 // Вычисляет детерминированный идентификатор диалога между двумя пользователями.
@@ -1335,46 +1053,39 @@ func (c *MaxClient) GetChatId(firstUserID int64, secondUserID int64) int64 {
 
 // Получает список всех активных сессий аккаунта (устройства, платформы и т.п.).
 func (c *MaxClient) GetSessions(ctx context.Context) ([]*types.Session, error) {
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeSessionsInfo, map[string]any{})
-	if err != nil {
+	var resp struct {
+		Sessions []*types.Session `json:"sessions"`
+	}
+	if err := c.requestIdempotent(ctx, enums.OpcodeSessionsInfo, nil, &resp); err != nil {
 		return nil, err
 	}
+	if resp.Sessions == nil {
+		return []*types.Session{}, nil
+	}
+	return resp.Sessions, nil
+}
 
-	if err := HandleError(resp); err != nil {
+// Получает информацию о канале по его публичному имени.
+// Возвращает ResponseError, если имя указывает не на канал.
+func (c *MaxClient) ResolveChannel(ctx context.Context, name string) (*types.Chat, error) {
+	info, err := c.ResolveLink(ctx, fmt.Sprintf("https://max.ru/%s", name))
+	if err != nil {
 		return nil, err
 	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	sessionsData, _ := payload["sessions"].([]interface{})
-
-	sessions := make([]*types.Session, 0, len(sessionsData))
-	for _, sessionData := range sessionsData {
-		sessionMap, _ := sessionData.(map[string]any)
-		session := &types.Session{}
-		if err := utils.FromMap(sessionMap, session); err == nil {
-			sessions = append(sessions, session)
-		}
+	if info.Type != enums.LinkTypeChannel {
+		return nil, &ResponseError{Message: fmt.Sprintf("link %q does not point to a channel", info.Link)}
 	}
-
-	return sessions, nil
+	return info.Chat, nil
 }
 
-// Пытается получить информацию о канале по его публичному имени.
+// Запрашивает информацию о канале по его публичному имени, не возвращая её.
+//
+// Deprecated: используйте ResolveChannel, который возвращает найденный канал.
 func (c *MaxClient) ResolveChannelByName(ctx context.Context, name string) error {
 	pl := payloads.ResolveLinkPayload{
 		Link: fmt.Sprintf("https://max.ru/%s", name),
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeLinkInfo, payloadMap)
-	if err != nil {
-		return err
-	}
-
-	return HandleError(resp)
+	return c.requestIdempotent(ctx, enums.OpcodeLinkInfo, pl, nil)
 }
 
 // Присоединяется к публичному каналу по полной ссылке-просмотру.
@@ -1382,17 +1093,7 @@ func (c *MaxClient) JoinChannel(ctx context.Context, link string) error {
 	pl := payloads.JoinChatPayload{
 		Link: link,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeChatJoin, payloadMap)
-	if err != nil {
-		return err
-	}
-
-	return HandleError(resp)
+	return c.request(ctx, enums.OpcodeChatJoin, pl, nil)
 }
 
 // Загружает участников канала или группы с поддержкой маркера пагинации.
@@ -1411,39 +1112,12 @@ func (c *MaxClient) LoadMembers(ctx context.Context, chatID int64, marker *int,
 		ChatID: chatID,
 		Count:  count,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeChatMembers, payloadMap)
-	if err != nil {
+	var resp membersResponse
+	if err := c.requestIdempotent(ctx, enums.OpcodeChatMembers, pl, &resp); err != nil {
 		return nil, nil, err
 	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, nil, err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	membersData, _ := payload["members"].([]interface{})
-	markerVal, _ := payload["marker"].(float64)
-
-	members := make([]*types.Member, 0, len(membersData))
-	for _, memberData := range membersData {
-		memberMap, _ := memberData.(map[string]any)
-		member := &types.Member{}
-		if err := utils.FromMap(memberMap, member); err == nil {
-			members = append(members, member)
-		}
-	}
-
-	var nextMarker *int
-	if markerVal > 0 {
-		m := int(markerVal)
-		nextMarker = &m
-	}
-
+	members, nextMarker := resp.page()
+	c.trackMembersPresence(ctx, members)
 	return members, nextMarker, nil
 }
 
@@ -1454,39 +1128,12 @@ func (c *MaxClient) FindMembers(ctx context.Context, chatID int64, query string)
 		Query:  query,
 		ChatID: chatID,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
+	var resp membersResponse
+	if err := c.requestIdempotent(ctx, enums.OpcodeChatMembers, pl, &resp); err != nil {
 		return nil, nil, err
 	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeChatMembers, payloadMap)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, nil, err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	membersData, _ := payload["members"].([]interface{})
-	markerVal, _ := payload["marker"].(float64)
-
-	members := make([]*types.Member, 0, len(membersData))
-	for _, memberData := range membersData {
-		memberMap, _ := memberData.(map[string]any)
-		member := &types.Member{}
-		if err := utils.FromMap(memberMap, member); err == nil {
-			members = append(members, member)
-		}
-	}
-
-	var nextMarker *int
-	if markerVal > 0 {
-		m := int(markerVal)
-		nextMarker = &m
-	}
-
+	members, nextMarker := resp.page()
+	c.trackMembersPresence(ctx, members)
 	return members, nextMarker, nil
 }
 
@@ -1496,33 +1143,21 @@ func (c *MaxClient) GetChats(ctx context.Context, chatIDs []int64) ([]*types.Cha
 	pl := payloads.GetChatInfoPayload{
 		ChatIDs: chatIDs,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeChatInfo, payloadMap)
-	if err != nil {
-		return nil, err
+	var resp struct {
+		Chats []*types.Chat `json:"chats"`
 	}
-
-	if err := HandleError(resp); err != nil {
+	if err := c.requestIdempotent(ctx, enums.OpcodeChatInfo, pl, &resp); err != nil {
 		return nil, err
 	}
 
-	payload, _ := resp["payload"].(map[string]any)
-	chatsData, _ := payload["chats"].([]interface{})
-
-	chats := make([]*types.Chat, 0, len(chatsData))
-	for _, chatData := range chatsData {
-		chatMap, _ := chatData.(map[string]any)
-		chat := &types.Chat{}
-		if err := utils.FromMap(chatMap, chat); err == nil {
-			chats = append(chats, chat)
-			c.updateChatCache(chat)
+	chats := make([]*types.Chat, 0, len(resp.Chats))
+	for _, chat := range resp.Chats {
+		if chat == nil {
+			continue
 		}
+		chats = append(chats, chat)
+		c.updateChatCache(chat)
 	}
-
 	return chats, nil
 }
 
@@ -1571,32 +1206,27 @@ func (c *MaxClient) CreateGroup(ctx context.Context, name string, participantIDs
 		},
 		Notify: notify,
 	}
-	payloadMap, err := utils.ToMap(pl)
+	frame, err := c.roundTrip(ctx, enums.OpcodeMsgSend, pl, true)
 	if err != nil {
 		return nil, nil, err
 	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeMsgSend, payloadMap)
-	if err != nil {
+	if err := frame.Err(); err != nil {
 		return nil, nil, err
 	}
 
-	if err := HandleError(resp); err != nil {
-		return nil, nil, err
+	// Ответ содержит служебное сообщение о создании и вложенный объект чата.
+	var chatResp struct {
+		Chat types.Chat `json:"chat"`
 	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	chatData, _ := payload["chat"].(map[string]any)
-	chat := &types.Chat{}
-	if err := utils.FromMap(chatData, chat); err != nil {
+	if err := frame.Decode(&chatResp); err != nil {
 		return nil, nil, err
 	}
-
 	msg := &types.Message{}
-	if err := utils.FromMap(payload, msg); err != nil {
+	if err := frame.Decode(msg); err != nil {
 		return nil, nil, err
 	}
 
+	chat := &chatResp.Chat
 	c.updateChatCache(chat)
 	return chat, msg, nil
 }
@@ -1610,28 +1240,7 @@ func (c *MaxClient) InviteUsersToGroup(ctx context.Context, chatID int64, userID
 		ShowHistory: showHistory,
 		Operation:   constants.OperationAdd,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeChatMembersUpdate, payloadMap)
-	if err != nil {
-		return err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	chatData, _ := payload["chat"].(map[string]any)
-	chat := &types.Chat{}
-	if err := utils.FromMap(chatData, chat); err == nil {
-		c.updateChatCache(chat)
-	}
-
-	return nil
+	return c.requestChatUpdate(ctx, enums.OpcodeChatMembersUpdate, pl)
 }
 
 // Удаляет пользователей из группы и при необходимости очищает их историю сообщений.
@@ -1642,28 +1251,7 @@ func (c *MaxClient) RemoveUsersFromGroup(ctx context.Context, chatID int64, user
 		Operation:      constants.OperationRemove,
 		CleanMsgPeriod: cleanMsgPeriod,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeChatMembersUpdate, payloadMap)
-	if err != nil {
-		return err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	chatData, _ := payload["chat"].(map[string]any)
-	chat := &types.Chat{}
-	if err := utils.FromMap(chatData, chat); err == nil {
-		c.updateChatCache(chat)
-	}
-
-	return nil
+	return c.requestChatUpdate(ctx, enums.OpcodeChatMembersUpdate, pl)
 }
 
 // Изменяет административные настройки группы
@@ -1679,28 +1267,7 @@ func (c *MaxClient) ChangeGroupSettings(ctx context.Context, chatID int64, allCa
 			MembersCanSeePrivateLink:    membersCanSeePrivateLink,
 		},
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeChatUpdate, payloadMap)
-	if err != nil {
-		return err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	chatData, _ := payload["chat"].(map[string]any)
-	chat := &types.Chat{}
-	if err := utils.FromMap(chatData, chat); err == nil {
-		c.updateChatCache(chat)
-	}
-
-	return nil
+	return c.requestChatUpdate(ctx, enums.OpcodeChatUpdate, pl)
 }
 
 // Изменяет основные поля профиля группы: название и описание.
@@ -1710,28 +1277,7 @@ func (c *MaxClient) ChangeGroupProfile(ctx context.Context, chatID int64, name *
 		Theme:       name,
 		Description: description,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeChatUpdate, payloadMap)
-	if err != nil {
-		return err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	chatData, _ := payload["chat"].(map[string]any)
-	chat := &types.Chat{}
-	if err := utils.FromMap(chatData, chat); err == nil {
-		c.updateChatCache(chat)
-	}
-
-	return nil
+	return c.requestChatUpdate(ctx, enums.OpcodeChatUpdate, pl)
 }
 
 // Присоединяется к группе по ссылке приглашения формата .../join/<token>
@@ -1746,29 +1292,16 @@ func (c *MaxClient) JoinGroup(ctx context.Context, link string) (*types.Chat, er
 	pl := payloads.JoinChatPayload{
 		Link: proceedLink,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeChatJoin, payloadMap)
-	if err != nil {
+	var resp chatResponse
+	if err := c.request(ctx, enums.OpcodeChatJoin, pl, &resp); err != nil {
 		return nil, err
 	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, err
+	if resp.Chat == nil {
+		return nil, &ResponseStructureError{Message: "chat data missing in response"}
 	}
 
-	payload, _ := resp["payload"].(map[string]any)
-	chatData, _ := payload["chat"].(map[string]any)
-	chat := &types.Chat{}
-	if err := utils.FromMap(chatData, chat); err != nil {
-		return nil, err
-	}
-
-	c.updateChatCache(chat)
-	return chat, nil
+	c.updateChatCache(resp.Chat)
+	return resp.Chat, nil
 }
 
 // Отзывает текущую приватную ссылку приглашения и создаёт новую для указанной группы.
@@ -1777,29 +1310,16 @@ func (c *MaxClient) ReworkInviteLink(ctx context.Context, chatID int64) (*types.
 		RevokePrivateLink: true,
 		ChatID:            chatID,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeChatUpdate, payloadMap)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := HandleError(resp); err != nil {
+	var resp chatResponse
+	if err := c.request(ctx, enums.OpcodeChatUpdate, pl, &resp); err != nil {
 		return nil, err
 	}
-
-	payload, _ := resp["payload"].(map[string]any)
-	chatData, _ := payload["chat"].(map[string]any)
-	chat := &types.Chat{}
-	if err := utils.FromMap(chatData, chat); err != nil {
+	if resp.Chat == nil {
 		return nil, fmt.Errorf("chat data missing in response")
 	}
 
-	c.updateChatCache(chat)
-	return chat, nil
+	c.updateChatCache(resp.Chat)
+	return resp.Chat, nil
 }
 
 // Изменяет профиль текущего пользователя (имя, фамилию и статус/описание).
@@ -1809,17 +1329,7 @@ func (c *MaxClient) ChangeProfile(ctx context.Context, firstName string, lastNam
 		LastName:    lastName,
 		Description: description,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeProfile, payloadMap)
-	if err != nil {
-		return err
-	}
-
-	return HandleError(resp)
+	return c.request(ctx, enums.OpcodeProfile, pl, nil)
 }
 
 // Создаёт пользовательскую папку (фильтр) для выбранных чатов
@@ -1831,23 +1341,8 @@ func (c *MaxClient) CreateFolder(ctx context.Context, title string, chatInclude
 		Include: chatInclude,
 		Filters: folderFilters,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeFoldersUpdate, payloadMap)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
 	folderUpdate := &types.FolderUpdate{}
-	if err := utils.FromMap(payload, folderUpdate); err != nil {
+	if err := c.request(ctx, enums.OpcodeFoldersUpdate, pl, folderUpdate); err != nil {
 		return nil, err
 	}
 	return folderUpdate, nil
@@ -1858,23 +1353,8 @@ func (c *MaxClient) GetFolders(ctx context.Context, folderSync int) (*types.Fold
 	pl := payloads.GetFolderPayload{
 		FolderSync: folderSync,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeFoldersGet, payloadMap)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
 	folderList := &types.FolderList{}
-	if err := utils.FromMap(payload, folderList); err != nil {
+	if err := c.requestIdempotent(ctx, enums.OpcodeFoldersGet, pl, folderList); err != nil {
 		return nil, err
 	}
 	return folderList, nil
@@ -1889,23 +1369,8 @@ func (c *MaxClient) UpdateFolder(ctx context.Context, folderID string, title str
 		Filters: folderFilters,
 		Options: options,
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeFoldersUpdate, payloadMap)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
 	folderUpdate := &types.FolderUpdate{}
-	if err := utils.FromMap(payload, folderUpdate); err != nil {
+	if err := c.request(ctx, enums.OpcodeFoldersUpdate, pl, folderUpdate); err != nil {
 		return nil, err
 	}
 	return folderUpdate, nil
@@ -1916,23 +1381,8 @@ func (c *MaxClient) DeleteFolder(ctx context.Context, folderID string) (*types.F
 	pl := payloads.DeleteFolderPayload{
 		FolderIDs: []string{folderID},
 	}
-	payloadMap, err := utils.ToMap(pl)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.sendAndWaitResponse(ctx, enums.OpcodeFoldersDelete, payloadMap)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := HandleError(resp); err != nil {
-		return nil, err
-	}
-
-	payload, _ := resp["payload"].(map[string]any)
 	folderUpdate := &types.FolderUpdate{}
-	if err := utils.FromMap(payload, folderUpdate); err != nil {
+	if err := c.request(ctx, enums.OpcodeFoldersDelete, pl, folderUpdate); err != nil {
 		return nil, err
 	}
 	return folderUpdate, nil
@@ -1959,6 +1409,11 @@ func (c *MaxClient) OnReactionChange(handler func(context.Context, string, int64
 	c.onReactionChange = append(c.onReactionChange, handler)
 }
 
+// Регистрирует обработчик уведомлений об изменении контактов в адресной книге.
+func (c *MaxClient) OnContactUpdate(handler func(context.Context, *types.Contact)) {
+	c.onContactUpdate = append(c.onContactUpdate, handler)
+}
+
 // Регистрирует обработчик уведомлений об обновлении чатов.
 func (c *MaxClient) OnChatUpdate(handler func(context.Context, *types.Chat)) {
 	c.onChatUpdate = append(c.onChatUpdate, handler)
@@ -1,11 +1,26 @@
 package gomax
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/fresh-milkshake/gomax/enums"
+	"github.com/fresh-milkshake/gomax/files"
 	"github.com/fresh-milkshake/gomax/logger"
 	"github.com/fresh-milkshake/gomax/mockserver"
+	"github.com/fresh-milkshake/gomax/types"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -132,6 +147,92 @@ func TestSendMessage_NoNotify(t *testing.T) {
 	assert.False(t, receivedNotify)
 }
 
+// TestSendTyping проверяет отправку индикатора набора текста.
+func TestSendTyping(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	var receivedChatID any
+	server.SetHandler(mockserver.OpcodeMsgTyping, func(msg map[string]any) map[string]any {
+		payload := msg["payload"].(map[string]any)
+		receivedChatID = payload["chatId"]
+		return mockserver.TypingResponse(0)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	err := client.SendTyping(ctx, testChatID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(testChatID), receivedChatID)
+}
+
+// TestKeepTyping_StopsOnSendMessage проверяет, что KeepTyping отправляет индикатор
+// и останавливается после отправки сообщения в тот же чат.
+func TestKeepTyping_StopsOnSendMessage(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	typingSent := make(chan struct{}, 16)
+	server.SetHandler(mockserver.OpcodeMsgTyping, func(msg map[string]any) map[string]any {
+		typingSent <- struct{}{}
+		return mockserver.TypingResponse(0)
+	})
+	server.SetHandler(mockserver.OpcodeMsgSend, func(msg map[string]any) map[string]any {
+		return mockserver.SendMessageResponse(0, testChatID, testMessageID, "done")
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	stop := client.KeepTyping(ctx, testChatID)
+	defer stop()
+
+	select {
+	case <-typingSent:
+	case <-time.After(2 * time.Second):
+		t.Fatal("typing indicator was not sent")
+	}
+
+	_, err := client.SendMessage(ctx, "done", testChatID, true, nil, nil, nil)
+	require.NoError(t, err)
+
+	assert.True(t, mockserver.WaitForCondition(t, time.Second, func() bool {
+		client.typingMu.Lock()
+		defer client.typingMu.Unlock()
+		return len(client.typingKeepers) == 0
+	}))
+}
+
+// TestMarkRead проверяет отметку о прочтении и сброс счётчика непрочитанных в кэше.
+func TestMarkRead(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	chat := mockserver.TestChat(testChatID, "CHAT", "Support")
+	chat["newMessages"] = 3
+	server.SetHandler(mockserver.OpcodeLogin, func(msg map[string]any) map[string]any {
+		return mockserver.SyncResponse(0, nil, []map[string]any{chat})
+	})
+
+	var receivedPayload map[string]any
+	server.SetHandler(mockserver.OpcodeChatMark, func(msg map[string]any) map[string]any {
+		receivedPayload = msg["payload"].(map[string]any)
+		return mockserver.ChatMarkResponse(0)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	require.Len(t, client.ChatList(), 1)
+	assert.Equal(t, 3, client.ChatList()[0].NewMessages)
+
+	err := client.MarkRead(ctx, testChatID, testMessageID)
+	require.NoError(t, err)
+	assert.Equal(t, "READ_MESSAGE", receivedPayload["type"])
+	assert.Equal(t, float64(testChatID), receivedPayload["chatId"])
+	assert.Equal(t, "67890", receivedPayload["messageId"])
+	assert.NotZero(t, receivedPayload["mark"])
+	assert.Equal(t, 0, client.ChatList()[0].NewMessages)
+}
+
 // TestEditMessage_Text проверяет редактирование текста сообщения.
 func TestEditMessage_Text(t *testing.T) {
 	server := mockserver.StartMockServerWithDefaults(t)
@@ -521,6 +622,200 @@ func TestRemoveContact(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// TestListContacts проверяет получение адресной книги.
+func TestListContacts(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	server.SetHandler(mockserver.OpcodeContactList, func(msg map[string]any) map[string]any {
+		return mockserver.ContactsResponse(0, mockserver.OpcodeContactList, []map[string]any{
+			mockserver.TestContact(1, "Ivan", "Petrov", "+79990000001"),
+			mockserver.TestContact(2, "Anna", "Sidorova", "+79990000002"),
+		})
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	contacts, err := client.ListContacts(ctx)
+	require.NoError(t, err)
+	require.Len(t, contacts, 2)
+	assert.Equal(t, int64(1), contacts[0].ID)
+	assert.Equal(t, int64(2), contacts[1].ID)
+}
+
+// TestUpdateContact проверяет изменение локального имени контакта.
+func TestUpdateContact(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	var receivedPayload map[string]any
+	server.SetHandler(mockserver.OpcodeContactUpdate, func(msg map[string]any) map[string]any {
+		receivedPayload = msg["payload"].(map[string]any)
+		contact := mockserver.TestContact(testUserID, "Renamed", "Contact", "+79994444444")
+		return mockserver.AddContactResponse(0, contact)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	lastName := "Contact"
+	contact, err := client.UpdateContact(ctx, testUserID, "Renamed", &lastName)
+	require.NoError(t, err)
+	assert.Equal(t, testUserID, contact.ID)
+	assert.Equal(t, "UPDATE", receivedPayload["action"])
+	assert.Equal(t, "Renamed", receivedPayload["firstName"])
+	assert.Equal(t, "Contact", receivedPayload["lastName"])
+}
+
+// TestSearchContacts проверяет поиск по адресной книге.
+func TestSearchContacts(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	var receivedQuery any
+	server.SetHandler(mockserver.OpcodeContactSearch, func(msg map[string]any) map[string]any {
+		receivedQuery = msg["payload"].(map[string]any)["query"]
+		return mockserver.ContactsResponse(0, mockserver.OpcodeContactSearch, []map[string]any{
+			mockserver.TestContact(1, "Ivan", "Petrov", "+79990000001"),
+		})
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	contacts, err := client.SearchContacts(ctx, "Ivan")
+	require.NoError(t, err)
+	assert.Len(t, contacts, 1)
+	assert.Equal(t, "Ivan", receivedQuery)
+}
+
+// TestMutualContacts проверяет получение общих контактов.
+func TestMutualContacts(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	server.SetHandler(mockserver.OpcodeContactMutual, func(msg map[string]any) map[string]any {
+		return mockserver.ContactsResponse(0, mockserver.OpcodeContactMutual, nil)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	contacts, err := client.MutualContacts(ctx, testUserID)
+	require.NoError(t, err)
+	assert.NotNil(t, contacts)
+	assert.Empty(t, contacts)
+}
+
+// TestContactPhotos проверяет получение фотографий контакта.
+func TestContactPhotos(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	server.SetHandler(mockserver.OpcodeContactPhotos, func(msg map[string]any) map[string]any {
+		return mockserver.ContactPhotosResponse(0, []map[string]any{
+			{"photoId": 10, "baseUrl": "https://example.com/10.jpg", "time": 1000},
+		})
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	photos, err := client.ContactPhotos(ctx, testUserID)
+	require.NoError(t, err)
+	require.Len(t, photos, 1)
+	assert.Equal(t, int64(10), photos[0].PhotoID)
+	assert.Equal(t, "https://example.com/10.jpg", photos[0].BaseURL)
+}
+
+// TestSortContacts проверяет изменение порядка контактов.
+func TestSortContacts(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	var receivedIDs any
+	server.SetHandler(mockserver.OpcodeContactSort, func(msg map[string]any) map[string]any {
+		receivedIDs = msg["payload"].(map[string]any)["contactIds"]
+		return mockserver.ContactSortResponse(0)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	err := client.SortContacts(ctx, []int64{2, 1})
+	require.NoError(t, err)
+	assert.Equal(t, []any{float64(2), float64(1)}, receivedIDs)
+}
+
+// TestImportContacts проверяет массовый импорт номеров: отчёт по каждому номеру,
+// повтор после RateLimitError, дедупликацию и вызовы Progress.
+func TestImportContacts(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	var mu sync.Mutex
+	searches := map[string]int{}
+	server.SetHandler(mockserver.OpcodeContactInfoByPhone, func(msg map[string]any) map[string]any {
+		phone := msg["payload"].(map[string]any)["phone"].(string)
+		mu.Lock()
+		searches[phone]++
+		attempt := searches[phone]
+		mu.Unlock()
+
+		switch phone {
+		case "+79990000002":
+			return mockserver.ErrorResponse(0, mockserver.OpcodeContactInfoByPhone, "contact.not.found", "not found")
+		case "+79990000003":
+			if attempt == 1 {
+				return mockserver.ErrorResponse(0, mockserver.OpcodeContactInfoByPhone, "too.many.requests", "slow down")
+			}
+			return mockserver.SearchByPhoneResponse(0, mockserver.TestContact(3, "Third", "User", phone))
+		default:
+			return mockserver.SearchByPhoneResponse(0, mockserver.TestContact(1, "First", "User", phone))
+		}
+	})
+	server.SetHandler(mockserver.OpcodeContactUpdate, func(msg map[string]any) map[string]any {
+		id := int64(msg["payload"].(map[string]any)["contactId"].(float64))
+		return mockserver.AddContactResponse(0, mockserver.TestContact(id, "Added", "User", ""))
+	})
+
+	client, err := NewMaxClient(ClientConfig{
+		Phone:             testPhone,
+		URI:               server.URL(),
+		WorkDir:           t.TempDir(),
+		Token:             testAuthToken,
+		RetryInitialDelay: 20 * time.Millisecond,
+		Logger:            logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	ctx := mockserver.TestContext(t)
+	require.NoError(t, client.Start(ctx))
+
+	phones := []string{"+79990000001", "+79990000002", "+79990000003", "not-a-phone", " +79990000001 "}
+	var progress []int
+	results, err := client.ImportContacts(ctx, phones, ImportOptions{
+		Add:      true,
+		Interval: time.Millisecond,
+		Progress: func(done, total int, result ImportResult) {
+			assert.Equal(t, len(phones), total)
+			progress = append(progress, done)
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, len(phones))
+
+	assert.Equal(t, ImportAdded, results[0].Status)
+	require.NotNil(t, results[0].Contact)
+	assert.Equal(t, int64(1), results[0].Contact.ID)
+	assert.Equal(t, ImportNotRegistered, results[1].Status)
+	assert.Equal(t, ImportAdded, results[2].Status)
+	assert.Equal(t, int64(3), results[2].User.ID)
+	assert.Equal(t, ImportInvalidPhone, results[3].Status)
+	assert.Equal(t, ImportAdded, results[4].Status)
+	assert.Equal(t, "+79990000001", results[4].Phone)
+
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, progress)
+	mu.Lock()
+	assert.Equal(t, 1, searches["+79990000001"])
+	assert.Equal(t, 2, searches["+79990000003"])
+	mu.Unlock()
+}
+
 // TestFetchHistory проверяет загрузку истории сообщений.
 func TestFetchHistory(t *testing.T) {
 	server := mockserver.StartMockServerWithDefaults(t)
@@ -542,6 +837,193 @@ func TestFetchHistory(t *testing.T) {
 	assert.Len(t, messages, 3)
 }
 
+// TestFetchHistory_PreservesInt64IDs проверяет, что крупные идентификаторы
+// не теряют точность при декодировании типизированного кадра.
+func TestFetchHistory_PreservesInt64IDs(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	const bigID int64 = 1<<62 + 1
+	const bigChatID int64 = -(1<<61 + 3)
+	server.SetHandler(49, func(msg map[string]any) map[string]any {
+		messages := []map[string]any{
+			mockserver.TestMessage(bigID, bigChatID, bigID-1, "Big IDs"),
+		}
+		return mockserver.FetchHistoryResponse(0, messages)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	messages, err := client.FetchHistory(ctx, bigChatID, nil, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, bigID, messages[0].ID)
+	require.NotNil(t, messages[0].ChatID)
+	assert.Equal(t, bigChatID, *messages[0].ChatID)
+}
+
+// TestFetchHistory_SkipsUndecodable проверяет, что нераспознанное сообщение
+// пропускается, а остальные сообщения страницы возвращаются.
+func TestFetchHistory_SkipsUndecodable(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	server.SetHandler(mockserver.OpcodeChatHistory, func(msg map[string]any) map[string]any {
+		broken := mockserver.TestMessage(2, testChatID, testUserID, "Broken")
+		broken["time"] = "not-a-timestamp"
+		messages := []map[string]any{
+			mockserver.TestMessage(1, testChatID, testUserID, "Message 1"),
+			broken,
+			mockserver.TestMessage(3, testChatID, testUserID, "Message 3"),
+		}
+		return mockserver.FetchHistoryResponse(int(msg["seq"].(float64)), messages)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	messages, err := client.FetchHistory(ctx, testChatID, nil, 10, 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, int64(1), messages[0].ID)
+	assert.Equal(t, int64(3), messages[1].ID)
+}
+
+// serveHistory эмулирует CHAT_HISTORY поверх сообщений с заданными временами:
+// id сообщения — его индекс + 1, окно включает границу from, как на реальном сервере.
+// Возвращает счётчик запросов.
+func serveHistory(server *mockserver.MockServer, times []int64) *int {
+	var mu sync.Mutex
+	requests := 0
+	server.SetHandler(mockserver.OpcodeChatHistory, func(msg map[string]any) map[string]any {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+
+		payload, _ := msg["payload"].(map[string]any)
+		from := int64(payload["from"].(float64))
+		forward := int(payload["forward"].(float64))
+		backward := int(payload["backward"].(float64))
+
+		var window []map[string]any
+		if forward > 0 {
+			for i, ts := range times {
+				if ts >= from && len(window) < forward {
+					m := mockserver.TestMessage(int64(i+1), testChatID, testUserID, "")
+					m["time"] = ts
+					window = append(window, m)
+				}
+			}
+		} else {
+			for i := len(times) - 1; i >= 0; i-- {
+				if times[i] <= from && len(window) < backward {
+					m := mockserver.TestMessage(int64(i+1), testChatID, testUserID, "")
+					m["time"] = times[i]
+					window = append([]map[string]any{m}, window...)
+				}
+			}
+		}
+		return mockserver.FetchHistoryResponse(0, window)
+	})
+	return &requests
+}
+
+// TestHistoryIterator_Backward проверяет полный обход истории от новых к старым
+// без дублей на границах окон и с одинаковыми временами сообщений.
+func TestHistoryIterator_Backward(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+	serveHistory(server, []int64{100, 200, 300, 300, 400, 500, 500, 600})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	it := client.HistoryIterator(testChatID, HistoryBackward, 3, HistoryOptions{})
+	var ids []int64
+	for it.Next(ctx) {
+		ids = append(ids, it.Value().ID)
+		require.Less(t, len(ids), 20, "iterator did not stop")
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []int64{8, 7, 6, 5, 4, 3, 2, 1}, ids)
+}
+
+// TestHistoryIterator_Forward проверяет обход от старых к новым с границами времени и лимитом.
+func TestHistoryIterator_Forward(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+	serveHistory(server, []int64{100, 200, 300, 300, 400, 500, 500, 600})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	it := client.HistoryIterator(testChatID, HistoryForward, 2, HistoryOptions{
+		Since: time.UnixMilli(200),
+		Until: time.UnixMilli(500),
+	})
+	var ids []int64
+	for it.Next(ctx) {
+		ids = append(ids, it.Value().ID)
+		require.Less(t, len(ids), 20, "iterator did not stop")
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []int64{2, 3, 4, 5, 6, 7}, ids)
+
+	it = client.HistoryIterator(testChatID, HistoryBackward, 2, HistoryOptions{Limit: 3})
+	ids = nil
+	for it.Next(ctx) {
+		ids = append(ids, it.Value().ID)
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []int64{8, 7, 6}, ids)
+}
+
+// TestHistoryIterator_SameTimestamp проверяет, что итератор отдаёт все сообщения
+// миллисекунды, в которую попало больше pageSize сообщений, и не зацикливается.
+func TestHistoryIterator_SameTimestamp(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+	requests := serveHistory(server, []int64{100, 500, 500, 500, 500, 500, 900})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	collect := func(it *Iterator[*types.Message]) []int64 {
+		var ids []int64
+		for it.Next(ctx) {
+			ids = append(ids, it.Value().ID)
+			require.Less(t, len(ids), 20, "iterator did not stop")
+		}
+		require.NoError(t, it.Err())
+		return ids
+	}
+
+	ids := collect(client.HistoryIterator(testChatID, HistoryBackward, 2, HistoryOptions{}))
+	assert.Equal(t, []int64{7, 6, 5, 4, 3, 2, 1}, ids)
+	assert.Less(t, *requests, 10)
+
+	ids = collect(client.HistoryIterator(testChatID, HistoryForward, 2, HistoryOptions{}))
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7}, ids)
+}
+
+// TestFrame_Err проверяет разбор ошибки протокола из payload кадра.
+func TestFrame_Err(t *testing.T) {
+	frame, err := newFrame(1, 0, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(frame.Payload))
+	assert.NoError(t, frame.Err())
+
+	frame.Payload = []byte(`{"error":"too.many.requests","message":"slow down"}`)
+	var rateErr *RateLimitError
+	require.ErrorAs(t, frame.Err(), &rateErr)
+	assert.Equal(t, "slow down", rateErr.Err.Message)
+
+	frame.Payload = []byte(`{"error":"chat.not.found"}`)
+	var maxErr *Error
+	require.ErrorAs(t, frame.Err(), &maxErr)
+	assert.Equal(t, "chat.not.found", maxErr.Code)
+
+	frame.Payload = nil
+	var respErr *ResponseError
+	assert.ErrorAs(t, frame.Err(), &respErr)
+}
+
 // TestPinMessage проверяет закрепление сообщения.
 func TestPinMessage(t *testing.T) {
 	server := mockserver.StartMockServerWithDefaults(t)
@@ -642,6 +1124,98 @@ func TestFindMembers(t *testing.T) {
 	assert.Len(t, members, 1)
 }
 
+// TestSearchMessages проверяет поиск сообщений в одном чате и проставление chatId из результата.
+func TestSearchMessages(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	var got map[string]any
+	server.SetHandler(mockserver.OpcodeMsgSearch, func(msg map[string]any) map[string]any {
+		got, _ = msg["payload"].(map[string]any)
+		messages := []map[string]any{
+			mockserver.TestMessage(testMessageID, testChatID, testUserID, "квартальный отчёт"),
+		}
+		return mockserver.SearchMessagesResponse(0, messages, 0)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	chatID := int64(testChatID)
+	messages, marker, err := client.SearchMessages(ctx, "отчёт", &chatID, Pagination{})
+	require.NoError(t, err)
+	assert.Nil(t, marker)
+	require.Len(t, messages, 1)
+	assert.Equal(t, int64(testMessageID), messages[0].ID)
+	require.NotNil(t, messages[0].ChatID)
+	assert.Equal(t, int64(testChatID), *messages[0].ChatID)
+
+	assert.Equal(t, "отчёт", got["query"])
+	assert.Equal(t, float64(testChatID), got["chatId"])
+	assert.Equal(t, float64(30), got["count"])
+	assert.NotContains(t, got, "marker")
+}
+
+// TestSearchMessagesIter проверяет обход нескольких страниц поиска через итератор.
+func TestSearchMessagesIter(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	server.SetHandler(mockserver.OpcodeMsgSearch, func(msg map[string]any) map[string]any {
+		payload, _ := msg["payload"].(map[string]any)
+		if _, ok := payload["chatId"]; ok {
+			return mockserver.ErrorResponse(0, mockserver.OpcodeMsgSearch, "unexpected.chat", "global search expected")
+		}
+		if marker, _ := payload["marker"].(float64); marker == 2 {
+			return mockserver.SearchMessagesResponse(0, []map[string]any{
+				mockserver.TestMessage(3, testChatID+1, testUserID, "hello again"),
+			}, 0)
+		}
+		return mockserver.SearchMessagesResponse(0, []map[string]any{
+			mockserver.TestMessage(1, testChatID, testUserID, "hello"),
+			mockserver.TestMessage(2, testChatID, testUserID, "hello there"),
+		}, 2)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	it := client.SearchMessagesIter("hello", nil, 2)
+	var ids []int64
+	for it.Next(ctx) {
+		ids = append(ids, it.Value().ID)
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+	assert.Nil(t, it.Marker())
+}
+
+// TestSearchChats проверяет поиск чатов и ошибку итератора при ошибке сервера.
+func TestSearchChats(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	server.SetHandler(mockserver.OpcodeChatSearch, func(msg map[string]any) map[string]any {
+		payload, _ := msg["payload"].(map[string]any)
+		if payload["query"] == "broken" {
+			return mockserver.ErrorResponse(0, mockserver.OpcodeChatSearch, "search.failed", "search failed")
+		}
+		return mockserver.SearchChatsResponse(0, []map[string]any{
+			mockserver.TestChat(testChatID, mockserver.ChatTypeChat, "Рабочий чат"),
+		}, 0)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	chats, marker, err := client.SearchChats(ctx, "Рабочий", Pagination{Count: 10})
+	require.NoError(t, err)
+	assert.Nil(t, marker)
+	require.Len(t, chats, 1)
+	assert.Equal(t, int64(testChatID), chats[0].ID)
+
+	it := client.SearchChatsIter("broken", 10)
+	assert.False(t, it.Next(ctx))
+	assert.Error(t, it.Err())
+}
+
 // TestJoinChannel проверяет присоединение к каналу.
 func TestJoinChannel(t *testing.T) {
 	server := mockserver.StartMockServerWithDefaults(t)
@@ -775,6 +1349,142 @@ func TestGetSessions(t *testing.T) {
 	assert.Len(t, sessions, 2)
 }
 
+// setupTwoFactorHandlers отвечает успехом на все шаги трека 2FA и возвращает
+// список опкодов в порядке поступления вместе с payload последнего AUTH_SET_2FA.
+func setupTwoFactorHandlers(server *mockserver.MockServer, trackID string) (func() []int, func() map[string]any) {
+	var mu sync.Mutex
+	var opcodes []int
+	var setPayload map[string]any
+
+	record := func(msg map[string]any) int {
+		mu.Lock()
+		defer mu.Unlock()
+		opcode := int(msg["opcode"].(float64))
+		opcodes = append(opcodes, opcode)
+		if opcode == mockserver.OpcodeAuthSet2FA {
+			setPayload = msg["payload"].(map[string]any)
+		}
+		return opcode
+	}
+
+	server.SetHandler(mockserver.OpcodeAuthCreateTrack, func(msg map[string]any) map[string]any {
+		record(msg)
+		return mockserver.CreateTrackResponse(int(msg["seq"].(float64)), trackID)
+	})
+	for _, opcode := range []int{
+		mockserver.OpcodeAuthValidatePassword,
+		mockserver.OpcodeAuthValidateHint,
+		mockserver.OpcodeAuthVerifyEmail,
+		mockserver.OpcodeAuthCheckEmail,
+		mockserver.OpcodeAuthSet2FA,
+	} {
+		server.SetHandler(opcode, func(msg map[string]any) map[string]any {
+			return mockserver.TwoFactorStepResponse(int(msg["seq"].(float64)), record(msg))
+		})
+	}
+
+	return func() []int {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]int(nil), opcodes...)
+		}, func() map[string]any {
+			mu.Lock()
+			defer mu.Unlock()
+			return setPayload
+		}
+}
+
+// TestEnableTwoFactor проверяет включение 2FA с подсказкой и подтверждением почты.
+func TestEnableTwoFactor(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+	opcodes, setPayload := setupTwoFactorHandlers(server, "track-2fa")
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	var codeFor string
+	err := client.EnableTwoFactor(ctx, TwoFactorOptions{
+		Password: "secret",
+		Hint:     "hint",
+		Email:    "user@example.com",
+		EmailCodeProvider: func(ctx context.Context, email string) (string, error) {
+			codeFor = email
+			return "111111", nil
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "user@example.com", codeFor)
+	assert.Equal(t, []int{
+		mockserver.OpcodeAuthCreateTrack,
+		mockserver.OpcodeAuthValidatePassword,
+		mockserver.OpcodeAuthValidateHint,
+		mockserver.OpcodeAuthVerifyEmail,
+		mockserver.OpcodeAuthCheckEmail,
+		mockserver.OpcodeAuthSet2FA,
+	}, opcodes())
+
+	payload := setPayload()
+	assert.Equal(t, "track-2fa", payload["trackId"])
+	assert.Equal(t, true, payload["enabled"])
+	assert.Equal(t, "secret", payload["password"])
+	assert.Equal(t, "user@example.com", payload["email"])
+}
+
+// TestChangeTwoFactor проверяет смену пароля без подсказки и почты.
+func TestChangeTwoFactor(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+	opcodes, setPayload := setupTwoFactorHandlers(server, "track-change")
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	require.NoError(t, client.ChangeTwoFactor(ctx, "old", TwoFactorOptions{Password: "new"}))
+	assert.Equal(t, []int{
+		mockserver.OpcodeAuthCreateTrack,
+		mockserver.OpcodeAuthValidatePassword,
+		mockserver.OpcodeAuthSet2FA,
+	}, opcodes())
+	assert.Equal(t, "new", setPayload()["password"])
+
+	var create map[string]any
+	for _, m := range server.GetReceivedMessages() {
+		if int(m["opcode"].(float64)) == mockserver.OpcodeAuthCreateTrack {
+			create = m["payload"].(map[string]any)
+		}
+	}
+	require.NotNil(t, create)
+	assert.Equal(t, string(enums.TwoFactorChange), create["type"])
+	assert.Equal(t, "old", create["password"])
+}
+
+// TestDisableTwoFactor проверяет отключение 2FA.
+func TestDisableTwoFactor(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+	opcodes, setPayload := setupTwoFactorHandlers(server, "track-off")
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	require.NoError(t, client.DisableTwoFactor(ctx, "secret"))
+	assert.Equal(t, []int{mockserver.OpcodeAuthCreateTrack, mockserver.OpcodeAuthSet2FA}, opcodes())
+	assert.Equal(t, false, setPayload()["enabled"])
+	assert.NotContains(t, setPayload(), "password")
+}
+
+// TestEnableTwoFactor_Validation проверяет отказ до отправки запросов при неполных параметрах.
+func TestEnableTwoFactor_Validation(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+	opcodes, _ := setupTwoFactorHandlers(server, "track")
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	assert.Error(t, client.EnableTwoFactor(ctx, TwoFactorOptions{}))
+	assert.Error(t, client.EnableTwoFactor(ctx, TwoFactorOptions{Password: "p", Email: "user@example.com"}))
+	assert.Empty(t, opcodes())
+}
+
 // TestGetChatId проверяет вычисление ID диалога.
 func TestGetChatId(t *testing.T) {
 	server := mockserver.StartMockServerWithDefaults(t)
@@ -788,19 +1498,120 @@ func TestGetChatId(t *testing.T) {
 	assert.Equal(t, chatID, chatID2)
 }
 
-// TestResolveChannelByName проверяет разрешение канала по имени.
-func TestResolveChannelByName(t *testing.T) {
+// TestResolveChannel проверяет разрешение канала по имени.
+func TestResolveChannel(t *testing.T) {
 	server := mockserver.StartMockServerWithDefaults(t)
 
 	server.SetHandler(89, func(msg map[string]any) map[string]any {
+		payload, _ := msg["payload"].(map[string]any)
+		if payload["link"] != "https://max.ru/testchannel" {
+			return mockserver.ResolveLinkResponse(0)
+		}
+		return mockserver.LinkInfoResponse(0, mockserver.TestChat(testChatID, mockserver.ChatTypeChannel, "Test channel"), nil, nil)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	channel, err := client.ResolveChannel(ctx, "testchannel")
+	require.NoError(t, err)
+	require.NotNil(t, channel)
+	assert.Equal(t, int64(testChatID), channel.ID)
+
+	_, err = client.ResolveChannel(ctx, "unknown")
+	var respErr *ResponseError
+	assert.ErrorAs(t, err, &respErr)
+
+	// Устаревший вариант сохраняет прежнюю сигнатуру.
+	require.NoError(t, client.ResolveChannelByName(ctx, "testchannel"))
+}
+
+// TestResolveLink проверяет определение типа ссылки по ответу LINK_INFO.
+func TestResolveLink(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	server.SetHandler(mockserver.OpcodeLinkInfo, func(msg map[string]any) map[string]any {
+		payload, _ := msg["payload"].(map[string]any)
+		switch payload["link"] {
+		case "https://max.ru/join/abc":
+			return mockserver.LinkInfoResponse(0, mockserver.TestChat(testChatID, mockserver.ChatTypeChat, "Group"), nil, nil)
+		case "https://max.ru/u/ivan":
+			return mockserver.LinkInfoResponse(0, nil, mockserver.TestUser(testUserID, "Иван", "Иванов", testPhone), nil)
+		case "https://max.ru/news/42":
+			return mockserver.LinkInfoResponse(0,
+				mockserver.TestChat(testChatID, mockserver.ChatTypeChannel, "News"),
+				nil,
+				map[string]any{"id": 42, "text": "post", "time": 1, "type": "USER"},
+			)
+		}
 		return mockserver.ResolveLinkResponse(0)
 	})
 
 	client := createTestClient(t, server)
 	ctx := mockserver.TestContext(t)
 
-	err := client.ResolveChannelByName(ctx, "testchannel")
+	info, err := client.ResolveLink(ctx, "https://max.ru/join/abc")
+	require.NoError(t, err)
+	assert.Equal(t, enums.LinkTypeChat, info.Type)
+	require.NotNil(t, info.Chat)
+	assert.Equal(t, int64(testChatID), info.Chat.ID)
+
+	info, err = client.ResolveLink(ctx, "https://max.ru/u/ivan")
 	require.NoError(t, err)
+	assert.Equal(t, enums.LinkTypeUser, info.Type)
+	require.NotNil(t, info.User)
+	assert.Equal(t, int64(testUserID), info.User.ID)
+
+	info, err = client.ResolveLink(ctx, "https://max.ru/news/42")
+	require.NoError(t, err)
+	assert.Equal(t, enums.LinkTypeMessage, info.Type)
+	require.NotNil(t, info.Message)
+	assert.Equal(t, int64(42), info.Message.ID)
+	require.NotNil(t, info.Message.ChatID)
+	assert.Equal(t, int64(testChatID), *info.Message.ChatID)
+
+	info, err = client.ResolveLink(ctx, "https://max.ru/nothing")
+	require.NoError(t, err)
+	assert.Equal(t, enums.LinkTypeUnknown, info.Type)
+}
+
+// TestPublicSearch проверяет раскладку результатов публичного поиска по типам.
+func TestPublicSearch(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	var kind, marker, count any
+	server.SetHandler(mockserver.OpcodePublicSearch, func(msg map[string]any) map[string]any {
+		payload, _ := msg["payload"].(map[string]any)
+		kind, marker, count = payload["type"], payload["marker"], payload["count"]
+		return mockserver.PublicSearchResponse(0,
+			[]map[string]any{
+				mockserver.TestChat(1, mockserver.ChatTypeChannel, "News"),
+				mockserver.TestChat(2, mockserver.ChatTypeChat, "Talks"),
+			},
+			[]map[string]any{mockserver.TestUser(testUserID, "Иван", "", testPhone)},
+		)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	res, err := client.PublicSearch(ctx, "news", "", Pagination{})
+	require.NoError(t, err)
+	assert.Equal(t, "ALL", kind)
+	assert.Nil(t, marker)
+	require.Len(t, res.Channels, 1)
+	assert.Equal(t, int64(1), res.Channels[0].ID)
+	require.Len(t, res.Chats, 1)
+	assert.Equal(t, int64(2), res.Chats[0].ID)
+	require.Len(t, res.Users, 1)
+	assert.Nil(t, res.Marker)
+
+	next := int64(40)
+	_, err = client.PublicSearch(ctx, "news", enums.PublicSearchChannels, Pagination{Marker: &next, Count: 10})
+	require.NoError(t, err)
+	assert.Equal(t, "CHANNELS", kind)
+	assert.Equal(t, float64(40), marker)
+	assert.Equal(t, float64(10), count)
 }
 
 // TestGetVideoById проверяет получение метаданных видео.
@@ -835,6 +1646,104 @@ func TestGetFileById(t *testing.T) {
 	assert.NotNil(t, fileReq)
 }
 
+// TestDownload_ResumesAfterDisconnect проверяет скачивание файла с обрывом посреди тела:
+// вторая попытка идёт с Range, размер и SHA256 сверяются, прогресс доходит до конца.
+func TestDownload_ResumesAfterDisconnect(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	digest := sha256.Sum256(content)
+
+	var requests atomic.Int32
+	var ranges []string
+	var mu sync.Mutex
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", "10000")
+			w.Write(content[:4000])
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "report.pdf", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(media.Close)
+
+	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodeFileDownload, func(msg map[string]any) map[string]any {
+		return mockserver.GetFileByIdResponse(0, media.URL+"/report.pdf")
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	attach := types.Attach{
+		Type: enums.AttachTypeFile,
+		File: &types.FileAttach{Type: enums.AttachTypeFile, ID: 5678, Name: "report.pdf", Size: int64(len(content))},
+	}
+	var buf bytes.Buffer
+	var lastWritten, lastTotal int64
+	n, err := client.Download(ctx, attach, &buf, DownloadOptions{
+		ChatID:    testChatID,
+		MessageID: testMessageID,
+		SHA256:    hex.EncodeToString(digest[:]),
+		Progress: func(written, total int64) {
+			lastWritten, lastTotal = written, total
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), n)
+	assert.Equal(t, content, buf.Bytes())
+	assert.Equal(t, n, lastWritten)
+	assert.Equal(t, n, lastTotal)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, ranges, 2)
+	assert.Empty(t, ranges[0])
+	assert.Equal(t, "bytes=4000-", ranges[1])
+}
+
+// TestDownload_Verification проверяет обнаружение несовпадения размера и контрольной суммы,
+// а также продолжение с Offset у сервера без поддержки Range.
+func TestDownload_Verification(t *testing.T) {
+	content := []byte("photo bytes")
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	t.Cleanup(media.Close)
+
+	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodeFileDownload, func(msg map[string]any) map[string]any {
+		return mockserver.GetFileByIdResponse(0, media.URL)
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	photo := types.Attach{Photo: &types.PhotoAttach{BaseURL: media.URL}}
+
+	var buf bytes.Buffer
+	buf.WriteString("photo")
+	n, err := client.Download(ctx, photo, &buf, DownloadOptions{Offset: 5})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), n)
+	assert.Equal(t, content, buf.Bytes())
+
+	var integrityErr *DownloadIntegrityError
+	_, err = client.Download(ctx, photo, &bytes.Buffer{}, DownloadOptions{SHA256: strings.Repeat("0", 64)})
+	require.ErrorAs(t, err, &integrityErr)
+	assert.Equal(t, "sha256", integrityErr.Check)
+
+	file := types.Attach{File: &types.FileAttach{ID: 1, Size: 100}}
+	_, err = client.Download(ctx, file, &bytes.Buffer{}, DownloadOptions{ChatID: testChatID, MessageID: testMessageID})
+	require.ErrorAs(t, err, &integrityErr)
+	assert.Equal(t, "size", integrityErr.Check)
+	assert.Equal(t, "100", integrityErr.Expected)
+
+	_, err = client.Download(ctx, file, &bytes.Buffer{}, DownloadOptions{})
+	assert.Error(t, err)
+}
+
 // TestSendMessage_UploadFlow проверяет flow загрузки вложений.
 func TestSendMessage_UploadFlow(t *testing.T) {
 
@@ -858,6 +1767,292 @@ func TestSendMessage_UploadFlow(t *testing.T) {
 	assert.NotNil(t, msg)
 }
 
+// TestSendMessage_ChunkedFileUpload проверяет потоковую загрузку файла частями:
+// у каждой части свой Content-Range, а после частичного подтверждения через Range
+// отправка продолжается с первого неподтверждённого байта.
+func TestSendMessage_ChunkedFileUpload(t *testing.T) {
+	content := bytes.Repeat([]byte("abcdefghij"), 1000)
+	path := filepath.Join(t.TempDir(), "recording.mp4")
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	const fileID int64 = 777
+	var mu sync.Mutex
+	var ranges []string
+	var received []byte
+	partial := true
+	httpServer := mockserver.MockHTTPServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+		ranges = append(ranges, r.Header.Get("Content-Range"))
+		if len(received) == 4096 && partial {
+			// Подтверждаем только первые 1000 байт второй части.
+			partial = false
+			received = append(received, body[:1000]...)
+			w.Header().Set("Range", "0-5095")
+			return
+		}
+		received = append(received, body...)
+		if len(received) == len(content) {
+			server.Broadcast(mockserver.NotifAttachResponse(fileID, 0))
+		}
+	})
+
+	server.SetHandler(mockserver.OpcodeFileUpload, func(msg map[string]any) map[string]any {
+		return mockserver.FileUploadResponse(0, httpServer.URL, fileID)
+	})
+	server.SetHandler(mockserver.OpcodeMsgSend, func(msg map[string]any) map[string]any {
+		return mockserver.SendMessageResponse(0, testChatID, testMessageID, "Recording")
+	})
+
+	client, err := NewMaxClient(ClientConfig{
+		Phone:           testPhone,
+		URI:             server.URL(),
+		WorkDir:         t.TempDir(),
+		Token:           testAuthToken,
+		Logger:          logger.Nop(),
+		UploadChunkSize: 4096,
+	})
+	require.NoError(t, err)
+	ctx := mockserver.TestContext(t)
+	require.NoError(t, client.Start(ctx))
+	t.Cleanup(func() { client.Close() })
+
+	file, err := files.NewFileFromPath(path)
+	require.NoError(t, err)
+
+	msg, err := client.SendMessage(ctx, "Recording", testChatID, true, file, nil, nil)
+	require.NoError(t, err)
+	assert.NotNil(t, msg)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, content, received)
+	assert.Equal(t, []string{
+		"0-4095/10000",
+		"4096-8191/10000",
+		"5096-9191/10000",
+		"9192-9999/10000",
+	}, ranges)
+}
+
+// TestSendMessage_StreamedPhotoUpload проверяет потоковую multipart‑отправку фото с известной длиной.
+func TestSendMessage_StreamedPhotoUpload(t *testing.T) {
+	content := bytes.Repeat([]byte{0xFF, 0xD8}, 2048)
+	path := filepath.Join(t.TempDir(), "shot.jpg")
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+
+	var got []byte
+	var length int64
+	httpServer := mockserver.MockHTTPServer(t, func(w http.ResponseWriter, r *http.Request) {
+		length = r.ContentLength
+		file, header, err := r.FormFile("file")
+		if err == nil {
+			got, _ = io.ReadAll(file)
+			assert.Equal(t, "image.jpg", header.Filename)
+		}
+		mockserver.PhotoUploadHandler("photo_token_123")(w, r)
+	})
+
+	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodePhotoUpload, func(msg map[string]any) map[string]any {
+		return mockserver.PhotoUploadResponse(0, httpServer.URL)
+	})
+	server.SetHandler(mockserver.OpcodeMsgSend, func(msg map[string]any) map[string]any {
+		return mockserver.SendMessageResponse(0, testChatID, testMessageID, "Photo")
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	photo, err := files.NewPhotoFromPath(path)
+	require.NoError(t, err)
+
+	_, err = client.SendMessage(ctx, "Photo", testChatID, true, photo, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+	assert.Greater(t, length, int64(len(content)))
+}
+
+// TestSendMessage_PhotoFromURL проверяет отправку фото по URL: повтор после 5xx,
+// имя из Content-Disposition, определение типа по содержимому и лимит размера.
+func TestSendMessage_PhotoFromURL(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
+
+	var hits atomic.Int32
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="chart"`)
+		w.Write(png)
+	}))
+	t.Cleanup(remote.Close)
+
+	var uploadedName string
+	var uploaded []byte
+	httpServer := mockserver.MockHTTPServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if file, header, err := r.FormFile("file"); err == nil {
+			uploadedName = header.Filename
+			uploaded, _ = io.ReadAll(file)
+		}
+		mockserver.PhotoUploadHandler("photo_token_123")(w, r)
+	})
+
+	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodePhotoUpload, func(msg map[string]any) map[string]any {
+		return mockserver.PhotoUploadResponse(0, httpServer.URL)
+	})
+	server.SetHandler(mockserver.OpcodeMsgSend, func(msg map[string]any) map[string]any {
+		return mockserver.SendMessageResponse(0, testChatID, testMessageID, "Chart")
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	photo := files.NewPhotoFromURL(remote.URL+"/render?id=1", files.URLOptions{RetryDelay: time.Millisecond})
+	_, err := client.SendMessage(ctx, "Chart", testChatID, true, photo, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), hits.Load())
+	assert.Equal(t, "chart.png", photo.FileName())
+	assert.Equal(t, "image/png", photo.ContentType())
+	assert.Equal(t, "image.png", uploadedName)
+	assert.Equal(t, png, uploaded)
+
+	small := files.NewFileFromURL(remote.URL+"/big.bin", files.URLOptions{MaxSize: 8})
+	_, err = client.SendMessage(ctx, "Too big", testChatID, true, small, nil, nil)
+	var tooLarge *files.FileTooLargeError
+	assert.ErrorAs(t, err, &tooLarge)
+}
+
+// TestSendMessage_FileFromURLStreamed проверяет потоковую отправку файла по URL без Content-Length:
+// содержимое доходит до сервера загрузки целиком, повторная отправка скачивает файл заново,
+// лимит размера срабатывает по числу прочитанных байт, а отменённый контекст прерывает скачивание.
+func TestSendMessage_FileFromURLStreamed(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	var hits atomic.Int32
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		// Отправка частями с Flush не даёт серверу выставить Content-Length.
+		for i := 0; i < len(content); i += 2500 {
+			w.Write(content[i : i+2500])
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(remote.Close)
+
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	const fileID int64 = 778
+	var mu sync.Mutex
+	var received []byte
+	httpServer := mockserver.MockHTTPServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, body...)
+		if len(received)%len(content) == 0 {
+			server.Broadcast(mockserver.NotifAttachResponse(fileID, 0))
+		}
+	})
+	server.SetHandler(mockserver.OpcodeFileUpload, func(msg map[string]any) map[string]any {
+		return mockserver.FileUploadResponse(0, httpServer.URL, fileID)
+	})
+	server.SetHandler(mockserver.OpcodeMsgSend, func(msg map[string]any) map[string]any {
+		return mockserver.SendMessageResponse(0, testChatID, testMessageID, "Report")
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	file := files.NewFileFromURL(remote.URL + "/report.txt")
+	for i := 0; i < 2; i++ {
+		_, err := client.SendMessage(ctx, "Report", testChatID, true, file, nil, nil)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), hits.Load())
+	size, err := file.Size()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), size)
+	mu.Lock()
+	assert.Equal(t, append(append([]byte(nil), content...), content...), received)
+	mu.Unlock()
+
+	small := files.NewFileFromURL(remote.URL+"/report.txt", files.URLOptions{MaxSize: 4096})
+	_, err = client.SendMessage(ctx, "Too big", testChatID, true, small, nil, nil)
+	var tooLarge *files.FileTooLargeError
+	assert.ErrorAs(t, err, &tooLarge)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = files.NewFileFromURL(remote.URL + "/report.txt").OpenContext(cancelled)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// TestSendMessage_FileFromURLReleasedOnFailure проверяет, что временный файл,
+// созданный при запросе файла по URL, удаляется, если загрузка не состоялась.
+func TestSendMessage_FileFromURLReleasedOnFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Без Content-Length тело сохраняется во временный файл.
+		w.Write([]byte("not an image"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(" at all"))
+	}))
+	t.Cleanup(remote.Close)
+
+	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodeFileUpload, func(msg map[string]any) map[string]any {
+		return mockserver.ErrorResponse(0, mockserver.OpcodeFileUpload, "upload.denied", "upload denied")
+	})
+	server.SetHandler(mockserver.OpcodePhotoUpload, func(msg map[string]any) map[string]any {
+		return mockserver.PhotoUploadResponse(0, remote.URL+"/upload")
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	_, err := client.SendMessage(ctx, "Report", testChatID, true, files.NewFileFromURL(remote.URL+"/report.txt"), nil, nil)
+	require.Error(t, err)
+
+	_, err = client.SendMessage(ctx, "Photo", testChatID, true, files.NewPhotoFromURL(remote.URL+"/photo.txt"), nil, nil)
+	var badExt *files.InvalidPhotoExtensionError
+	require.ErrorAs(t, err, &badExt)
+
+	left, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Empty(t, left)
+}
+
+// TestValidatePhoto_NameFromDisposition проверяет, что расширение фото по URL без
+// расширения берётся из имени, уточнённого по Content-Disposition.
+func TestValidatePhoto_NameFromDisposition(t *testing.T) {
+	t.Parallel()
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="cat.jpg"`)
+		w.Write([]byte("jpeg bytes"))
+	}))
+	t.Cleanup(remote.Close)
+
+	photo := files.NewPhotoFromURL(remote.URL + "/photos/42")
+	require.NoError(t, photo.Fetch(mockserver.TestContext(t)))
+	defer photo.Release()
+
+	ext, mime, err := photo.ValidatePhoto()
+	require.NoError(t, err)
+	assert.Equal(t, "jpg", ext)
+	assert.Equal(t, "image/jpeg", mime)
+}
+
 // TestTimeout проверяет обработку таймаутов.
 func TestTimeout(t *testing.T) {
 	server := mockserver.StartMockServer(t)
@@ -1,13 +1,25 @@
 package gomax
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/fresh-milkshake/gomax/enums"
 	"github.com/fresh-milkshake/gomax/internal/constants"
 	"github.com/fresh-milkshake/gomax/logger"
 	"github.com/fresh-milkshake/gomax/mockserver"
+	"github.com/fresh-milkshake/gomax/sessionstore"
+	"github.com/fresh-milkshake/gomax/types"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -191,6 +203,629 @@ func TestLogin_QRFlow(t *testing.T) {
 	assert.Equal(t, loginToken, payload["token"])
 }
 
+// TestLogin_QRWithPassword проверяет QR‑авторизацию аккаунта с облачным паролем:
+// после LOGIN_BY_QR пароль запрашивается через PasswordProvider и подтверждается по треку.
+func TestLogin_QRWithPassword(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServer(t)
+	server.DefaultHandlers()
+
+	const trackID = "qr-track"
+	const passwordTrackID = "password-track"
+
+	server.SetHandler(mockserver.OpcodeGetQR, func(msg map[string]any) map[string]any {
+		return mockserver.GetQRResponse(int(msg["seq"].(float64)), trackID, "https://qr.example", 100, time.Now().Add(5*time.Second).UnixMilli())
+	})
+	server.SetHandler(mockserver.OpcodeGetQRStatus, func(msg map[string]any) map[string]any {
+		return mockserver.GetQRStatusResponse(int(msg["seq"].(float64)), true, time.Now().Add(5*time.Second).UnixMilli())
+	})
+	server.SetHandler(mockserver.OpcodeLoginByQR, func(msg map[string]any) map[string]any {
+		return mockserver.LoginByQRPasswordChallengeResponse(int(msg["seq"].(float64)), passwordTrackID, "pet")
+	})
+	var checked map[string]any
+	server.SetHandler(mockserver.OpcodeAuthLoginCheckPassword, func(msg map[string]any) map[string]any {
+		checked = msg["payload"].(map[string]any)
+		return mockserver.CheckPasswordResponse(int(msg["seq"].(float64)), testLoginToken)
+	})
+
+	var hint string
+	client, err := NewMaxClient(ClientConfig{
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: t.TempDir(),
+		Logger:  logger.Nop(),
+		UserAgent: UserAgent{
+			DeviceType:      constants.DeviceTypeWeb,
+			AppVersion:      constants.MinWebQRAppVersion,
+			HeaderUserAgent: constants.DefaultUserAgent,
+		},
+		QRProvider: func(ctx context.Context, state QRState) error { return nil },
+		PasswordProvider: func(ctx context.Context, h string) (string, error) {
+			hint = h
+			return "secret", nil
+		},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.NoError(t, client.Start(mockserver.TestContext(t)))
+	assert.Equal(t, "pet", hint)
+	assert.Equal(t, passwordTrackID, checked["trackId"])
+	assert.Equal(t, "secret", checked["password"])
+	assert.Equal(t, testLoginToken, client.token)
+}
+
+// TestLogin_QRProviderRegenerates проверяет статусы QRProvider и перевыпуск истёкшего QR‑кода.
+func TestLogin_QRProviderRegenerates(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServer(t)
+	server.DefaultHandlers()
+
+	const loginToken = "qr_login_token"
+	var mu sync.Mutex
+	issued := 0
+	server.SetHandler(mockserver.OpcodeGetQR, func(msg map[string]any) map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		issued++
+		// Первый код истекает сразу, второй действует долго.
+		expiresAt := time.Now().Add(-time.Second).UnixMilli()
+		if issued > 1 {
+			expiresAt = time.Now().Add(5 * time.Second).UnixMilli()
+		}
+		return mockserver.GetQRResponse(int(msg["seq"].(float64)), fmt.Sprintf("track-%d", issued), fmt.Sprintf("https://qr.example/%d", issued), 20, expiresAt)
+	})
+	polls := 0
+	server.SetHandler(mockserver.OpcodeGetQRStatus, func(msg map[string]any) map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		payload := msg["payload"].(map[string]any)
+		available := false
+		if payload["trackId"] == "track-2" {
+			polls++
+			available = polls > 1
+		}
+		return mockserver.GetQRStatusResponse(int(msg["seq"].(float64)), available, 0)
+	})
+	server.SetHandler(mockserver.OpcodeLoginByQR, func(msg map[string]any) map[string]any {
+		return mockserver.LoginByQRResponse(int(msg["seq"].(float64)), loginToken)
+	})
+
+	var states []QRState
+	client, err := NewMaxClient(ClientConfig{
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: t.TempDir(),
+		Logger:  logger.Nop(),
+		UserAgent: UserAgent{
+			DeviceType:      constants.DeviceTypeWeb,
+			AppVersion:      constants.MinWebQRAppVersion,
+			HeaderUserAgent: constants.DefaultUserAgent,
+		},
+		QRProvider: func(ctx context.Context, state QRState) error {
+			states = append(states, state)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.NoError(t, client.Start(mockserver.TestContext(t)))
+
+	var statuses []QRStatus
+	for _, s := range states {
+		statuses = append(statuses, s.Status)
+	}
+	assert.Equal(t, []QRStatus{QRStatusNew, QRStatusExpired, QRStatusNew, QRStatusPending, QRStatusConfirmed}, statuses)
+	assert.Equal(t, "https://qr.example/1", states[0].Link)
+	assert.Equal(t, "track-2", states[2].TrackID)
+	assert.Equal(t, 2, states[2].Attempt)
+	assert.Equal(t, "track-2", states[len(states)-1].TrackID)
+}
+
+// TestQRPresenters проверяет PNG‑ и SVG‑представления QR‑кода.
+func TestQRPresenters(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	state := QRState{Status: QRStatusNew, Link: "https://qr.example/login", TrackID: "t"}
+
+	path := filepath.Join(t.TempDir(), "qr.png")
+	require.NoError(t, PNGFileQRPresenter(path)(ctx, state))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("\x89PNG")))
+
+	var svg string
+	show := SVGQRPresenter(func(ctx context.Context, s string, st QRState) error {
+		svg = s
+		return nil
+	})
+	require.NoError(t, show(ctx, QRState{Status: QRStatusPending}))
+	assert.Empty(t, svg)
+	require.NoError(t, show(ctx, state))
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Contains(t, svg, "h1v1h-1z")
+
+	var out bytes.Buffer
+	require.NoError(t, TerminalQRPresenter(&out)(ctx, state))
+	assert.Contains(t, out.String(), state.Link)
+}
+
+// TestLogin_TwoFactorPassword проверяет вход в аккаунт с облачным паролем.
+func TestLogin_TwoFactorPassword(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServer(t)
+	server.DefaultHandlers()
+
+	const trackID = "pwd-track"
+	const hint = "кличка кота"
+	const password = "secret"
+
+	server.SetHandler(mockserver.OpcodeAuthRequest, func(msg map[string]any) map[string]any {
+		return mockserver.AuthRequestResponse(int(msg["seq"].(float64)), testTempToken)
+	})
+	server.SetHandler(mockserver.OpcodeAuth, func(msg map[string]any) map[string]any {
+		return mockserver.AuthPasswordChallengeResponse(int(msg["seq"].(float64)), trackID, hint, "")
+	})
+	server.SetHandler(mockserver.OpcodeAuthLoginCheckPassword, func(msg map[string]any) map[string]any {
+		seq := int(msg["seq"].(float64))
+		payload := msg["payload"].(map[string]any)
+		if payload["trackId"] != trackID || payload["password"] != password {
+			return mockserver.ErrorResponse(seq, mockserver.OpcodeAuthLoginCheckPassword, "password.invalid", "Invalid password")
+		}
+		return mockserver.CheckPasswordResponse(seq, testLoginToken)
+	})
+
+	var gotHint string
+	client, err := NewMaxClient(ClientConfig{
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: t.TempDir(),
+		Logger:  logger.Nop(),
+		CodeProvider: func(ctx context.Context) (string, error) {
+			return testVerifyCode, nil
+		},
+		PasswordProvider: func(ctx context.Context, h string) (string, error) {
+			gotHint = h
+			return password, nil
+		},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	require.NoError(t, client.Start(ctx))
+	assert.Equal(t, hint, gotHint)
+
+	var loginMsg map[string]any
+	for _, m := range server.GetReceivedMessages() {
+		if int(m["opcode"].(float64)) == mockserver.OpcodeLogin {
+			loginMsg = m
+			break
+		}
+	}
+	require.NotNil(t, loginMsg, "LOGIN not sent after password check")
+	assert.Equal(t, testLoginToken, loginMsg["payload"].(map[string]any)["token"])
+}
+
+// TestLogin_TwoFactorWrongPassword проверяет, что ошибка проверки пароля возвращается из Start.
+func TestLogin_TwoFactorWrongPassword(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServer(t)
+	server.DefaultHandlers()
+
+	server.SetHandler(mockserver.OpcodeAuthRequest, func(msg map[string]any) map[string]any {
+		return mockserver.AuthRequestResponse(int(msg["seq"].(float64)), testTempToken)
+	})
+	server.SetHandler(mockserver.OpcodeAuth, func(msg map[string]any) map[string]any {
+		return mockserver.AuthPasswordChallengeResponse(int(msg["seq"].(float64)), "pwd-track", "", "")
+	})
+	server.SetHandler(mockserver.OpcodeAuthLoginCheckPassword, func(msg map[string]any) map[string]any {
+		return mockserver.ErrorResponse(int(msg["seq"].(float64)), mockserver.OpcodeAuthLoginCheckPassword, "password.invalid", "Invalid password")
+	})
+
+	client, err := NewMaxClient(ClientConfig{
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: t.TempDir(),
+		Logger:  logger.Nop(),
+		CodeProvider: func(ctx context.Context) (string, error) {
+			return testVerifyCode, nil
+		},
+		PasswordProvider: func(ctx context.Context, hint string) (string, error) {
+			return "wrong", nil
+		},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	err = client.Start(mockserver.TestContext(t))
+	require.Error(t, err)
+	var maxErr *Error
+	require.ErrorAs(t, err, &maxErr)
+	assert.Equal(t, "password.invalid", maxErr.Code)
+}
+
+// TestAuthFlow_ResumeAcrossClients проверяет пошаговый вход с 2FA, когда состояние
+// сохраняется в JSON и каждый шаг выполняет новый экземпляр клиента.
+func TestAuthFlow_ResumeAcrossClients(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServer(t)
+	server.DefaultHandlers()
+
+	server.SetHandler(mockserver.OpcodeAuthRequest, func(msg map[string]any) map[string]any {
+		return mockserver.AuthRequestResponse(int(msg["seq"].(float64)), testTempToken)
+	})
+	server.SetHandler(mockserver.OpcodeAuth, func(msg map[string]any) map[string]any {
+		seq := int(msg["seq"].(float64))
+		payload := msg["payload"].(map[string]any)
+		if payload["token"] != testTempToken || payload["verifyCode"] != testVerifyCode {
+			return mockserver.ErrorResponse(seq, mockserver.OpcodeAuth, "verify.code.wrong", "Wrong code")
+		}
+		return mockserver.AuthPasswordChallengeResponse(seq, "pwd-track", "hint", "")
+	})
+	server.SetHandler(mockserver.OpcodeAuthLoginCheckPassword, func(msg map[string]any) map[string]any {
+		return mockserver.CheckPasswordResponse(int(msg["seq"].(float64)), testLoginToken)
+	})
+
+	workDir := t.TempDir()
+	ctx := mockserver.TestContext(t)
+	newClient := func() *MaxClient {
+		client, err := NewMaxClient(ClientConfig{
+			Phone:   testPhone,
+			URI:     server.URL(),
+			WorkDir: workDir,
+			Logger:  logger.Nop(),
+		})
+		require.NoError(t, err)
+		require.NoError(t, client.Connect(ctx))
+		return client
+	}
+	// step выполняет шаг на новом клиенте и возвращает сохранённое состояние.
+	step := func(saved []byte, run func(flow *AuthFlow) error) []byte {
+		client := newClient()
+		defer client.Close()
+		var state AuthFlowState
+		if saved != nil {
+			require.NoError(t, json.Unmarshal(saved, &state))
+		}
+		flow := client.ResumeAuthFlow(state)
+		require.NoError(t, run(flow))
+		data, err := json.Marshal(flow.State())
+		require.NoError(t, err)
+		return data
+	}
+
+	saved := step(nil, func(flow *AuthFlow) error {
+		return flow.Begin(ctx, testPhone)
+	})
+	saved = step(saved, func(flow *AuthFlow) error {
+		next, err := flow.SubmitCode(ctx, "000000")
+		assert.Equal(t, AuthStepCode, next)
+		assert.Error(t, err)
+
+		next, err = flow.SubmitCode(ctx, testVerifyCode)
+		assert.Equal(t, AuthStepPassword, next)
+		assert.Equal(t, "hint", flow.State().Password.Hint)
+		return err
+	})
+	saved = step(saved, func(flow *AuthFlow) error {
+		return flow.SubmitPassword(ctx, "secret")
+	})
+
+	var final AuthFlowState
+	require.NoError(t, json.Unmarshal(saved, &final))
+	assert.Equal(t, AuthStepDone, final.Step)
+	assert.Empty(t, final.TempToken)
+	assert.Nil(t, final.Password)
+
+	// Токен сохранён в сессии: Start входит без повторной авторизации.
+	client := newClient()
+	defer client.Close()
+	require.NoError(t, client.Start(ctx))
+
+	var loginMsg map[string]any
+	for _, m := range server.GetReceivedMessages() {
+		if int(m["opcode"].(float64)) == mockserver.OpcodeLogin {
+			loginMsg = m
+		}
+	}
+	require.NotNil(t, loginMsg)
+	assert.Equal(t, testLoginToken, loginMsg["payload"].(map[string]any)["token"])
+}
+
+// TestAuthFlow_RegisterAndSteps проверяет Resend, регистрацию и ошибку шага.
+func TestAuthFlow_RegisterAndSteps(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServer(t)
+	server.DefaultHandlers()
+
+	var mu sync.Mutex
+	requests := 0
+	server.SetHandler(mockserver.OpcodeAuthRequest, func(msg map[string]any) map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		return mockserver.AuthRequestResponse(int(msg["seq"].(float64)), fmt.Sprintf("temp-%d", requests))
+	})
+	server.SetHandler(mockserver.OpcodeAuth, func(msg map[string]any) map[string]any {
+		payload := msg["payload"].(map[string]any)
+		assert.Equal(t, "temp-2", payload["token"])
+		return mockserver.AuthResponse(int(msg["seq"].(float64)), testRegToken, "")
+	})
+	server.SetHandler(mockserver.OpcodeAuthConfirm, func(msg map[string]any) map[string]any {
+		payload := msg["payload"].(map[string]any)
+		assert.Equal(t, testRegToken, payload["token"])
+		return mockserver.AuthConfirmResponse(int(msg["seq"].(float64)), testAuthToken)
+	})
+
+	client, err := NewMaxClient(ClientConfig{
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: t.TempDir(),
+		Logger:  logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	require.NoError(t, client.Connect(ctx))
+
+	flow := client.NewAuthFlow()
+	var stepErr *AuthFlowStepError
+	require.ErrorAs(t, flow.SubmitPassword(ctx, "secret"), &stepErr)
+	assert.Equal(t, AuthStepPassword, stepErr.Expected)
+	assert.Equal(t, AuthStepStart, stepErr.Actual)
+
+	require.NoError(t, flow.Begin(ctx, testPhone))
+	require.NoError(t, flow.Resend(ctx))
+	assert.Equal(t, "temp-2", flow.State().TempToken)
+
+	next, err := flow.SubmitCode(ctx, testVerifyCode)
+	require.NoError(t, err)
+	assert.Equal(t, AuthStepRegister, next)
+
+	require.NoError(t, flow.Register(ctx, testFirstName, nil))
+	assert.Equal(t, AuthStepDone, flow.Step())
+	assert.Equal(t, testAuthToken, client.token)
+}
+
+// TestAuthFlow_RejectsOtherPhone проверяет, что поток не авторизует номер, отличный от номера
+// клиента, — ни при Begin, ни при продолжении чужого сохранённого состояния.
+func TestAuthFlow_RejectsOtherPhone(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServer(t)
+	server.DefaultHandlers()
+	server.SetupAuthHandlers(testTempToken, "", testLoginToken, "")
+
+	store := sessionstore.NewMemory()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:        testPhone,
+		URI:          server.URL(),
+		SessionStore: store,
+		Logger:       logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	require.NoError(t, client.Connect(ctx))
+
+	const otherPhone = "+79997654321"
+	var phoneErr *AuthFlowPhoneError
+	require.ErrorAs(t, client.NewAuthFlow().Begin(ctx, otherPhone), &phoneErr)
+	assert.Equal(t, otherPhone, phoneErr.Phone)
+	assert.Equal(t, testPhone, phoneErr.ClientPhone)
+
+	flow := client.ResumeAuthFlow(AuthFlowState{Step: AuthStepCode, Phone: otherPhone, TempToken: testTempToken})
+	_, err = flow.SubmitCode(ctx, testVerifyCode)
+	require.ErrorAs(t, err, &phoneErr)
+
+	for _, m := range server.GetReceivedMessages() {
+		op := int(m["opcode"].(float64))
+		assert.NotEqual(t, mockserver.OpcodeAuthRequest, op)
+		assert.NotEqual(t, mockserver.OpcodeAuth, op)
+	}
+	assert.Empty(t, client.token)
+	data, err := store.Load(ctx, testPhone)
+	require.NoError(t, err)
+	assert.Empty(t, data.Token)
+}
+
+// TestSessionStore_Custom проверяет, что токен после входа попадает в переданное хранилище
+// и следующий клиент с тем же хранилищем входит без кода на том же deviceId.
+func TestSessionStore_Custom(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServer(t)
+	server.DefaultHandlers()
+	server.SetupAuthHandlers(testTempToken, "", testLoginToken, "")
+
+	store := sessionstore.NewMemory()
+	ctx := mockserver.TestContext(t)
+	codeRequests := 0
+	newClient := func() *MaxClient {
+		client, err := NewMaxClient(ClientConfig{
+			Phone:        testPhone,
+			URI:          server.URL(),
+			SessionStore: store,
+			Logger:       logger.Nop(),
+			CodeProvider: func(ctx context.Context) (string, error) {
+				codeRequests++
+				return testVerifyCode, nil
+			},
+		})
+		require.NoError(t, err)
+		return client
+	}
+
+	first := newClient()
+	require.NoError(t, first.Start(ctx))
+	require.NoError(t, first.Close())
+
+	saved, err := store.Load(ctx, testPhone)
+	require.NoError(t, err)
+	require.NotNil(t, saved, "store must stay usable after Close")
+	assert.Equal(t, testLoginToken, saved.Token)
+	assert.Equal(t, first.deviceID.String(), saved.DeviceID)
+
+	second := newClient()
+	defer second.Close()
+	require.NoError(t, second.Start(ctx))
+	assert.Equal(t, 1, codeRequests)
+	assert.Equal(t, first.deviceID, second.deviceID)
+	assert.Equal(t, testLoginToken, second.token)
+}
+
+// TestSessionStore_MultipleAccounts проверяет, что клиенты с разными номерами в одном
+// WorkDir не перезаписывают токены друг друга, а также ListAccounts и RemoveAccount.
+func TestSessionStore_MultipleAccounts(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServer(t)
+	server.DefaultHandlers()
+
+	const otherPhone = "+79997654321"
+	server.SetHandler(mockserver.OpcodeAuthRequest, func(msg map[string]any) map[string]any {
+		phone := msg["payload"].(map[string]any)["phone"].(string)
+		return mockserver.AuthRequestResponse(int(msg["seq"].(float64)), "temp-"+phone)
+	})
+	server.SetHandler(mockserver.OpcodeAuth, func(msg map[string]any) map[string]any {
+		temp := msg["payload"].(map[string]any)["token"].(string)
+		return mockserver.AuthResponse(int(msg["seq"].(float64)), "", "login-"+strings.TrimPrefix(temp, "temp-"))
+	})
+
+	workDir := t.TempDir()
+	ctx := mockserver.TestContext(t)
+	var codeRequests atomic.Int32
+	newClient := func(phone string) *MaxClient {
+		client, err := NewMaxClient(ClientConfig{
+			Phone:   phone,
+			URI:     server.URL(),
+			WorkDir: workDir,
+			Logger:  logger.Nop(),
+			CodeProvider: func(ctx context.Context) (string, error) {
+				codeRequests.Add(1)
+				return testVerifyCode, nil
+			},
+		})
+		require.NoError(t, err)
+		return client
+	}
+
+	first, second := newClient(testPhone), newClient(otherPhone)
+	require.NoError(t, first.Start(ctx))
+	require.NoError(t, second.Start(ctx))
+	assert.NotEqual(t, first.deviceID, second.deviceID)
+	require.NoError(t, first.Close())
+	require.NoError(t, second.Close())
+
+	again := newClient(testPhone)
+	defer again.Close()
+	assert.Equal(t, "login-"+testPhone, again.token)
+	assert.Equal(t, first.deviceID, again.deviceID)
+	require.NoError(t, again.Start(ctx))
+	assert.Equal(t, int32(2), codeRequests.Load())
+
+	accounts, err := again.ListAccounts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{testPhone, otherPhone}, accounts)
+
+	require.NoError(t, again.RemoveAccount(ctx, otherPhone))
+	accounts, err = again.ListAccounts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{testPhone}, accounts)
+	assert.Equal(t, "login-"+testPhone, again.token)
+
+	require.NoError(t, again.RemoveAccount(ctx, testPhone))
+	assert.Empty(t, again.token)
+	accounts, err = again.ListAccounts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+}
+
+// TestSessionStore_LegacyMigration проверяет, что сессия, сохранённая без номера,
+// переходит к первому запущенному клиенту.
+func TestSessionStore_LegacyMigration(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := sessionstore.NewMemory()
+	legacy := &sessionstore.Data{DeviceID: "7b0c4d3e-7f5e-4a43-9d8e-3c1a3b1f2e10", Token: "legacy-token", DeviceType: "DESKTOP"}
+	require.NoError(t, store.Save(ctx, sessionstore.LegacyAccount, legacy))
+
+	client, err := NewMaxClient(ClientConfig{Phone: testPhone, SessionStore: store, Logger: logger.Nop()})
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, "legacy-token", client.token)
+	assert.Equal(t, legacy.DeviceID, client.deviceID.String())
+	assert.Equal(t, "DESKTOP", client.cfg.UserAgent.DeviceType)
+
+	moved, err := store.Load(ctx, testPhone)
+	require.NoError(t, err)
+	assert.Equal(t, legacy, moved)
+	gone, err := store.Load(ctx, sessionstore.LegacyAccount)
+	require.NoError(t, err)
+	assert.Nil(t, gone)
+}
+
+// TestSessionStore_LegacyMigrationTwoPhones проверяет, что сессия без номера не
+// переносится при наличии других аккаунтов и возвращается на место, если SYNC
+// показал чужой номер.
+func TestSessionStore_LegacyMigrationTwoPhones(t *testing.T) {
+	t.Parallel()
+	const otherPhone = "+79990000002"
+	legacy := &sessionstore.Data{DeviceID: "7b0c4d3e-7f5e-4a43-9d8e-3c1a3b1f2e10", Token: "legacy-token", DeviceType: "DESKTOP"}
+
+	t.Run("other accounts present", func(t *testing.T) {
+		ctx := context.Background()
+		store := sessionstore.NewMemory()
+		require.NoError(t, store.Save(ctx, sessionstore.LegacyAccount, legacy))
+		require.NoError(t, store.Save(ctx, testPhone, &sessionstore.Data{Token: "own-token"}))
+
+		client, err := NewMaxClient(ClientConfig{Phone: otherPhone, SessionStore: store, Logger: logger.Nop()})
+		require.NoError(t, err)
+		defer client.Close()
+		assert.Empty(t, client.token)
+		assert.NotEqual(t, legacy.DeviceID, client.deviceID.String())
+
+		kept, err := store.Load(ctx, sessionstore.LegacyAccount)
+		require.NoError(t, err)
+		assert.Equal(t, legacy, kept)
+	})
+
+	t.Run("token of another phone", func(t *testing.T) {
+		server := mockserver.StartMockServer(t)
+		server.DefaultHandlers()
+		server.SetupAuthHandlers(testTempToken, testRegToken, testLoginToken, testAuthToken)
+
+		store := sessionstore.NewMemory()
+		require.NoError(t, store.Save(context.Background(), sessionstore.LegacyAccount, legacy))
+
+		client, err := NewMaxClient(ClientConfig{
+			Phone:        otherPhone,
+			URI:          server.URL(),
+			SessionStore: store,
+			Logger:       logger.Nop(),
+			UserAgent:    UserAgent{DeviceType: constants.DeviceTypeDesktop},
+			CodeProvider: func(ctx context.Context) (string, error) {
+				return testVerifyCode, nil
+			},
+		})
+		require.NoError(t, err)
+		defer client.Close()
+		assert.Equal(t, "legacy-token", client.token)
+
+		ctx := mockserver.TestContext(t)
+		require.NoError(t, client.Start(ctx))
+		assert.Equal(t, testLoginToken, client.token)
+
+		restored, err := store.Load(ctx, sessionstore.LegacyAccount)
+		require.NoError(t, err)
+		assert.Equal(t, legacy, restored)
+		own, err := store.Load(ctx, otherPhone)
+		require.NoError(t, err)
+		assert.Equal(t, testLoginToken, own.Token)
+	})
+}
+
 // TestLogin_QRVersionTooLow проверяет отказ при недостаточной версии для WEB QR.
 func TestLogin_QRVersionTooLow(t *testing.T) {
 	t.Parallel()
@@ -287,21 +922,71 @@ func TestStart_WithExistingToken(t *testing.T) {
 
 }
 
-// TestStart_OnStartHandler проверяет вызов OnStart обработчика.
-func TestStart_OnStartHandler(t *testing.T) {
+// TestStart_RetryAfterFailure проверяет, что после неудачного Start (в том числе при повторном
+// входе из-за недействительного токена) соединение закрывается, а повторный Start подключается заново.
+func TestStart_RetryAfterFailure(t *testing.T) {
 	t.Parallel()
 	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetupAuthHandlers(testTempToken, "", testLoginToken, "")
 
-	workDir := t.TempDir()
-	client, err := NewMaxClient(ClientConfig{
-		Phone:   testPhone,
-		URI:     server.URL(),
-		WorkDir: workDir,
-		Token:   testAuthToken,
-		Logger:  logger.Nop(),
+	var syncs atomic.Int32
+	server.SetHandler(mockserver.OpcodeLogin, func(msg map[string]any) map[string]any {
+		if syncs.Add(1) == 1 {
+			return mockserver.ErrorResponse(0, mockserver.OpcodeLogin, "login.token", "token expired")
+		}
+		return mockserver.SyncResponse(0, nil, nil)
 	})
-	require.NoError(t, err)
-	defer client.Close()
+
+	var codeRequests atomic.Int32
+	client, err := NewMaxClient(ClientConfig{
+		Phone:     testPhone,
+		URI:       server.URL(),
+		WorkDir:   t.TempDir(),
+		Token:     "expired-token",
+		Logger:    logger.Nop(),
+		UserAgent: UserAgent{DeviceType: constants.DeviceTypeDesktop},
+		CodeProvider: func(ctx context.Context) (string, error) {
+			if codeRequests.Add(1) == 1 {
+				return "", errors.New("code not available yet")
+			}
+			return testVerifyCode, nil
+		},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	require.Error(t, client.Start(ctx))
+	assert.Equal(t, StateClosed, client.State())
+
+	require.NoError(t, client.Start(ctx))
+	assert.Equal(t, StateReady, client.State())
+	assert.Equal(t, testLoginToken, client.token)
+
+	var inits int
+	for _, m := range server.GetReceivedMessages() {
+		if int(m["opcode"].(float64)) == mockserver.OpcodeSessionInit {
+			inits++
+		}
+	}
+	assert.Equal(t, 2, inits)
+}
+
+// TestStart_OnStartHandler проверяет вызов OnStart обработчика.
+func TestStart_OnStartHandler(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: workDir,
+		Token:   testAuthToken,
+		Logger:  logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
 
 	handlerCalled := make(chan struct{})
 	client.OnStart(func(ctx context.Context) {
@@ -368,17 +1053,493 @@ func TestSyncResponse(t *testing.T) {
 		return mockserver.SyncResponse(0, nil, nil)
 	})
 
-	server.SetHandler(21, func(msg map[string]any) map[string]any {
-		return mockserver.SyncResponse(0, nil, nil)
-	})
+	server.SetHandler(21, func(msg map[string]any) map[string]any {
+		return mockserver.SyncResponse(0, nil, nil)
+	})
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: workDir,
+		Token:   testAuthToken,
+		Logger:  logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	err = client.Start(ctx)
+	require.NoError(t, err)
+
+	syncNotification := map[string]any{
+		"ver":    11,
+		"cmd":    0,
+		"seq":    0,
+		"opcode": 21,
+		"payload": map[string]any{
+			"profile": map[string]any{
+				"contact": map[string]any{
+					"id":    int64(999),
+					"phone": testPhone,
+					"names": []map[string]any{
+						{
+							"firstName": "Custom",
+							"lastName":  "User",
+							"type":      "FIRST_LAST",
+						},
+					},
+				},
+			},
+			"chats": []map[string]any{
+				mockserver.TestChat(100, "DIALOG", ""),
+				mockserver.TestChat(200, "CHAT", "Test Group"),
+				mockserver.TestChat(300, "CHANNEL", "Test Channel"),
+			},
+		},
+	}
+	err = server.SendNotification(syncNotification)
+	require.NoError(t, err)
+
+	time.Sleep(200 * time.Millisecond)
+
+	me := client.Profile()
+	require.NotNil(t, me)
+	require.NotEmpty(t, me.Names)
+	assert.Equal(t, "Custom", *me.Names[0].FirstName)
+}
+
+// TestReconnect_OnConnectionLoss проверяет переподключение при потере соединения.
+func TestReconnect_OnConnectionLoss(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:          testPhone,
+		URI:            server.URL(),
+		WorkDir:        workDir,
+		Token:          testAuthToken,
+		Reconnect:      true,
+		ReconnectDelay: 100 * time.Millisecond,
+		Logger:         logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	err = client.Start(ctx)
+	require.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	server.CloseAllConnections()
+
+	time.Sleep(500 * time.Millisecond)
+
+	assert.True(t, server.IsConnected(), "client should have reconnected")
+}
+
+// TestReconnect_ReAuthentication проверяет повторную аутентификацию при переподключении.
+func TestReconnect_ReAuthentication(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:          testPhone,
+		URI:            server.URL(),
+		WorkDir:        workDir,
+		Token:          testAuthToken,
+		Reconnect:      true,
+		ReconnectDelay: 100 * time.Millisecond,
+		Logger:         logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	err = client.Start(ctx)
+	require.NoError(t, err)
+
+	time.Sleep(300 * time.Millisecond)
+
+	initialMessages := server.GetReceivedMessages()
+	initialSessionInitCount := 0
+	initialSyncCount := 0
+	for _, msg := range initialMessages {
+		if opcode, ok := msg["opcode"].(float64); ok {
+			if int(opcode) == 6 {
+				initialSessionInitCount++
+			}
+			if int(opcode) == 19 {
+				initialSyncCount++
+			}
+		}
+	}
+
+	server.ClearReceivedMessages()
+
+	server.CloseAllConnections()
+
+	time.Sleep(1 * time.Second)
+
+	assert.True(t, server.IsConnected(), "client should have reconnected")
+
+	if server.IsConnected() {
+		t.Log("Client successfully reconnected")
+	}
+}
+
+// TestReconnect_Disabled проверяет поведение при Reconnect = false.
+func TestReconnect_Disabled(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:          testPhone,
+		URI:            server.URL(),
+		WorkDir:        workDir,
+		Token:          testAuthToken,
+		Reconnect:      false,
+		ReconnectDelay: 100 * time.Millisecond,
+		Logger:         logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	err = client.Start(ctx)
+	require.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	server.CloseAllConnections()
+
+	time.Sleep(500 * time.Millisecond)
+
+	assert.False(t, server.IsConnected(), "client should not reconnect when Reconnect=false")
+}
+
+// TestReconnect_MultipleReconnections проверяет множественные переподключения.
+func TestReconnect_MultipleReconnections(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:          testPhone,
+		URI:            server.URL(),
+		WorkDir:        workDir,
+		Token:          testAuthToken,
+		Reconnect:      true,
+		ReconnectDelay: 200 * time.Millisecond,
+		Logger:         logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	err = client.Start(ctx)
+	require.NoError(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+
+	if !mockserver.WaitForCondition(t, 2*time.Second, func() bool {
+		return server.IsConnected()
+	}) {
+		t.Fatal("connection not established before disconnect")
+	}
+
+	server.CloseAllConnections()
+
+	if !mockserver.WaitForCondition(t, 3*time.Second, func() bool {
+		return server.IsConnected()
+	}) {
+		t.Fatal("client did not reconnect after disconnect")
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	assert.True(t, server.IsConnected(), "connection should remain stable after reconnection")
+}
+
+// TestReconnect_WithLoginFlow проверяет переподключение с повторным логином.
+func TestReconnect_WithLoginFlow(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServer(t)
+	server.DefaultHandlers()
+
+	server.SetHandler(17, func(msg map[string]any) map[string]any {
+		return mockserver.AuthRequestResponse(0, testTempToken)
+	})
+
+	server.SetHandler(18, func(msg map[string]any) map[string]any {
+		return mockserver.AuthResponse(0, "", testLoginToken)
+	})
+
+	workDir := t.TempDir()
+
+	codeProvided := make(chan struct{}, 2)
+	client, err := NewMaxClient(ClientConfig{
+		Phone:          testPhone,
+		URI:            server.URL(),
+		WorkDir:        workDir,
+		Reconnect:      true,
+		ReconnectDelay: 100 * time.Millisecond,
+		Logger:         logger.Nop(),
+		CodeProvider: func(ctx context.Context) (string, error) {
+			codeProvided <- struct{}{}
+			return testVerifyCode, nil
+		},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	err = client.Start(ctx)
+	require.NoError(t, err)
+
+	select {
+	case <-codeProvided:
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("code provider was not called on initial connection")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	server.CloseAllConnections()
+
+	time.Sleep(1 * time.Second)
+
+	assert.True(t, server.IsConnected(), "client should have reconnected")
+
+	select {
+	case <-codeProvided:
+
+	default:
+
+	}
+
+	assert.True(t, server.IsConnected(), "client should have reconnected")
+}
+
+// TestHeartbeat_MeasuresRTT проверяет, что PING отправляется по интервалу и RTT измеряется.
+func TestHeartbeat_MeasuresRTT(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:        testPhone,
+		URI:          server.URL(),
+		WorkDir:      workDir,
+		Token:        testAuthToken,
+		PingInterval: 50 * time.Millisecond,
+		Logger:       logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	err = client.Start(ctx)
+	require.NoError(t, err)
+
+	ok := mockserver.WaitForCondition(t, 2*time.Second, func() bool {
+		return client.PingRTT() > 0
+	})
+	require.True(t, ok, "PING RTT was not measured")
+	assert.Equal(t, 0, client.MissedPings())
+	mockserver.AssertMessageReceived(t, server, mockserver.OpcodePing)
+}
+
+// TestHeartbeat_ReconnectOnMissedPings проверяет принудительное переподключение,
+// когда сервер перестаёт отвечать на PING.
+func TestHeartbeat_ReconnectOnMissedPings(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodePing, func(msg map[string]any) map[string]any {
+		return nil
+	})
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:          testPhone,
+		URI:            server.URL(),
+		WorkDir:        workDir,
+		Token:          testAuthToken,
+		Reconnect:      true,
+		ReconnectDelay: 50 * time.Millisecond,
+		PingInterval:   50 * time.Millisecond,
+		MaxMissedPings: 2,
+		Logger:         logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := mockserver.TestContext(t)
+	err = client.Start(ctx)
+	require.NoError(t, err)
+
+	ok := mockserver.WaitForCondition(t, 3*time.Second, func() bool {
+		count := 0
+		for _, m := range server.GetReceivedMessages() {
+			if int(m["opcode"].(float64)) == mockserver.OpcodeSessionInit {
+				count++
+			}
+		}
+		return count >= 2
+	})
+	assert.True(t, ok, "client should reconnect after missed pings")
+}
+
+// TestState_Lifecycle проверяет переходы состояния при старте и закрытии клиента.
+func TestState_Lifecycle(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: workDir,
+		Token:   testAuthToken,
+		Logger:  logger.Nop(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StateIdle, client.State())
+
+	var mu sync.Mutex
+	var seen []ConnectionState
+	client.OnStateChange(func(ctx context.Context, from, to ConnectionState) {
+		mu.Lock()
+		seen = append(seen, to)
+		mu.Unlock()
+	})
+
+	ctx := mockserver.TestContext(t)
+	ready := make(chan error, 1)
+	go func() {
+		ready <- client.WaitReady(ctx)
+	}()
+
+	err = client.Start(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, StateReady, client.State())
+	require.NoError(t, <-ready)
+
+	require.NoError(t, client.Close())
+	assert.Equal(t, StateClosed, client.State())
+
+	var closedErr *ClientClosedError
+	assert.ErrorAs(t, client.WaitReady(ctx), &closedErr)
+
+	assert.True(t, mockserver.WaitForCondition(t, time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(seen) >= 5
+	}))
+	mu.Lock()
+	assert.Contains(t, seen, StateHandshaking)
+	assert.Contains(t, seen, StateSyncing)
+	mu.Unlock()
+}
+
+// TestState_DisconnectAndReconnectHooks проверяет вызов OnDisconnect и OnReconnect при обрыве соединения.
+func TestState_DisconnectAndReconnectHooks(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	workDir := t.TempDir()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:          testPhone,
+		URI:            server.URL(),
+		WorkDir:        workDir,
+		Token:          testAuthToken,
+		Reconnect:      true,
+		ReconnectDelay: 100 * time.Millisecond,
+		Logger:         logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	disconnected := make(chan error, 1)
+	reconnected := make(chan struct{}, 1)
+	client.OnDisconnect(func(ctx context.Context, err error) {
+		disconnected <- err
+	})
+	client.OnReconnect(func(ctx context.Context) {
+		reconnected <- struct{}{}
+	})
+
+	ctx := mockserver.TestContext(t)
+	err = client.Start(ctx)
+	require.NoError(t, err)
+
+	server.CloseAllConnections()
+
+	select {
+	case err := <-disconnected:
+		assert.Error(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnDisconnect was not called")
+	}
+
+	select {
+	case <-reconnected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnReconnect was not called")
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	require.NoError(t, client.WaitReady(waitCtx))
+	assert.Equal(t, StateReady, client.State())
+}
+
+// TestReconnectPolicy_Delay проверяет рост задержки, верхнюю границу и разброс jitter.
+func TestReconnectPolicy_Delay(t *testing.T) {
+	t.Parallel()
+	policy := ReconnectPolicy{
+		InitialDelay: 100 * time.Millisecond,
+		Multiplier:   2,
+		MaxDelay:     500 * time.Millisecond,
+	}
+	fillReconnectPolicyDefaults(&policy, time.Second)
+
+	assert.Equal(t, 100*time.Millisecond, policy.delay(1))
+	assert.Equal(t, 200*time.Millisecond, policy.delay(2))
+	assert.Equal(t, 400*time.Millisecond, policy.delay(3))
+	assert.Equal(t, 500*time.Millisecond, policy.delay(4))
+	assert.Equal(t, 500*time.Millisecond, policy.delay(10))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := policy.delay(1)
+		assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+		assert.LessOrEqual(t, d, 150*time.Millisecond)
+	}
+}
+
+// TestReconnect_PolicyExhausted проверяет остановку клиента и ошибку в Err,
+// когда все попытки переподключения исчерпаны.
+func TestReconnect_PolicyExhausted(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
 
 	workDir := t.TempDir()
 	client, err := NewMaxClient(ClientConfig{
-		Phone:   testPhone,
-		URI:     server.URL(),
-		WorkDir: workDir,
-		Token:   testAuthToken,
-		Logger:  logger.Nop(),
+		Phone:     testPhone,
+		URI:       server.URL(),
+		WorkDir:   workDir,
+		Token:     testAuthToken,
+		Reconnect: true,
+		ReconnectPolicy: ReconnectPolicy{
+			InitialDelay: 50 * time.Millisecond,
+			MaxAttempts:  2,
+		},
+		Logger: logger.Nop(),
 	})
 	require.NoError(t, err)
 	defer client.Close()
@@ -386,48 +1547,35 @@ func TestSyncResponse(t *testing.T) {
 	ctx := mockserver.TestContext(t)
 	err = client.Start(ctx)
 	require.NoError(t, err)
+	assert.NoError(t, client.Err())
 
-	syncNotification := map[string]any{
-		"ver":    11,
-		"cmd":    0,
-		"seq":    0,
-		"opcode": 21,
-		"payload": map[string]any{
-			"profile": map[string]any{
-				"contact": map[string]any{
-					"id":    int64(999),
-					"phone": testPhone,
-					"names": []map[string]any{
-						{
-							"firstName": "Custom",
-							"lastName":  "User",
-							"type":      "FIRST_LAST",
-						},
-					},
-				},
-			},
-			"chats": []map[string]any{
-				mockserver.TestChat(100, "DIALOG", ""),
-				mockserver.TestChat(200, "CHAT", "Test Group"),
-				mockserver.TestChat(300, "CHANNEL", "Test Channel"),
-			},
-		},
-	}
-	err = server.SendNotification(syncNotification)
-	require.NoError(t, err)
+	server.SetHandler(mockserver.OpcodeLogin, func(msg map[string]any) map[string]any {
+		return mockserver.ErrorResponse(0, mockserver.OpcodeLogin, "service.unavailable", "maintenance")
+	})
+	server.CloseAllConnections()
 
-	time.Sleep(200 * time.Millisecond)
+	select {
+	case <-client.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("client did not stop after reconnect policy was exhausted")
+	}
 
-	me := client.Profile()
-	require.NotNil(t, me)
-	require.NotEmpty(t, me.Names)
-	assert.Equal(t, "Custom", *me.Names[0].FirstName)
+	var reconnectErr *ReconnectError
+	require.ErrorAs(t, client.Err(), &reconnectErr)
+	assert.Equal(t, 2, reconnectErr.Attempts)
+	assert.Equal(t, StateClosed, client.State())
+	assert.ErrorAs(t, client.WaitReady(ctx), &reconnectErr)
 }
 
-// TestReconnect_OnConnectionLoss проверяет переподключение при потере соединения.
-func TestReconnect_OnConnectionLoss(t *testing.T) {
+// TestPending_FailFastOnConnectionLost проверяет, что неидемпотентный запрос
+// завершается ErrConnectionLost сразу при обрыве соединения, а не по таймауту.
+func TestPending_FailFastOnConnectionLost(t *testing.T) {
 	t.Parallel()
 	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodeMsgDelete, func(msg map[string]any) map[string]any {
+		go server.CloseAllConnections()
+		return nil
+	})
 
 	workDir := t.TempDir()
 	client, err := NewMaxClient(ClientConfig{
@@ -436,7 +1584,7 @@ func TestReconnect_OnConnectionLoss(t *testing.T) {
 		WorkDir:        workDir,
 		Token:          testAuthToken,
 		Reconnect:      true,
-		ReconnectDelay: 100 * time.Millisecond,
+		ReconnectDelay: 50 * time.Millisecond,
 		Logger:         logger.Nop(),
 	})
 	require.NoError(t, err)
@@ -446,20 +1594,37 @@ func TestReconnect_OnConnectionLoss(t *testing.T) {
 	err = client.Start(ctx)
 	require.NoError(t, err)
 
-	time.Sleep(100 * time.Millisecond)
-
-	server.CloseAllConnections()
-
-	time.Sleep(500 * time.Millisecond)
-
-	assert.True(t, server.IsConnected(), "client should have reconnected")
+	started := time.Now()
+	err = client.DeleteMessage(ctx, 12345, []int64{1}, false)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrConnectionLost)
+	var lostErr *ConnectionLostError
+	require.ErrorAs(t, err, &lostErr)
+	assert.Equal(t, enums.OpcodeMsgDelete, lostErr.Opcode)
+	assert.Less(t, time.Since(started), 3*time.Second)
 }
 
-// TestReconnect_ReAuthentication проверяет повторную аутентификацию при переподключении.
-func TestReconnect_ReAuthentication(t *testing.T) {
+// TestPending_ReplayIdempotentAfterReconnect проверяет повторную отправку
+// идемпотентного запроса с новым seq после переподключения.
+func TestPending_ReplayIdempotentAfterReconnect(t *testing.T) {
 	t.Parallel()
 	server := mockserver.StartMockServerWithDefaults(t)
 
+	var mu sync.Mutex
+	var seqs []int
+	server.SetHandler(mockserver.OpcodeChatHistory, func(msg map[string]any) map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		seqs = append(seqs, int(msg["seq"].(float64)))
+		if len(seqs) == 1 {
+			go server.CloseAllConnections()
+			return nil
+		}
+		return mockserver.FetchHistoryResponse(0, []map[string]any{
+			{"id": "1", "text": "replayed", "time": 1000, "sender": 1},
+		})
+	})
+
 	workDir := t.TempDir()
 	client, err := NewMaxClient(ClientConfig{
 		Phone:          testPhone,
@@ -467,7 +1632,7 @@ func TestReconnect_ReAuthentication(t *testing.T) {
 		WorkDir:        workDir,
 		Token:          testAuthToken,
 		Reconnect:      true,
-		ReconnectDelay: 100 * time.Millisecond,
+		ReconnectDelay: 50 * time.Millisecond,
 		Logger:         logger.Nop(),
 	})
 	require.NoError(t, err)
@@ -477,39 +1642,99 @@ func TestReconnect_ReAuthentication(t *testing.T) {
 	err = client.Start(ctx)
 	require.NoError(t, err)
 
-	time.Sleep(300 * time.Millisecond)
+	messages, err := client.FetchHistory(ctx, 12345, nil, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "replayed", messages[0].Text)
 
-	initialMessages := server.GetReceivedMessages()
-	initialSessionInitCount := 0
-	initialSyncCount := 0
-	for _, msg := range initialMessages {
-		if opcode, ok := msg["opcode"].(float64); ok {
-			if int(opcode) == 6 {
-				initialSessionInitCount++
-			}
-			if int(opcode) == 19 {
-				initialSyncCount++
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, seqs, 2)
+	assert.NotEqual(t, seqs[0], seqs[1], "replayed request must use a fresh seq")
+}
+
+// TestReconnect_UserFramesWaitForReady проверяет, что запрос, отправленный во время
+// reconnect, не уходит в новый сокет до авторизации, а переотправляется после неё.
+func TestReconnect_UserFramesWaitForReady(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+
+	handshaking := make(chan struct{})
+	release := make(chan struct{})
+	var inits atomic.Int32
+	server.SetHandler(mockserver.OpcodeSessionInit, func(msg map[string]any) map[string]any {
+		if inits.Add(1) == 2 {
+			close(handshaking)
+			select {
+			case <-release:
+			case <-time.After(5 * time.Second):
 			}
 		}
-	}
-
-	server.ClearReceivedMessages()
+		return mockserver.SessionInitResponse(0)
+	})
+	server.SetHandler(mockserver.OpcodeChatHistory, func(msg map[string]any) map[string]any {
+		return mockserver.FetchHistoryResponse(0, []map[string]any{
+			{"id": "1", "text": "after login", "time": 1000, "sender": 1},
+		})
+	})
 
-	server.CloseAllConnections()
+	client, err := NewMaxClient(ClientConfig{
+		Phone:          testPhone,
+		URI:            server.URL(),
+		WorkDir:        t.TempDir(),
+		Token:          testAuthToken,
+		Reconnect:      true,
+		ReconnectDelay: 50 * time.Millisecond,
+		Logger:         logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
 
-	time.Sleep(1 * time.Second)
+	ctx := mockserver.TestContext(t)
+	require.NoError(t, client.Start(ctx))
 
-	assert.True(t, server.IsConnected(), "client should have reconnected")
+	server.CloseAllConnections()
+	select {
+	case <-handshaking:
+	case <-ctx.Done():
+		t.Fatal("client did not reconnect")
+	}
 
-	if server.IsConnected() {
-		t.Log("Client successfully reconnected")
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.FetchHistory(ctx, testChatID, nil, 0, 10)
+		result <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	require.NoError(t, <-result)
+	require.Eventually(t, func() bool { return client.State() == StateReady }, 5*time.Second, 10*time.Millisecond)
+
+	lastLogin, history := -1, -1
+	for i, m := range server.GetReceivedMessages() {
+		switch int(m["opcode"].(float64)) {
+		case mockserver.OpcodeLogin:
+			lastLogin = i
+		case mockserver.OpcodeChatHistory:
+			history = i
+		}
 	}
+	require.NotEqual(t, -1, history)
+	assert.Greater(t, history, lastLogin, "request must not reach the server before the new session is authorized")
 }
 
-// TestReconnect_Disabled проверяет поведение при Reconnect = false.
-func TestReconnect_Disabled(t *testing.T) {
+// TestGapRecovery_BackfillsMissedMessages проверяет догрузку пропущенных сообщений
+// после reconnect с пометкой Replayed и дедупликацией по ID.
+func TestGapRecovery_BackfillsMissedMessages(t *testing.T) {
 	t.Parallel()
 	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodeChatHistory, func(msg map[string]any) map[string]any {
+		return mockserver.FetchHistoryResponse(0, []map[string]any{
+			{"id": "1", "chatId": 100, "text": "live", "time": 1000, "sender": 1},
+			{"id": "3", "chatId": 100, "text": "missed 2", "time": 3000, "sender": 1},
+			{"id": "2", "chatId": 100, "text": "missed 1", "time": 2000, "sender": 1},
+		})
+	})
 
 	workDir := t.TempDir()
 	client, err := NewMaxClient(ClientConfig{
@@ -517,40 +1742,78 @@ func TestReconnect_Disabled(t *testing.T) {
 		URI:            server.URL(),
 		WorkDir:        workDir,
 		Token:          testAuthToken,
-		Reconnect:      false,
-		ReconnectDelay: 100 * time.Millisecond,
+		Reconnect:      true,
+		ReconnectDelay: 50 * time.Millisecond,
 		Logger:         logger.Nop(),
 	})
 	require.NoError(t, err)
 	defer client.Close()
 
+	received := make(chan *types.Message, 10)
+	client.OnMessage(func(ctx context.Context, msg *types.Message) {
+		received <- msg
+	}, nil)
+
 	ctx := mockserver.TestContext(t)
 	err = client.Start(ctx)
 	require.NoError(t, err)
 
-	time.Sleep(100 * time.Millisecond)
+	live := map[string]any{"id": "1", "chatId": 100, "text": "live", "time": 1000, "sender": 1}
+	require.NoError(t, server.Broadcast(mockserver.NotifMessageResponse(live)))
+	select {
+	case msg := <-received:
+		assert.Equal(t, int64(1), msg.ID)
+		assert.False(t, msg.Replayed)
+	case <-time.After(2 * time.Second):
+		t.Fatal("live message was not delivered")
+	}
 
 	server.CloseAllConnections()
 
-	time.Sleep(500 * time.Millisecond)
+	replayed := map[int64]bool{}
+	timeout := time.After(3 * time.Second)
+	for len(replayed) < 2 {
+		select {
+		case msg := <-received:
+			assert.True(t, msg.Replayed, "backfilled message must be marked as replayed")
+			replayed[msg.ID] = true
+		case <-timeout:
+			t.Fatalf("missed messages were not recovered, got %v", replayed)
+		}
+	}
+	assert.True(t, replayed[2])
+	assert.True(t, replayed[3])
 
-	assert.False(t, server.IsConnected(), "client should not reconnect when Reconnect=false")
+	require.NoError(t, server.Broadcast(mockserver.NotifMessageResponse(
+		map[string]any{"id": "3", "chatId": 100, "text": "missed 2", "time": 3000, "sender": 1},
+	)))
+	select {
+	case msg := <-received:
+		t.Fatalf("duplicate message delivered: %d", msg.ID)
+	case <-time.After(300 * time.Millisecond):
+	}
 }
 
-// TestReconnect_MultipleReconnections проверяет множественные переподключения.
-func TestReconnect_MultipleReconnections(t *testing.T) {
+// TestInvoke_RawAndTyped проверяет Invoke и InvokeInto для произвольного opcode.
+func TestInvoke_RawAndTyped(t *testing.T) {
 	t.Parallel()
 	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodeSessionsInfo, func(msg map[string]any) map[string]any {
+		return mockserver.GetSessionsResponse(0, []map[string]any{
+			{"client": "Chrome", "info": "Linux", "location": "Moscow", "time": 1000, "current": true},
+		})
+	})
+	server.SetHandler(mockserver.OpcodeFoldersGet, func(msg map[string]any) map[string]any {
+		return mockserver.ErrorResponse(0, mockserver.OpcodeFoldersGet, "not.found", "no folders")
+	})
 
 	workDir := t.TempDir()
 	client, err := NewMaxClient(ClientConfig{
-		Phone:          testPhone,
-		URI:            server.URL(),
-		WorkDir:        workDir,
-		Token:          testAuthToken,
-		Reconnect:      true,
-		ReconnectDelay: 200 * time.Millisecond,
-		Logger:         logger.Nop(),
+		Phone:   testPhone,
+		URI:     server.URL(),
+		WorkDir: workDir,
+		Token:   testAuthToken,
+		Logger:  logger.Nop(),
 	})
 	require.NoError(t, err)
 	defer client.Close()
@@ -559,83 +1822,285 @@ func TestReconnect_MultipleReconnections(t *testing.T) {
 	err = client.Start(ctx)
 	require.NoError(t, err)
 
-	time.Sleep(500 * time.Millisecond)
-
-	if !mockserver.WaitForCondition(t, 2*time.Second, func() bool {
-		return server.IsConnected()
-	}) {
-		t.Fatal("connection not established before disconnect")
-	}
-
-	server.CloseAllConnections()
+	resp, err := client.Invoke(ctx, enums.OpcodeSessionsInfo, nil)
+	require.NoError(t, err)
+	assert.Equal(t, float64(mockserver.OpcodeSessionsInfo), resp["opcode"])
 
-	if !mockserver.WaitForCondition(t, 3*time.Second, func() bool {
-		return server.IsConnected()
-	}) {
-		t.Fatal("client did not reconnect after disconnect")
+	type sessionsPayload struct {
+		Sessions []types.Session `json:"sessions"`
 	}
+	out, err := InvokeInto[sessionsPayload](ctx, client, enums.OpcodeSessionsInfo, nil)
+	require.NoError(t, err)
+	require.Len(t, out.Sessions, 1)
+	assert.Equal(t, "Chrome", out.Sessions[0].Client)
 
-	time.Sleep(500 * time.Millisecond)
-	assert.True(t, server.IsConnected(), "connection should remain stable after reconnection")
+	_, err = InvokeInto[map[string]any](ctx, client, enums.OpcodeFoldersGet, map[string]any{"folderSync": 0})
+	var maxErr *Error
+	require.ErrorAs(t, err, &maxErr)
+	assert.Equal(t, "not.found", maxErr.Code)
 }
 
-// TestReconnect_WithLoginFlow проверяет переподключение с повторным логином.
-func TestReconnect_WithLoginFlow(t *testing.T) {
+// TestInvoke_Timeouts проверяет RequestTimeout из конфигурации и его переопределение дедлайном контекста.
+func TestInvoke_Timeouts(t *testing.T) {
 	t.Parallel()
-	server := mockserver.StartMockServer(t)
-	server.DefaultHandlers()
-
-	server.SetHandler(17, func(msg map[string]any) map[string]any {
-		return mockserver.AuthRequestResponse(0, testTempToken)
-	})
-
-	server.SetHandler(18, func(msg map[string]any) map[string]any {
-		return mockserver.AuthResponse(0, "", testLoginToken)
+	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodeSessionsInfo, func(msg map[string]any) map[string]any {
+		time.Sleep(200 * time.Millisecond)
+		return mockserver.GetSessionsResponse(0, nil)
 	})
 
 	workDir := t.TempDir()
-
-	codeProvided := make(chan struct{}, 2)
 	client, err := NewMaxClient(ClientConfig{
 		Phone:          testPhone,
 		URI:            server.URL(),
 		WorkDir:        workDir,
-		Reconnect:      true,
-		ReconnectDelay: 100 * time.Millisecond,
+		Token:          testAuthToken,
+		RequestTimeout: 50 * time.Millisecond,
 		Logger:         logger.Nop(),
-		CodeProvider: func(ctx context.Context) (string, error) {
-			codeProvided <- struct{}{}
-			return testVerifyCode, nil
-		},
 	})
 	require.NoError(t, err)
 	defer client.Close()
 
 	ctx := mockserver.TestContext(t)
-	err = client.Start(ctx)
-	require.NoError(t, err)
+	require.NoError(t, client.Start(ctx))
+
+	started := time.Now()
+	_, err = client.Invoke(context.Background(), enums.OpcodeSessionsInfo, nil)
+	require.Error(t, err)
+	assert.Less(t, time.Since(started), 190*time.Millisecond)
+
+	callCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = client.Invoke(callCtx, enums.OpcodeSessionsInfo, nil)
+	assert.NoError(t, err)
+}
+
+// TestSubscribe_FilterAndUnsubscribe проверяет фильтрацию событий по opcode и закрытие канала при отписке.
+func TestSubscribe_FilterAndUnsubscribe(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+	client := createTestClient(t, server)
+
+	events, unsubscribe := client.Subscribe(enums.OpcodeNotifTyping)
+
+	require.NoError(t, server.Broadcast(map[string]any{
+		"ver": 11, "cmd": 0, "seq": 0, "opcode": mockserver.OpcodeNotifContact,
+		"payload": map[string]any{"contactId": 1},
+	}))
+	require.NoError(t, server.Broadcast(map[string]any{
+		"ver": 11, "cmd": 0, "seq": 0, "opcode": mockserver.OpcodeNotifTyping,
+		"payload": map[string]any{"chatId": 100, "userId": 7},
+	}))
 
 	select {
-	case <-codeProvided:
+	case ev := <-events:
+		assert.Equal(t, enums.OpcodeNotifTyping, ev.Opcode)
+		var typing struct {
+			ChatID int64 `json:"chatId"`
+			UserID int64 `json:"userId"`
+		}
+		require.NoError(t, ev.Decode(&typing))
+		assert.Equal(t, int64(100), typing.ChatID)
+		assert.Equal(t, int64(7), typing.UserID)
+		assert.False(t, ev.ReceivedAt.IsZero())
+	case <-time.After(2 * time.Second):
+		t.Fatal("typing event was not delivered")
+	}
 
-	case <-time.After(5 * time.Second):
-		t.Fatal("code provider was not called on initial connection")
+	unsubscribe()
+	unsubscribe()
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+// TestSubscribe_SlowSubscriberDoesNotBlockReader проверяет, что переполненная подписка
+// не останавливает чтение из сокета и ответы на запросы.
+func TestSubscribe_SlowSubscriberDoesNotBlockReader(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodeSessionsInfo, func(msg map[string]any) map[string]any {
+		return mockserver.GetSessionsResponse(0, nil)
+	})
+
+	client, err := NewMaxClient(ClientConfig{
+		Phone:       testPhone,
+		URI:         server.URL(),
+		WorkDir:     t.TempDir(),
+		Token:       testAuthToken,
+		EventBuffer: 4,
+		Logger:      logger.Nop(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	require.NoError(t, client.Start(mockserver.TestContext(t)))
+
+	events, unsubscribe := client.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < 300; i++ {
+		require.NoError(t, server.Broadcast(map[string]any{
+			"ver": 11, "cmd": 0, "seq": 0, "opcode": mockserver.OpcodeNotifTyping,
+			"payload": map[string]any{"chatId": i},
+		}))
 	}
 
-	time.Sleep(200 * time.Millisecond)
+	callCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err = client.GetSessions(callCtx)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(events), 4)
+}
 
-	server.CloseAllConnections()
+// TestSubscribe_DropOldest проверяет, что при OverflowDropOldest в буфере остаются последние события,
+// а канал закрывается при остановке клиента.
+func TestSubscribe_DropOldest(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodeSessionsInfo, func(msg map[string]any) map[string]any {
+		return mockserver.GetSessionsResponse(0, nil)
+	})
 
-	time.Sleep(1 * time.Second)
+	client, err := NewMaxClient(ClientConfig{
+		Phone:         testPhone,
+		URI:           server.URL(),
+		WorkDir:       t.TempDir(),
+		Token:         testAuthToken,
+		EventBuffer:   2,
+		EventOverflow: OverflowDropOldest,
+		Logger:        logger.Nop(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, client.Start(mockserver.TestContext(t)))
+
+	events, _ := client.Subscribe(enums.OpcodeNotifTyping)
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, server.Broadcast(map[string]any{
+			"ver": 11, "cmd": 0, "seq": 0, "opcode": mockserver.OpcodeNotifTyping,
+			"payload": map[string]any{"chatId": i},
+		}))
+	}
+	// Ответ на запрос приходит после всех уведомлений, значит они уже распределены.
+	_, err = client.GetSessions(mockserver.TestContext(t))
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
 
-	assert.True(t, server.IsConnected(), "client should have reconnected")
+	var got []int64
+	for ev := range events {
+		var typing struct {
+			ChatID int64 `json:"chatId"`
+		}
+		require.NoError(t, ev.Decode(&typing))
+		got = append(got, typing.ChatID)
+	}
+	assert.Equal(t, []int64{4, 5}, got)
+}
 
-	select {
-	case <-codeProvided:
+// TestPresence_SubscribeAndNotifications проверяет заполнение хранилища присутствия
+// по CONTACT_PRESENCE и его обновление по NOTIF_PRESENCE.
+func TestPresence_SubscribeAndNotifications(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
 
-	default:
+	hourAgo := time.Now().Add(-time.Hour).Unix()
+	var requested []any
+	server.SetHandler(mockserver.OpcodeContactPresence, func(msg map[string]any) map[string]any {
+		requested = msg["payload"].(map[string]any)["contactIds"].([]any)
+		return mockserver.ContactPresenceResponse(0, map[int64]map[string]any{
+			1: {"seen": time.Now().Unix(), "online": true},
+			2: {"seen": hourAgo},
+		})
+	})
+
+	client := createTestClient(t, server)
+	changes := make(chan UserPresence, 8)
+	client.OnPresenceChange(func(ctx context.Context, p UserPresence) {
+		changes <- p
+	})
 
+	ctx := mockserver.TestContext(t)
+	require.NoError(t, client.SubscribePresence(ctx, 1, 2))
+	assert.Equal(t, []any{float64(1), float64(2)}, requested)
+
+	p1, ok := client.Presence(1)
+	require.True(t, ok)
+	assert.True(t, p1.Online)
+
+	p2, ok := client.Presence(2)
+	require.True(t, ok)
+	assert.False(t, p2.Online)
+	seen, ok := client.LastSeen(2)
+	require.True(t, ok)
+	assert.Equal(t, hourAgo, seen.Unix())
+
+	_, ok = client.Presence(3)
+	assert.False(t, ok)
+
+	for i := 0; i < 2; i++ {
+		<-changes
 	}
 
-	assert.True(t, server.IsConnected(), "client should have reconnected")
+	now := time.Now().Unix()
+	require.NoError(t, server.Broadcast(mockserver.NotifPresenceResponse(2, now, true)))
+	select {
+	case p := <-changes:
+		assert.Equal(t, int64(2), p.UserID)
+		assert.True(t, p.Online)
+		assert.Equal(t, now, p.LastSeen.Unix())
+	case <-time.After(2 * time.Second):
+		t.Fatal("presence change was not delivered")
+	}
+
+	require.NoError(t, server.Broadcast(mockserver.NotifPresenceResponse(2, now, true)))
+	select {
+	case p := <-changes:
+		t.Fatalf("unchanged presence delivered: %+v", p)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	client.UnsubscribePresence(2)
+	_, ok = client.Presence(2)
+	assert.False(t, ok)
+}
+
+// TestPresence_UntrackedAndPartialUpdates проверяет, что хранилище не заполняется
+// пользователями без подписки, а частичное обновление не сбрасывает флаг online.
+func TestPresence_UntrackedAndPartialUpdates(t *testing.T) {
+	t.Parallel()
+	server := mockserver.StartMockServerWithDefaults(t)
+	server.SetHandler(mockserver.OpcodeContactPresence, func(msg map[string]any) map[string]any {
+		return mockserver.ContactPresenceResponse(0, map[int64]map[string]any{
+			1: {"seen": time.Now().Unix(), "online": true},
+		})
+	})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+	require.NoError(t, client.SubscribePresence(ctx, 1))
+
+	now := time.Now().Unix()
+	require.NoError(t, server.Broadcast(mockserver.NotifPresenceResponse(7, now, true)))
+
+	// Через час после последней активности пользователь без явного флага считался бы не в сети.
+	hourAgo := time.Now().Add(-time.Hour).Unix()
+	require.NoError(t, server.Broadcast(map[string]any{
+		"ver":    mockserver.ProtocolVersion,
+		"cmd":    mockserver.ProtocolCommand,
+		"seq":    0,
+		"opcode": mockserver.OpcodeNotifPresence,
+		"payload": map[string]any{
+			"userId":   1,
+			"presence": map[string]any{"seen": hourAgo},
+		},
+	}))
+
+	require.True(t, mockserver.WaitForCondition(t, 2*time.Second, func() bool {
+		seen, ok := client.LastSeen(1)
+		return ok && seen.Unix() == hourAgo
+	}))
+	p, ok := client.Presence(1)
+	require.True(t, ok)
+	assert.True(t, p.Online)
+
+	_, ok = client.Presence(7)
+	assert.False(t, ok)
 }
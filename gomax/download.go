@@ -0,0 +1,225 @@
+package gomax
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Параметры скачивания вложения.
+type DownloadOptions struct {
+	// ChatID и MessageID сообщения с вложением. Обязательны для видео и файлов:
+	// ссылку на них выдаёт сервер по запросу GetVideoById/GetFileById.
+	ChatID    int64
+	MessageID int64
+	// Offset — сколько байт уже записано в w прошлым вызовом. Скачивание продолжится с этого
+	// места через HTTP Range; если сервер Range не поддерживает, первые Offset байт пропускаются.
+	Offset int64
+	// SHA256 — ожидаемая контрольная сумма (hex) всего содержимого. Проверяется только при
+	// Offset == 0, так как уже записанные ранее байты Download недоступны.
+	SHA256 string
+	// Progress вызывается по мере записи с числом записанных байт (включая Offset)
+	// и полным размером, либо -1, если размер неизвестен.
+	Progress func(written, total int64)
+}
+
+// Скачивает содержимое вложения в w и возвращает полный размер скачанного (включая Offset).
+//
+// Ссылка выбирается по типу вложения: PhotoAttach.BaseURL, AudioAttach.URL, StickerAttach.URL,
+// для видео и файлов — через GetVideoById и GetFileById. Ошибки соединения и 5xx повторяются
+// как в остальных HTTP‑запросах клиента; обрыв посреди тела продолжается запросом с Range,
+// не начиная загрузку заново. Размер сверяется с FileAttach.Size (или с размером от сервера),
+// а при заданном SHA256 — и контрольная сумма; несовпадение возвращается как *DownloadIntegrityError.
+func (c *MaxClient) Download(ctx context.Context, attach types.Attach, w io.Writer, opts DownloadOptions) (int64, error) {
+	url, expected, err := c.attachURL(ctx, attach, opts)
+	if err != nil {
+		return opts.Offset, err
+	}
+
+	var sum hash.Hash
+	if opts.SHA256 != "" && opts.Offset == 0 {
+		sum = sha256.New()
+	}
+	dst := &downloadWriter{w: w, hash: sum, written: opts.Offset, total: expected, progress: opts.Progress}
+
+	maxRetries := c.cfg.MaxRetries
+	for attempt := 0; ; attempt++ {
+		if dst.total >= 0 && dst.written >= dst.total {
+			// Всё уже скачано прошлым вызовом: запрос с Range вернул бы 416.
+			break
+		}
+		interrupted, err := c.downloadRange(ctx, url, dst)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return dst.written, ctx.Err()
+		}
+		if !interrupted || attempt >= maxRetries {
+			return dst.written, err
+		}
+		c.logger.Debug("Download interrupted, resuming", "attempt", attempt+1, "offset", dst.written, "err", err)
+	}
+
+	if dst.total >= 0 && dst.written != dst.total {
+		return dst.written, &DownloadIntegrityError{
+			Check:    "size",
+			Expected: strconv.FormatInt(dst.total, 10),
+			Actual:   strconv.FormatInt(dst.written, 10),
+		}
+	}
+	if sum != nil {
+		actual := hex.EncodeToString(sum.Sum(nil))
+		if !strings.EqualFold(actual, opts.SHA256) {
+			return dst.written, &DownloadIntegrityError{Check: "sha256", Expected: opts.SHA256, Actual: actual}
+		}
+	}
+	return dst.written, nil
+}
+
+// Выполняет один GET начиная с dst.written и копирует тело в dst.
+// interrupted равен true, если соединение оборвалось посреди тела и загрузку можно продолжить.
+func (c *MaxClient) downloadRange(ctx context.Context, url string, dst *downloadWriter) (interrupted bool, err error) {
+	offset := dst.written
+	resp, err := c.doHTTPRequestWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		return req, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	body := io.Reader(resp.Body)
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		// Сервер проигнорировал Range и отдаёт файл целиком: пропускаем уже записанное.
+		if _, err := io.CopyN(io.Discard, body, offset); err != nil {
+			return true, &NetworkError{Err: err}
+		}
+	}
+	if dst.total < 0 {
+		dst.total = responseSize(resp, offset)
+	}
+
+	if _, err := io.Copy(dst, body); err != nil {
+		if dst.writeErr != nil {
+			return false, dst.writeErr
+		}
+		return true, &NetworkError{Err: err}
+	}
+	return false, nil
+}
+
+// Определяет ссылку на скачивание вложения и его ожидаемый размер (-1, если неизвестен).
+func (c *MaxClient) attachURL(ctx context.Context, attach types.Attach, opts DownloadOptions) (string, int64, error) {
+	needMessage := func(kind string) error {
+		if opts.ChatID == 0 || opts.MessageID == 0 {
+			return fmt.Errorf("chat and message IDs are required to download %s", kind)
+		}
+		return nil
+	}
+
+	var url, kind string
+	size := int64(-1)
+	switch {
+	case attach.Photo != nil:
+		url, kind = attach.Photo.BaseURL, "photo"
+	case attach.Audio != nil:
+		url, kind = attach.Audio.URL, "audio"
+	case attach.Sticker != nil:
+		url, kind = attach.Sticker.URL, "sticker"
+	case attach.Video != nil:
+		kind = "video"
+		if err := needMessage("video"); err != nil {
+			return "", 0, err
+		}
+		video, err := c.GetVideoById(ctx, opts.ChatID, opts.MessageID, attach.Video.VideoID)
+		if err != nil {
+			return "", 0, err
+		}
+		url = video.URL
+	case attach.File != nil:
+		kind = "file"
+		if err := needMessage("file"); err != nil {
+			return "", 0, err
+		}
+		file, err := c.GetFileById(ctx, opts.ChatID, opts.MessageID, attach.File.ID)
+		if err != nil {
+			return "", 0, err
+		}
+		url = file.URL
+		if attach.File.Size > 0 {
+			size = attach.File.Size
+		}
+	default:
+		return "", 0, fmt.Errorf("attach of type %q cannot be downloaded", attach.Type)
+	}
+
+	if url == "" {
+		return "", 0, &ResponseError{Message: fmt.Sprintf("no download url for %s attach", kind)}
+	}
+	return url, size, nil
+}
+
+// Вычисляет полный размер содержимого по Content-Range или Content-Length; -1, если неизвестен.
+func responseSize(resp *http.Response, offset int64) int64 {
+	if cr := resp.Header.Get("Content-Range"); cr != "" {
+		if i := strings.LastIndexByte(cr, '/'); i >= 0 {
+			if total, err := strconv.ParseInt(cr[i+1:], 10, 64); err == nil {
+				return total
+			}
+		}
+	}
+	if resp.ContentLength < 0 {
+		return -1
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		return offset + resp.ContentLength
+	}
+	return resp.ContentLength
+}
+
+// Пишет в целевой writer, обновляя контрольную сумму и прогресс.
+// Ошибку записи запоминает отдельно, чтобы не повторять загрузку при отказе получателя.
+type downloadWriter struct {
+	w        io.Writer
+	hash     hash.Hash
+	written  int64
+	total    int64
+	progress func(written, total int64)
+	writeErr error
+}
+
+func (d *downloadWriter) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	if n > 0 {
+		if d.hash != nil {
+			d.hash.Write(p[:n])
+		}
+		d.written += int64(n)
+		if d.progress != nil {
+			d.progress(d.written, d.total)
+		}
+	}
+	if err != nil {
+		d.writeErr = err
+	} else if n < len(p) {
+		d.writeErr = io.ErrShortWrite
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
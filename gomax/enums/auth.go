@@ -10,3 +10,12 @@ const (
 	AuthTypeRegister  AuthType = "REGISTER"
 	AuthTypeResend    AuthType = "RESEND"
 )
+
+// Описывает цель трека изменения облачного пароля (AUTH_CREATE_TRACK).
+type TwoFactorAction string
+
+const (
+	TwoFactorEnable  TwoFactorAction = "ENABLE"
+	TwoFactorChange  TwoFactorAction = "CHANGE"
+	TwoFactorDisable TwoFactorAction = "DISABLE"
+)
@@ -46,3 +46,24 @@ const (
 	AttachTypeAudio   AttachType = "AUDIO"
 	AttachTypeControl AttachType = "CONTROL"
 )
+
+// Описывает категорию публичного поиска по каталогу Max.
+type PublicSearchKind string
+
+const (
+	PublicSearchAll      PublicSearchKind = "ALL"
+	PublicSearchChannels PublicSearchKind = "CHANNELS"
+	PublicSearchChats    PublicSearchKind = "CHATS"
+	PublicSearchUsers    PublicSearchKind = "USERS"
+)
+
+// Описывает, на что указывает ссылка Max: чат, канал, пользователя или сообщение.
+type LinkType string
+
+const (
+	LinkTypeUnknown LinkType = ""
+	LinkTypeChat    LinkType = "CHAT"
+	LinkTypeChannel LinkType = "CHANNEL"
+	LinkTypeUser    LinkType = "USER"
+	LinkTypeMessage LinkType = "MESSAGE"
+)
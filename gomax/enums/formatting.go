@@ -45,4 +45,5 @@ type ContactAction string
 const (
 	ContactActionAdd    ContactAction = "ADD"
 	ContactActionRemove ContactAction = "REMOVE"
+	ContactActionUpdate ContactAction = "UPDATE"
 )
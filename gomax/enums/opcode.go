@@ -57,7 +57,7 @@ const (
 	OpcodeChatSearch                   Opcode = 68
 	OpcodeMsgSharePreview              Opcode = 70
 	OpcodeMsgGet                       Opcode = 71
-	OpcodeMsgSearchTouch               Opcode = 72
+	OpcodeMsgSearchTouch               Opcode = 72 // без обёртки: формат payload неизвестен, вызывается через MaxClient.Invoke
 	OpcodeMsgSearch                    Opcode = 73
 	OpcodeMsgGetStat                   Opcode = 74
 	OpcodeChatSubscribe                Opcode = 75
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"net"
 	"syscall"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/enums"
 )
 
 // Описывает ошибку протокола Max с деталями,
@@ -92,6 +95,14 @@ func (WebSocketNotConnectedError) Error() string {
 	return "websocket is not connected"
 }
 
+// Возвращается при ожидании готовности клиента, который уже закрыт.
+type ClientClosedError struct{}
+
+// Возвращает текстовое описание закрытого клиента.
+func (ClientClosedError) Error() string {
+	return "client is closed"
+}
+
 // Возвращается при работе с неинициализированным низкоуровневым сокетом.
 type SocketNotConnectedError struct{}
 
@@ -126,6 +137,93 @@ func (e *NetworkError) Unwrap() error {
 	return e.Err
 }
 
+// ErrConnectionLost — общая причина для errors.Is, когда запрос прерван обрывом соединения.
+var ErrConnectionLost = errors.New("connection lost")
+
+// Возвращается для запроса, ответ на который не был получен из‑за обрыва WebSocket‑соединения.
+// Неидемпотентные запросы завершаются этой ошибкой сразу, не дожидаясь таймаута.
+type ConnectionLostError struct {
+	Opcode enums.Opcode
+	Err    error
+}
+
+// Возвращает текстовое описание прерванного запроса.
+func (e *ConnectionLostError) Error() string {
+	msg := fmt.Sprintf("connection lost while waiting for response (opcode %d)", int(e.Opcode))
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap возвращает исходную ошибку соединения для поддержки errors.Is и errors.As.
+func (e *ConnectionLostError) Unwrap() error {
+	return e.Err
+}
+
+// Is позволяет сравнивать ошибку с ErrConnectionLost через errors.Is.
+func (e *ConnectionLostError) Is(target error) bool {
+	return target == ErrConnectionLost
+}
+
+// Возвращается через MaxClient.Err, когда ReconnectPolicy исчерпана и соединение восстановить не удалось.
+type ReconnectError struct {
+	Attempts int
+	Elapsed  time.Duration
+	Err      error
+}
+
+// Возвращает текстовое описание ошибки переподключения.
+func (e *ReconnectError) Error() string {
+	msg := fmt.Sprintf("reconnect failed after %d attempts (%s)", e.Attempts, e.Elapsed.Round(time.Millisecond))
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap возвращает последнюю ошибку переподключения для поддержки errors.Is и errors.As.
+func (e *ReconnectError) Unwrap() error {
+	return e.Err
+}
+
+// Возвращается из Download, когда скачанное содержимое не совпало с ожидаемым
+// размером (Check == "size") или контрольной суммой (Check == "sha256").
+type DownloadIntegrityError struct {
+	Check    string
+	Expected string
+	Actual   string
+}
+
+// Возвращает текстовое описание несовпадения.
+func (e *DownloadIntegrityError) Error() string {
+	return fmt.Sprintf("download %s mismatch: expected %s, got %s", e.Check, e.Expected, e.Actual)
+}
+
+// Возвращается шагом AuthFlow, вызванным не в том состоянии потока
+// (например, SubmitPassword до того, как сервер запросил пароль).
+type AuthFlowStepError struct {
+	Expected AuthStep
+	Actual   AuthStep
+}
+
+// Возвращает текстовое описание несоответствия шага.
+func (e *AuthFlowStepError) Error() string {
+	return fmt.Sprintf("auth flow: expected step %q, current step is %q", e.Expected, e.Actual)
+}
+
+// Возвращается AuthFlow, если номер потока не совпадает с номером клиента:
+// выданный токен сохраняется в сессию клиента, поэтому чужой номер авторизовать нельзя.
+type AuthFlowPhoneError struct {
+	Phone       string
+	ClientPhone string
+}
+
+// Возвращает текстовое описание несоответствия номеров.
+func (e *AuthFlowPhoneError) Error() string {
+	return fmt.Sprintf("auth flow: phone %s does not match client phone %s", e.Phone, e.ClientPhone)
+}
+
 // Сигнализирует о временной сетевой ошибке, которую можно повторить.
 type TemporaryError struct {
 	Err error
@@ -224,31 +322,33 @@ func HandleError(data map[string]any) error {
 		return &ResponseError{Message: "invalid response structure"}
 	}
 
-	errCode, ok := payload["error"].(string)
-	if !ok || errCode == "" {
-
-		return nil
-	}
-
+	errCode, _ := payload["error"].(string)
 	message, _ := payload["message"].(string)
 	title, _ := payload["title"].(string)
 	localizedMessage, _ := payload["localizedMessage"].(string)
 
-	if errCode == "too.many.requests" {
-		return &RateLimitError{
-			Err: &Error{
-				Code:             errCode,
-				Message:          message,
-				Title:            title,
-				LocalizedMessage: localizedMessage,
-			},
-		}
-	}
-
-	return &Error{
-		Code:             errCode,
+	return newProtocolError(errorPayload{
+		Error:            errCode,
 		Message:          message,
 		Title:            title,
 		LocalizedMessage: localizedMessage,
+	})
+}
+
+// Формирует Go‑ошибку из полей error‑payload или возвращает nil, если кода ошибки нет.
+func newProtocolError(p errorPayload) error {
+	if p.Error == "" {
+		return nil
+	}
+
+	maxErr := &Error{
+		Code:             p.Error,
+		Message:          p.Message,
+		Title:            p.Title,
+		LocalizedMessage: p.LocalizedMessage,
+	}
+	if p.Error == "too.many.requests" {
+		return &RateLimitError{Err: maxErr}
 	}
+	return maxErr
 }
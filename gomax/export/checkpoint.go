@@ -0,0 +1,77 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Прогресс экспорта, сохраняемый между запусками.
+type checkpoint struct {
+	ChatID int64  `json:"chatId"`
+	Format Format `json:"format"`
+	// LastTime — время последнего записанного сообщения, LastIDs — записанные сообщения с этим временем.
+	LastTime int64   `json:"lastTime"`
+	LastIDs  []int64 `json:"lastIds"`
+	// Offset — размер архива в байтах после последней записанной пачки.
+	Offset int64 `json:"offset"`
+	Count  int   `json:"count"`
+}
+
+// Читает чекпоинт; отсутствие файла не ошибка и даёт nil.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// Атомарно сохраняет чекпоинт через временный файл.
+func (cp *checkpoint) save(path string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Сообщает, было ли сообщение уже записано предыдущим запуском.
+// История обходится по возрастанию времени, поэтому повториться могут только сообщения с LastTime.
+func (cp *checkpoint) exported(msg *types.Message) bool {
+	if msg.Time != cp.LastTime {
+		return msg.Time < cp.LastTime
+	}
+	for _, id := range cp.LastIDs {
+		if id == msg.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// Продвигает чекпоинт после записи пачки, упорядоченной по времени.
+func (cp *checkpoint) advance(batch []*types.Message, offset int64) {
+	for _, msg := range batch {
+		if msg.Time != cp.LastTime {
+			cp.LastTime = msg.Time
+			cp.LastIDs = cp.LastIDs[:0]
+		}
+		cp.LastIDs = append(cp.LastIDs, msg.ID)
+	}
+	cp.Offset = offset
+	cp.Count += len(batch)
+}
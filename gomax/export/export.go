@@ -0,0 +1,297 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fresh-milkshake/gomax"
+	"github.com/fresh-milkshake/gomax/internal/constants"
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Формат архива экспорта.
+type Format string
+
+const (
+	FormatJSONL    Format = "jsonl"
+	FormatHTML     Format = "html"
+	FormatMarkdown Format = "md"
+)
+
+// Параметры экспорта чата.
+type Options struct {
+	// Format — формат архива. По умолчанию FormatJSONL.
+	Format Format
+	// Dir — каталог, в который пишутся архив, чекпоинт и каталог с вложениями.
+	Dir string
+	// Media — скачивать вложения (фото, видео, файлы, аудио) в каталог рядом с архивом.
+	Media bool
+	// PageSize — размер страницы истории и пачки между сохранениями чекпоинта.
+	// По умолчанию constants.DefaultHistoryPageSize.
+	PageSize int
+	// Since и Until ограничивают экспорт по времени сообщений (включительно).
+	// Since учитывается только при первом запуске: при возобновлении экспорт продолжается с чекпоинта.
+	Since time.Time
+	Until time.Time
+	// Progress вызывается после записи каждой пачки с общим числом экспортированных сообщений.
+	Progress func(exported int)
+}
+
+// Итог экспорта.
+type Result struct {
+	// Archive — путь к файлу архива.
+	Archive string
+	// MediaDir — каталог с вложениями (пустой, если Media выключен).
+	MediaDir string
+	// Exported — число сообщений в архиве с учётом предыдущих запусков.
+	Exported int
+	// Added — число сообщений, добавленных этим запуском.
+	Added int
+	// MediaFailed — число вложений, которые не удалось скачать; ошибка сохранена в записи сообщения.
+	MediaFailed int
+	// Resumed равен true, если экспорт продолжен с чекпоинта.
+	Resumed bool
+}
+
+// Экспортирует историю чата chatID в самодостаточный архив в opts.Dir.
+//
+// Архив называется chat_<id>.<format>, вложения кладутся в chat_<id>_files/, а прогресс
+// сохраняется в chat_<id>.<format>.checkpoint.json после каждой пачки сообщений. Повторный вызов
+// с тем же каталогом продолжает экспорт с чекпоинта: прерванная пачка отбрасывается и
+// выгружается заново, а после завершения догружаются сообщения, пришедшие с прошлого запуска.
+func Export(ctx context.Context, client *gomax.MaxClient, chatID int64, opts Options) (*Result, error) {
+	if opts.Format == "" {
+		opts.Format = FormatJSONL
+	}
+	w, err := newWriter(opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = constants.DefaultHistoryPageSize
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	base := fmt.Sprintf("chat_%d", chatID)
+	res := &Result{Archive: filepath.Join(opts.Dir, base+"."+string(opts.Format))}
+	cpPath := filepath.Join(opts.Dir, base+"."+string(opts.Format)+".checkpoint.json")
+
+	cp, err := loadCheckpoint(cpPath)
+	if err != nil {
+		return nil, err
+	}
+	if cp != nil && (cp.ChatID != chatID || cp.Format != opts.Format) {
+		return nil, fmt.Errorf("checkpoint %s belongs to chat %d in %s format", cpPath, cp.ChatID, cp.Format)
+	}
+
+	e := &exporter{
+		client: client,
+		chatID: chatID,
+		opts:   opts,
+		w:      w,
+		names:  map[int64]string{},
+	}
+	if opts.Media {
+		res.MediaDir = filepath.Join(opts.Dir, base+"_files")
+		if err := os.MkdirAll(res.MediaDir, 0o755); err != nil {
+			return nil, err
+		}
+		e.mediaDir = res.MediaDir
+	}
+
+	f, err := os.OpenFile(res.Archive, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	since := opts.Since
+	if cp == nil {
+		if err := f.Truncate(0); err != nil {
+			return nil, err
+		}
+		bw := bufio.NewWriter(f)
+		if err := w.header(bw, e.chatTitle(ctx)); err != nil {
+			return nil, err
+		}
+		if err := bw.Flush(); err != nil {
+			return nil, err
+		}
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		cp = &checkpoint{ChatID: chatID, Format: opts.Format, Offset: offset}
+	} else {
+		// Отбрасываем всё, что было записано после последнего чекпоинта, включая закрывающий блок формата.
+		if err := f.Truncate(cp.Offset); err != nil {
+			return nil, err
+		}
+		since = time.UnixMilli(cp.LastTime)
+		res.Resumed = true
+	}
+	if _, err := f.Seek(cp.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	it := client.HistoryIterator(chatID, gomax.HistoryForward, opts.PageSize, gomax.HistoryOptions{
+		Since: since,
+		Until: opts.Until,
+	})
+
+	bw := bufio.NewWriter(f)
+	batch := make([]*types.Message, 0, opts.PageSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		failed, err := e.writeBatch(ctx, bw, batch)
+		res.MediaFailed += failed
+		if err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		cp.advance(batch, offset)
+		res.Added += len(batch)
+		if err := cp.save(cpPath); err != nil {
+			return err
+		}
+		if opts.Progress != nil {
+			opts.Progress(cp.Count)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for it.Next(ctx) {
+		msg := it.Value()
+		if cp.exported(msg) {
+			continue
+		}
+		batch = append(batch, msg)
+		if len(batch) >= opts.PageSize {
+			if err := flush(); err != nil {
+				return res, err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		// Сохраняем уже полученное, чтобы следующий запуск продолжил с этого места.
+		err = errors.Join(err, flush())
+		res.Exported = cp.Count
+		return res, err
+	}
+	if err := flush(); err != nil {
+		return res, err
+	}
+
+	if err := w.footer(bw); err != nil {
+		return res, err
+	}
+	if err := bw.Flush(); err != nil {
+		return res, err
+	}
+	res.Exported = cp.Count
+	return res, f.Sync()
+}
+
+// Состояние одного запуска экспорта.
+type exporter struct {
+	client   *gomax.MaxClient
+	chatID   int64
+	opts     Options
+	w        writer
+	mediaDir string
+	names    map[int64]string
+}
+
+// Возвращает название чата для заголовка архива или "Chat <id>", если его не удалось получить.
+func (e *exporter) chatTitle(ctx context.Context) string {
+	chat, err := e.client.GetChat(ctx, e.chatID)
+	if err == nil && chat != nil && chat.Title != nil && *chat.Title != "" {
+		return *chat.Title
+	}
+	return fmt.Sprintf("Chat %d", e.chatID)
+}
+
+// Разрешает отправителей, скачивает вложения и записывает пачку сообщений.
+// Возвращает число вложений, которые не удалось скачать.
+func (e *exporter) writeBatch(ctx context.Context, w io.Writer, batch []*types.Message) (int, error) {
+	if err := e.resolveSenders(ctx, batch); err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, msg := range batch {
+		rec := newRecord(e.chatID, msg, e.names)
+		if e.mediaDir != "" {
+			for i := range rec.Attachments {
+				if err := e.download(ctx, msg, i, &rec.Attachments[i]); err != nil {
+					if ctx.Err() != nil {
+						return failed, ctx.Err()
+					}
+					rec.Attachments[i].Error = err.Error()
+					failed++
+				}
+			}
+		}
+		if err := e.w.record(w, rec); err != nil {
+			return failed, err
+		}
+	}
+	return failed, nil
+}
+
+// Загружает через GetUsers имена отправителей, которых ещё нет в кэше.
+func (e *exporter) resolveSenders(ctx context.Context, batch []*types.Message) error {
+	var ids []int64
+	queued := map[int64]bool{}
+	for _, msg := range batch {
+		if msg.Sender == nil {
+			continue
+		}
+		id := *msg.Sender
+		if _, ok := e.names[id]; ok || queued[id] {
+			continue
+		}
+		queued[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	users, err := e.client.GetUsers(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u != nil {
+			e.names[u.ID] = displayName(u.Names)
+		}
+	}
+	// Неизвестных отправителей запоминаем с пустым именем, чтобы не запрашивать их снова.
+	for _, id := range ids {
+		if _, ok := e.names[id]; !ok {
+			e.names[id] = ""
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fresh-milkshake/gomax"
+	"github.com/fresh-milkshake/gomax/logger"
+	"github.com/fresh-milkshake/gomax/mockserver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testChatID int64 = 12345
+	testUserID int64 = 11111
+)
+
+// newTestClient создаёт и запускает клиента поверх мок‑сервера.
+func newTestClient(t *testing.T, server *mockserver.MockServer) *gomax.MaxClient {
+	client, err := gomax.NewMaxClient(gomax.ClientConfig{
+		Phone:   "+79991234567",
+		URI:     server.URL(),
+		WorkDir: t.TempDir(),
+		Token:   "test_auth_token_12345",
+		Logger:  logger.Nop(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, client.Start(mockserver.TestContext(t)))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// serveChat настраивает мок‑сервер на чат с сообщениями messages (по возрастанию времени),
+// пользователя testUserID и выдачу ссылок на файлы. fail, если не nil, возвращает true,
+// когда запрос истории с данным from должен завершиться ошибкой.
+func serveChat(server *mockserver.MockServer, media *httptest.Server, messages *[]map[string]any, fail func(from int64) bool) {
+	server.SetHandler(mockserver.OpcodeChatInfo, func(msg map[string]any) map[string]any {
+		return mockserver.GetChatsResponse(0, []map[string]any{
+			mockserver.TestChat(testChatID, mockserver.ChatTypeChat, "Legal <hold>"),
+		})
+	})
+	server.SetHandler(mockserver.OpcodeContactInfo, func(msg map[string]any) map[string]any {
+		return mockserver.GetUsersResponse(0, []map[string]any{
+			mockserver.TestUser(testUserID, "Иван", "Иванов", "+79990000000"),
+		})
+	})
+	server.SetHandler(mockserver.OpcodeFileDownload, func(msg map[string]any) map[string]any {
+		return mockserver.GetFileByIdResponse(0, media.URL+"/file")
+	})
+	server.SetHandler(mockserver.OpcodeChatHistory, func(msg map[string]any) map[string]any {
+		payload, _ := msg["payload"].(map[string]any)
+		from := int64(payload["from"].(float64))
+		if fail != nil && fail(from) {
+			return mockserver.ErrorResponse(0, mockserver.OpcodeChatHistory, "history.failed", "history failed")
+		}
+		forward := int(payload["forward"].(float64))
+		var window []map[string]any
+		for _, m := range *messages {
+			if m["time"].(int64) >= from && len(window) < forward {
+				window = append(window, m)
+			}
+		}
+		return mockserver.FetchHistoryResponse(0, window)
+	})
+}
+
+// testMessages возвращает сообщения с временами 100..n*100, второе — с фото, третье — с файлом.
+func testMessages(media *httptest.Server, n int) []map[string]any {
+	var messages []map[string]any
+	for i := 1; i <= n; i++ {
+		m := mockserver.TestMessage(int64(i), testChatID, testUserID, "message "+string(rune('0'+i)))
+		m["sender"] = testUserID
+		m["time"] = int64(i * 100)
+		switch i {
+		case 2:
+			m["attaches"] = []map[string]any{{"_type": "PHOTO", "photo": map[string]any{"_type": "PHOTO", "photoId": 7, "baseUrl": media.URL + "/photo"}}}
+		case 3:
+			m["attaches"] = []map[string]any{{"_type": "FILE", "file": map[string]any{"_type": "FILE", "id": 9, "name": "report (final).pdf", "size": 4}}}
+		}
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+func newMediaServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/photo" {
+			w.Header().Set("Content-Type", "image/jpeg")
+		}
+		w.Write([]byte("data"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func readRecords(t *testing.T, path string) []Record {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		records = append(records, rec)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+// TestExport_JSONL проверяет экспорт в JSON Lines с именами отправителей и скачиванием вложений.
+func TestExport_JSONL(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+	media := newMediaServer(t)
+	messages := testMessages(media, 5)
+	serveChat(server, media, &messages, nil)
+
+	client := newTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+	dir := t.TempDir()
+
+	var progress int
+	res, err := Export(ctx, client, testChatID, Options{
+		Dir:      dir,
+		Media:    true,
+		PageSize: 2,
+		Progress: func(n int) { progress = n },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 5, res.Exported)
+	assert.Equal(t, 5, progress)
+	assert.Zero(t, res.MediaFailed)
+	assert.False(t, res.Resumed)
+
+	records := readRecords(t, res.Archive)
+	require.Len(t, records, 5)
+	for i, rec := range records {
+		assert.Equal(t, int64(i+1), rec.ID)
+		assert.Equal(t, "Иван Иванов", rec.SenderName)
+	}
+
+	require.Len(t, records[1].Attachments, 1)
+	assert.Equal(t, "chat_12345_files/2_1.jpg", records[1].Attachments[0].Path)
+	require.Len(t, records[2].Attachments, 1)
+	assert.Equal(t, "chat_12345_files/3_1_report (final).pdf", records[2].Attachments[0].Path)
+
+	data, err := os.ReadFile(filepath.Join(dir, records[2].Attachments[0].Path))
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+}
+
+// TestExport_Resume проверяет продолжение экспорта с чекпоинта после ошибки
+// и догрузку новых сообщений повторным запуском.
+func TestExport_Resume(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+	media := newMediaServer(t)
+	messages := testMessages(media, 5)
+
+	var broken atomic.Bool
+	broken.Store(true)
+	serveChat(server, media, &messages, func(from int64) bool {
+		return broken.Load() && from > 200
+	})
+
+	client := newTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+	dir := t.TempDir()
+	opts := Options{Dir: dir, Format: FormatHTML, PageSize: 2}
+
+	res, err := Export(ctx, client, testChatID, opts)
+	require.Error(t, err)
+	assert.Equal(t, 3, res.Exported)
+
+	broken.Store(false)
+	res, err = Export(ctx, client, testChatID, opts)
+	require.NoError(t, err)
+	assert.True(t, res.Resumed)
+	assert.Equal(t, 2, res.Added)
+	assert.Equal(t, 5, res.Exported)
+
+	messages = append(messages, testMessages(media, 6)[5])
+	res, err = Export(ctx, client, testChatID, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Added)
+	assert.Equal(t, 6, res.Exported)
+
+	data, err := os.ReadFile(res.Archive)
+	require.NoError(t, err)
+	page := string(data)
+	assert.Equal(t, 1, strings.Count(page, "<h1>Legal &lt;hold&gt;</h1>"))
+	assert.Equal(t, 1, strings.Count(page, "</html>"))
+	assert.True(t, strings.HasSuffix(page, "</html>\n"))
+	for i := 1; i <= 6; i++ {
+		assert.Equal(t, 1, strings.Count(page, `id="m`+string(rune('0'+i))+`"`), "message %d", i)
+	}
+}
+
+// TestExport_Markdown проверяет рендеринг Markdown и независимость архивов разных форматов.
+func TestExport_Markdown(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+	media := newMediaServer(t)
+	messages := testMessages(media, 3)
+	serveChat(server, media, &messages, nil)
+
+	client := newTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+	dir := t.TempDir()
+
+	res, err := Export(ctx, client, testChatID, Options{Dir: dir, Format: FormatMarkdown, Media: true})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(res.Archive)
+	require.NoError(t, err)
+	md := string(data)
+	assert.True(t, strings.HasPrefix(md, "# Legal <hold>\n"))
+	assert.Contains(t, md, "**Иван Иванов**")
+	assert.Contains(t, md, "> message 1\n")
+	assert.Contains(t, md, "![photo](chat_12345_files/2_1.jpg)")
+	assert.Contains(t, md, "[report (final).pdf](chat_12345_files/3_1_report%20%28final%29.pdf)")
+
+	res, err = Export(ctx, client, testChatID, Options{Dir: dir, Format: FormatHTML})
+	require.NoError(t, err)
+	assert.False(t, res.Resumed)
+	assert.Equal(t, 3, res.Exported)
+}
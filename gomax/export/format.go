@@ -0,0 +1,170 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/fresh-milkshake/gomax/enums"
+)
+
+// Записывает архив в конкретном формате. header пишется один раз при создании архива,
+// footer — после каждого завершённого запуска и отрезается при возобновлении.
+type writer interface {
+	header(w io.Writer, title string) error
+	record(w io.Writer, rec Record) error
+	footer(w io.Writer) error
+}
+
+// Возвращает writer для формата или ошибку для неизвестного формата.
+func newWriter(format Format) (writer, error) {
+	switch format {
+	case FormatJSONL:
+		return jsonlWriter{}, nil
+	case FormatHTML:
+		return htmlWriter{}, nil
+	case FormatMarkdown:
+		return markdownWriter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// JSON Lines: одна Record на строку, без заголовка и завершающего блока.
+type jsonlWriter struct{}
+
+func (jsonlWriter) header(io.Writer, string) error { return nil }
+
+func (jsonlWriter) record(w io.Writer, rec Record) error {
+	return json.NewEncoder(w).Encode(rec)
+}
+
+func (jsonlWriter) footer(io.Writer) error { return nil }
+
+// Статическая HTML‑страница со встроенными стилями; вложения подключаются относительными ссылками.
+type htmlWriter struct{}
+
+const htmlHeader = `<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body{font-family:sans-serif;max-width:800px;margin:2em auto;color:#222}
+.msg{padding:.5em 0;border-bottom:1px solid #eee}
+.meta{color:#888;font-size:.85em}
+.sender{font-weight:bold;color:#222}
+.text{white-space:pre-wrap;margin:.25em 0}
+.attach img{max-width:100%%;max-height:400px}
+.error{color:#b00}
+</style>
+</head>
+<body>
+<h1>%s</h1>
+`
+
+func (htmlWriter) header(w io.Writer, title string) error {
+	t := html.EscapeString(title)
+	_, err := fmt.Fprintf(w, htmlHeader, t, t)
+	return err
+}
+
+func (htmlWriter) record(w io.Writer, rec Record) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<div class=\"msg\" id=\"m%d\">\n", rec.ID)
+	fmt.Fprintf(&b, "<div class=\"meta\"><span class=\"sender\">%s</span> · %s",
+		html.EscapeString(rec.sender()), rec.Time.Format("2006-01-02 15:04:05 MST"))
+	if rec.LinkedMessageID != 0 {
+		fmt.Fprintf(&b, " · %s <a href=\"#m%d\">#%d</a>", html.EscapeString(strings.ToLower(rec.LinkType)), rec.LinkedMessageID, rec.LinkedMessageID)
+	}
+	if rec.Status != "" {
+		fmt.Fprintf(&b, " · %s", html.EscapeString(strings.ToLower(rec.Status)))
+	}
+	b.WriteString("</div>\n")
+	if rec.Text != "" {
+		fmt.Fprintf(&b, "<div class=\"text\">%s</div>\n", html.EscapeString(rec.Text))
+	}
+	for _, a := range rec.Attachments {
+		b.WriteString("<div class=\"attach\">")
+		switch {
+		case a.Path != "" && (a.Type == enums.AttachTypePhoto || a.Type == enums.AttachTypeSticker):
+			fmt.Fprintf(&b, "<img src=\"%s\" alt=\"%s\">", html.EscapeString(a.Path), html.EscapeString(a.label()))
+		case a.Path != "":
+			fmt.Fprintf(&b, "<a href=\"%s\">%s</a>", html.EscapeString(a.Path), html.EscapeString(a.label()))
+		default:
+			b.WriteString(html.EscapeString(a.label()))
+		}
+		if a.Error != "" {
+			fmt.Fprintf(&b, " <span class=\"error\">(%s)</span>", html.EscapeString(a.Error))
+		}
+		b.WriteString("</div>\n")
+	}
+	b.WriteString("</div>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (htmlWriter) footer(w io.Writer) error {
+	_, err := io.WriteString(w, "</body>\n</html>\n")
+	return err
+}
+
+// Markdown: заголовок чата и по абзацу на сообщение.
+type markdownWriter struct{}
+
+func (markdownWriter) header(w io.Writer, title string) error {
+	_, err := fmt.Fprintf(w, "# %s\n\n", title)
+	return err
+}
+
+func (markdownWriter) record(w io.Writer, rec Record) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s** · %s", rec.sender(), rec.Time.Format("2006-01-02 15:04:05 MST"))
+	if rec.LinkedMessageID != 0 {
+		fmt.Fprintf(&b, " · %s #%d", strings.ToLower(rec.LinkType), rec.LinkedMessageID)
+	}
+	if rec.Status != "" {
+		fmt.Fprintf(&b, " · %s", strings.ToLower(rec.Status))
+	}
+	b.WriteString("\n\n")
+	if rec.Text != "" {
+		for _, line := range strings.Split(rec.Text, "\n") {
+			b.WriteString("> " + line + "\n")
+		}
+		b.WriteString("\n")
+	}
+	for _, a := range rec.Attachments {
+		switch {
+		case a.Path != "" && (a.Type == enums.AttachTypePhoto || a.Type == enums.AttachTypeSticker):
+			fmt.Fprintf(&b, "![%s](%s)", a.label(), markdownPath(a.Path))
+		case a.Path != "":
+			fmt.Fprintf(&b, "[%s](%s)", a.label(), markdownPath(a.Path))
+		default:
+			b.WriteString(a.label())
+		}
+		if a.Error != "" {
+			fmt.Fprintf(&b, " (%s)", a.Error)
+		}
+		b.WriteString("\n\n")
+	}
+	b.WriteString("---\n\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (markdownWriter) footer(io.Writer) error { return nil }
+
+// Возвращает подпись вложения: имя файла или его тип.
+func (a Attachment) label() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return strings.ToLower(string(a.Type))
+}
+
+// Экранирует пробелы и скобки в относительной ссылке Markdown.
+func markdownPath(path string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(path)
+}
@@ -0,0 +1,130 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fresh-milkshake/gomax"
+	"github.com/fresh-milkshake/gomax/enums"
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Расширения по умолчанию, если тип содержимого определить не удалось.
+var defaultExt = map[enums.AttachType]string{
+	enums.AttachTypePhoto:   ".jpg",
+	enums.AttachTypeVideo:   ".mp4",
+	enums.AttachTypeAudio:   ".ogg",
+	enums.AttachTypeSticker: ".webp",
+}
+
+// Скачивает i‑е вложение сообщения через MaxClient.Download в каталог вложений и заполняет a.Path.
+// Уже скачанные при прошлом запуске файлы не загружаются повторно, а недокачанные
+// продолжаются с места обрыва.
+func (e *exporter) download(ctx context.Context, msg *types.Message, i int, a *Attachment) error {
+	prefix := fmt.Sprintf("%d_%d", msg.ID, i+1)
+	if path := e.existing(prefix); path != "" {
+		a.Path = e.relative(path)
+		return nil
+	}
+
+	tmp := filepath.Join(e.mediaDir, prefix+".part")
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	_, err = e.client.Download(ctx, a.attach, f, gomax.DownloadOptions{
+		ChatID:    e.chatID,
+		MessageID: msg.ID,
+		Offset:    info.Size(),
+	})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	name := prefix
+	if a.Name != "" {
+		name += "_" + sanitize(a.Name)
+	} else {
+		name += extension(a.Type, sniff(tmp))
+	}
+	path := filepath.Join(e.mediaDir, name)
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	a.Path = e.relative(path)
+	return nil
+}
+
+// Ищет вложение, скачанное при прошлом запуске: prefix, prefix.<ext> или prefix_<name>.
+func (e *exporter) existing(prefix string) string {
+	for _, pattern := range []string{prefix, prefix + ".*", prefix + "_*"} {
+		matches, _ := filepath.Glob(filepath.Join(e.mediaDir, pattern))
+		for _, p := range matches {
+			if !strings.HasSuffix(p, ".part") {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// Возвращает путь файла относительно каталога архива.
+func (e *exporter) relative(path string) string {
+	rel, err := filepath.Rel(e.opts.Dir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Определяет тип содержимого файла по первым байтам.
+func sniff(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return http.DetectContentType(head[:n])
+}
+
+// Подбирает расширение по типу содержимого, а если он неинформативен — по типу вложения.
+func extension(t enums.AttachType, contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType != "application/octet-stream" && !strings.HasPrefix(mediaType, "text/") {
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			for _, ext := range exts {
+				if ext == defaultExt[t] {
+					return ext
+				}
+			}
+			return exts[0]
+		}
+	}
+	return defaultExt[t]
+}
+
+// Убирает из имени файла разделители путей и управляющие символы.
+func sanitize(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == '/' || r == ':' {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,114 @@
+package export
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/enums"
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Запись архива об одном сообщении. В формате JSONL каждая строка — одна Record.
+type Record struct {
+	ID         int64     `json:"id"`
+	ChatID     int64     `json:"chatId"`
+	Time       time.Time `json:"time"`
+	SenderID   int64     `json:"senderId,omitempty"`
+	SenderName string    `json:"senderName,omitempty"`
+	Text       string    `json:"text"`
+	Status     string    `json:"status,omitempty"`
+	// LinkType — REPLY или FORWARD, если сообщение ссылается на другое.
+	LinkType        string       `json:"linkType,omitempty"`
+	LinkedChatID    int64        `json:"linkedChatId,omitempty"`
+	LinkedMessageID int64        `json:"linkedMessageId,omitempty"`
+	Attachments     []Attachment `json:"attachments,omitempty"`
+	// Message — исходное сообщение в том виде, в каком его вернул сервер.
+	Message *types.Message `json:"message"`
+}
+
+// Вложение сообщения в архиве.
+type Attachment struct {
+	Type enums.AttachType `json:"type"`
+	Name string           `json:"name,omitempty"`
+	Size int64            `json:"size,omitempty"`
+	// Path — путь к скачанному файлу относительно каталога архива.
+	Path string `json:"path,omitempty"`
+	// Error — причина, по которой вложение не удалось скачать.
+	Error string `json:"error,omitempty"`
+
+	attach types.Attach
+}
+
+// Собирает запись архива из сообщения и кэша имён отправителей.
+func newRecord(chatID int64, msg *types.Message, names map[int64]string) Record {
+	rec := Record{
+		ID:      msg.ID,
+		ChatID:  chatID,
+		Time:    time.UnixMilli(msg.Time).UTC(),
+		Text:    msg.Text,
+		Message: msg,
+	}
+	if msg.ChatID != nil {
+		rec.ChatID = *msg.ChatID
+	}
+	if msg.Sender != nil {
+		rec.SenderID = *msg.Sender
+		rec.SenderName = names[*msg.Sender]
+	}
+	if msg.Status != nil {
+		rec.Status = string(*msg.Status)
+	}
+	if msg.Link != nil {
+		rec.LinkType = msg.Link.Type
+		rec.LinkedChatID = msg.Link.ChatID
+		rec.LinkedMessageID = msg.Link.Message.ID
+	}
+
+	for _, a := range msg.Attaches {
+		switch {
+		case a.Photo != nil:
+			rec.Attachments = append(rec.Attachments, Attachment{Type: enums.AttachTypePhoto, attach: a})
+		case a.Video != nil:
+			rec.Attachments = append(rec.Attachments, Attachment{Type: enums.AttachTypeVideo, attach: a})
+		case a.File != nil:
+			rec.Attachments = append(rec.Attachments, Attachment{Type: enums.AttachTypeFile, Name: a.File.Name, Size: a.File.Size, attach: a})
+		case a.Audio != nil:
+			rec.Attachments = append(rec.Attachments, Attachment{Type: enums.AttachTypeAudio, attach: a})
+		case a.Sticker != nil:
+			rec.Attachments = append(rec.Attachments, Attachment{Type: enums.AttachTypeSticker, attach: a})
+		}
+	}
+	return rec
+}
+
+// Возвращает отображаемое имя пользователя: имя и фамилию либо единое поле name.
+func displayName(names []types.Names) string {
+	for _, n := range names {
+		var parts []string
+		if n.FirstName != nil && *n.FirstName != "" {
+			parts = append(parts, *n.FirstName)
+		}
+		if n.LastName != nil && *n.LastName != "" {
+			parts = append(parts, *n.LastName)
+		}
+		if len(parts) > 0 {
+			return strings.Join(parts, " ")
+		}
+		if n.Name != nil && *n.Name != "" {
+			return *n.Name
+		}
+	}
+	return ""
+}
+
+// Возвращает имя отправителя для текстовых форматов.
+func (r Record) sender() string {
+	if r.SenderName != "" {
+		return r.SenderName
+	}
+	if r.SenderID != 0 {
+		return "id" + strconv.FormatInt(r.SenderID, 10)
+	}
+	return "—"
+}
@@ -1,22 +1,33 @@
 package files
 
 import (
+	"context"
 	"io"
 	"os"
 	"path/filepath"
 )
 
 // Описывает минимальный интерфейс файла, который может быть загружен в Max.
+// Клиент загружает файлы потоково через Open и Size; Read оставлен для совместимости
+// и читает содержимое в память целиком.
 type BaseFile interface {
 	Read() ([]byte, error)
 	FileName() string
+	// Open открывает содержимое для последовательного чтения. Закрывает reader вызывающий.
+	Open() (io.ReadCloser, error)
+	// Size возвращает размер содержимого в байтах.
+	Size() (int64, error)
 }
 
 // Представляет локальный или удалённый файл, подготовленный к загрузке.
+// Удалённый файл не хранится в памяти: он скачивается потоково при каждой отправке,
+// а размер, тип и имя запоминаются после первого запроса (или явного Fetch).
 type File struct {
 	path     string
 	url      string
 	fileName string
+
+	remote *remoteSource
 }
 
 // Создаёт File из указанного пути к локальному файлу.
@@ -32,23 +43,105 @@ func NewFileFromPath(path string) (*File, error) {
 }
 
 // Создаёт File, ссылающийся на удалённый ресурс по URL.
-func NewFileFromURL(url string) *File {
+// Необязательный opts задаёт HTTP‑клиент, лимит размера и повторы при скачивании.
+func NewFileFromURL(url string, opts ...URLOptions) *File {
+	var o URLOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
 	return &File{
 		url:      url,
-		fileName: filepath.Base(url),
+		fileName: nameFromURL(url),
+		remote:   newRemoteSource(url, o),
+	}
+}
+
+// Запрашивает удалённый файл с учётом ctx, если это ещё не сделано, и определяет его размер,
+// тип и имя. Тело ответа остаётся открытым до первого OpenContext, поэтому Fetch стоит вызывать
+// непосредственно перед отправкой, а если отправка не состоялась — вызвать Release.
+// Для локального файла ничего не делает.
+func (f *File) Fetch(ctx context.Context) error {
+	if f.remote == nil {
+		return nil
+	}
+	return f.remote.fetch(ctx)
+}
+
+// Освобождает тело ответа или временный файл, оставленные Fetch и ещё не прочитанные
+// через OpenContext. Вызывайте, если после Fetch файл так и не был открыт. Для локального
+// файла ничего не делает.
+func (f *File) Release() {
+	if f.remote != nil {
+		f.remote.release()
 	}
 }
 
-// Читает содержимое файла либо с диска, либо (в дальнейшем) по URL.
+// Возвращает MIME‑тип содержимого удалённого файла: из Content-Type ответа, а если он
+// не информативен — определённый по первым байтам. Для локальных и ещё не скачанных файлов пусто.
+func (f *File) ContentType() string {
+	if f.remote == nil {
+		return ""
+	}
+	return f.remote.contentType()
+}
+
+// Читает содержимое файла с диска или по URL целиком в память.
 func (f *File) Read() ([]byte, error) {
+	return f.ReadContext(context.Background())
+}
+
+// Как Read, но скачивание удалённого файла учитывает ctx.
+func (f *File) ReadContext(ctx context.Context) ([]byte, error) {
 	if f.path != "" {
 		return os.ReadFile(f.path)
 	}
-	return nil, io.ErrUnexpectedEOF
+	r, err := f.OpenContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Открывает файл для потокового чтения.
+func (f *File) Open() (io.ReadCloser, error) {
+	return f.OpenContext(context.Background())
+}
+
+// Как Open, но скачивание удалённого файла учитывает ctx. Удалённый файл читается
+// прямо из ответа сервера; превышение URLOptions.MaxSize возвращает FileTooLargeError.
+func (f *File) OpenContext(ctx context.Context) (io.ReadCloser, error) {
+	if f.path != "" {
+		return os.Open(f.path)
+	}
+	return f.remote.open(ctx)
+}
+
+// Возвращает размер файла в байтах.
+func (f *File) Size() (int64, error) {
+	return f.SizeContext(context.Background())
+}
+
+// Как Size, но запрос удалённого файла учитывает ctx.
+func (f *File) SizeContext(ctx context.Context) (int64, error) {
+	if f.path != "" {
+		info, err := os.Stat(f.path)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	return f.remote.contentSize(ctx)
 }
 
 // Возвращает имя файла, используемое при загрузке в Max.
+// У удалённого файла после скачивания имя может уточниться по Content-Disposition и типу содержимого.
 func (f *File) FileName() string {
+	if f.remote != nil {
+		if name := f.remote.fileName(); name != "" {
+			return name
+		}
+	}
 	return f.fileName
 }
 
@@ -67,14 +160,14 @@ func NewPhotoFromPath(path string) (*Photo, error) {
 }
 
 // Создаёт Photo из URL удалённого изображения.
-func NewPhotoFromURL(url string) *Photo {
-	return &Photo{File: NewFileFromURL(url)}
+func NewPhotoFromURL(url string, opts ...URLOptions) *Photo {
+	return &Photo{File: NewFileFromURL(url, opts...)}
 }
 
 // Проверяет, что расширение фото поддерживается Max,
 // и возвращает расширение без точки и соответствующий MIME‑тип.
 func (p *Photo) ValidatePhoto() (string, string, error) {
-	ext := filepath.Ext(p.fileName)
+	ext := filepath.Ext(p.FileName())
 	allowed := map[string]string{
 		".jpg":  "image/jpeg",
 		".jpeg": "image/jpeg",
@@ -85,6 +178,14 @@ func (p *Photo) ValidatePhoto() (string, string, error) {
 	}
 	mime, ok := allowed[ext]
 	if !ok {
+		// У изображений по URL расширения может не быть: доверяем определённому типу содержимого.
+		if ct := p.ContentType(); ct != "" {
+			for e, m := range allowed {
+				if m == ct && e != ".jpeg" {
+					return e[1:], m, nil
+				}
+			}
+		}
 		return "", "", &InvalidPhotoExtensionError{Ext: ext}
 	}
 	return ext[1:], mime, nil
@@ -105,8 +206,8 @@ func NewVideoFromPath(path string) (*Video, error) {
 }
 
 // Создаёт Video из URL удалённого видео.
-func NewVideoFromURL(url string) *Video {
-	return &Video{File: NewFileFromURL(url)}
+func NewVideoFromURL(url string, opts ...URLOptions) *Video {
+	return &Video{File: NewFileFromURL(url, opts...)}
 }
 
 // Сигнализирует о недопустимом расширении файла изображения.
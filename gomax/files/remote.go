@@ -0,0 +1,403 @@
+package files
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/internal/constants"
+)
+
+// Параметры скачивания файла по URL.
+type URLOptions struct {
+	// Client — HTTP‑клиент для скачивания. По умолчанию http.DefaultClient.
+	Client *http.Client
+	// MaxSize — максимальный размер файла в байтах. По умолчанию constants.DefaultRemoteFileLimit.
+	MaxSize int64
+	// MaxRetries — число повторов при сетевых ошибках, 5xx и 429. По умолчанию constants.DefaultMaxRetries.
+	MaxRetries int
+	// RetryDelay — задержка перед первым повтором, далее растёт экспоненциально.
+	// По умолчанию constants.DefaultRetryInitialDelay.
+	RetryDelay time.Duration
+}
+
+// Сигнализирует, что удалённый файл больше допустимого URLOptions.MaxSize.
+type FileTooLargeError struct {
+	URL   string
+	Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("remote file %s exceeds size limit of %d bytes", e.URL, e.Limit)
+}
+
+// Возвращается, когда сервер ответил на запрос файла неуспешным статусом.
+type RemoteFileError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *RemoteFileError) Error() string {
+	return fmt.Sprintf("fetch %s: unexpected status %d", e.URL, e.StatusCode)
+}
+
+// Удалённый файл. Содержимое не хранится в памяти: первый запрос (Fetch) узнаёт размер,
+// тип и имя, а его тело отдаётся первому open; последующие open скачивают файл заново.
+type remoteSource struct {
+	url  string
+	opts URLOptions
+
+	mu      sync.Mutex
+	fetched bool
+	size    int64
+	ctype   string
+	name    string
+	// pending — содержимое, полученное fetch и ещё не отданное open: тело ответа
+	// или временный файл, если сервер не сообщил размер.
+	pending *remoteBody
+}
+
+func newRemoteSource(rawURL string, opts URLOptions) *remoteSource {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = int64(constants.DefaultRemoteFileLimit)
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = constants.DefaultMaxRetries
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = time.Duration(constants.DefaultRetryInitialDelay * float64(time.Second))
+	}
+	return &remoteSource{url: rawURL, opts: opts}
+}
+
+// Запрашивает файл и определяет его размер, тип и имя, если это ещё не сделано.
+func (r *remoteSource) fetch(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fetched {
+		return nil
+	}
+	return r.retry(ctx, func() (bool, error) {
+		return r.prefetch(ctx)
+	})
+}
+
+// Открывает содержимое файла для чтения с учётом ctx.
+func (r *remoteSource) open(ctx context.Context) (io.ReadCloser, error) {
+	if err := r.fetch(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	body, size := r.pending, r.size
+	r.pending = nil
+	r.mu.Unlock()
+
+	if body == nil {
+		err := r.retry(ctx, func() (retry bool, err error) {
+			body, _, retry, err = r.get(ctx)
+			return retry, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if body.want >= 0 && body.want != size {
+			body.Close()
+			return nil, fmt.Errorf("remote file %s changed size: %d bytes instead of %d", r.url, body.want, size)
+		}
+		body.want = size
+	}
+	body.bind(ctx)
+	return body, nil
+}
+
+// Закрывает содержимое, полученное fetch и не отданное open, и удаляет временный файл.
+// Следующий open скачает файл заново.
+func (r *remoteSource) release() {
+	r.mu.Lock()
+	body := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+	if body != nil {
+		body.Close()
+	}
+}
+
+// Возвращает размер файла, при необходимости запрашивая его.
+func (r *remoteSource) contentSize(ctx context.Context) (int64, error) {
+	if err := r.fetch(ctx); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.size, nil
+}
+
+// Повторяет attempt с экспоненциальной задержкой, пока он просит повтора.
+func (r *remoteSource) retry(ctx context.Context, attempt func() (retry bool, err error)) error {
+	delay := r.opts.RetryDelay
+	var lastErr error
+	for i := 0; i <= r.opts.MaxRetries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		retry, err := attempt()
+		if err == nil {
+			return nil
+		}
+		if !retry || ctx.Err() != nil {
+			return err
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// Выполняет первый запрос: определяет тип по первым байтам и оставляет тело для open.
+// Если сервер не сообщил размер, тело сохраняется во временный файл, чтобы его узнать.
+// Вызывается под r.mu.
+func (r *remoteSource) prefetch(ctx context.Context) (retry bool, err error) {
+	body, header, retry, err := r.get(ctx)
+	if err != nil {
+		return retry, err
+	}
+
+	head, _ := body.r.Peek(sniffLen)
+	ctype := sniffContentType(header.Get("Content-Type"), head)
+	if body.want < 0 {
+		body.bind(ctx)
+		if body, err = spool(body); err != nil {
+			var tooLarge *FileTooLargeError
+			return !errors.As(err, &tooLarge), err
+		}
+	}
+
+	r.pending = body
+	r.size = body.want
+	r.ctype = ctype
+	r.name = resolveName(r.url, header.Get("Content-Disposition"), ctype)
+	r.fetched = true
+	return false, nil
+}
+
+// Выполняет одну попытку GET. Возвращённое тело отвязано от ctx: его чтение
+// отменяет контекст, переданный в bind. retry сообщает, имеет ли смысл повторить.
+func (r *remoteSource) get(ctx context.Context) (body *remoteBody, header http.Header, retry bool, err error) {
+	reqCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, r.url, nil)
+	if err != nil {
+		cancel()
+		return nil, nil, false, err
+	}
+	resp, err := r.opts.Client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, nil, true, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		cancel()
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, nil, retry, &RemoteFileError{URL: r.url, StatusCode: resp.StatusCode}
+	}
+	if resp.ContentLength > r.opts.MaxSize {
+		resp.Body.Close()
+		cancel()
+		return nil, nil, false, &FileTooLargeError{URL: r.url, Limit: r.opts.MaxSize}
+	}
+
+	return &remoteBody{
+		r:      bufio.NewReaderSize(resp.Body, sniffLen),
+		closer: resp.Body,
+		url:    r.url,
+		limit:  r.opts.MaxSize,
+		want:   resp.ContentLength,
+		cancel: cancel,
+	}, resp.Header, false, nil
+}
+
+// Сохраняет тело неизвестной длины во временный файл, удаляемый при закрытии результата.
+func spool(body *remoteBody) (*remoteBody, error) {
+	defer body.Close()
+
+	tmp, err := os.CreateTemp("", "gomax-remote-*")
+	if err != nil {
+		return nil, err
+	}
+	n, err := io.Copy(tmp, body)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return &remoteBody{
+		r:      bufio.NewReader(tmp),
+		closer: tempFile{tmp},
+		url:    body.url,
+		limit:  body.limit,
+		want:   n,
+		cancel: func() {},
+	}, nil
+}
+
+// Временный файл, удаляемый при закрытии.
+type tempFile struct {
+	*os.File
+}
+
+func (f tempFile) Close() error {
+	err := f.File.Close()
+	if rmErr := os.Remove(f.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// Сколько первых байт нужно для определения типа содержимого (как у http.DetectContentType).
+const sniffLen = 512
+
+// Поток содержимого удалённого файла. Лимит размера проверяется по числу прочитанных байт,
+// а расхождение с ожидаемым размером считается ошибкой чтения.
+type remoteBody struct {
+	r      *bufio.Reader
+	closer io.Closer
+	url    string
+	limit  int64
+	// want — ожидаемый размер или -1, если он неизвестен.
+	want int64
+	read int64
+
+	cancel context.CancelFunc
+	stop   func() bool
+}
+
+// Привязывает чтение к ctx: его отмена прерывает скачивание.
+func (b *remoteBody) bind(ctx context.Context) {
+	if b.stop != nil {
+		b.stop()
+	}
+	b.stop = context.AfterFunc(ctx, b.cancel)
+}
+
+func (b *remoteBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	switch {
+	case b.read > b.limit:
+		return n, &FileTooLargeError{URL: b.url, Limit: b.limit}
+	case b.want >= 0 && b.read > b.want:
+		return n, fmt.Errorf("remote file %s is longer than %d bytes", b.url, b.want)
+	case err == io.EOF && b.want >= 0 && b.read < b.want:
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (b *remoteBody) Close() error {
+	if b.stop != nil {
+		b.stop()
+	}
+	b.cancel()
+	return b.closer.Close()
+}
+
+// Возвращает определённый MIME‑тип содержимого.
+func (r *remoteSource) contentType() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ctype
+}
+
+// Возвращает имя файла, определённое при скачивании, или пустую строку до него.
+func (r *remoteSource) fileName() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.name
+}
+
+// Выбирает MIME‑тип: из заголовка, если он информативен, иначе по первым байтам содержимого.
+func sniffContentType(header string, data []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(header); err == nil && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	return mediaType
+}
+
+// Определяет имя файла: имя из пути URL, а если его нет — из Content-Disposition.
+// Если у итогового имени нет расширения, оно подбирается по типу содержимого.
+func resolveName(rawURL, disposition, contentType string) string {
+	name := nameFromURL(rawURL)
+	if name == "" || path.Ext(name) == "" {
+		if _, params, err := mime.ParseMediaType(disposition); err == nil {
+			if fn := path.Base(strings.ReplaceAll(params["filename"], "\\", "/")); fn != "" && fn != "." && fn != "/" {
+				name = fn
+			}
+		}
+	}
+	if name == "" {
+		name = "file"
+	}
+	if path.Ext(name) == "" {
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			name += preferredExt(contentType, exts)
+		}
+	}
+	return name
+}
+
+// Возвращает последний сегмент пути URL без query и fragment или пустую строку.
+func nameFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// Выбирает привычное расширение для распространённых типов вместо первого по алфавиту.
+func preferredExt(contentType string, exts []string) string {
+	common := map[string]string{
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+		"image/gif":  ".gif",
+		"image/webp": ".webp",
+		"video/mp4":  ".mp4",
+		"text/plain": ".txt",
+	}
+	if ext, ok := common[contentType]; ok {
+		return ext
+	}
+	return exts[0]
+}
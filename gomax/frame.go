@@ -0,0 +1,96 @@
+package gomax
+
+import (
+	"encoding/json"
+
+	"github.com/fresh-milkshake/gomax/enums"
+	"github.com/fresh-milkshake/gomax/internal/constants"
+	"github.com/fresh-milkshake/gomax/internal/utils"
+)
+
+// Frame — кадр WebSocket‑протокола Max. Payload хранится в сыром виде
+// и декодируется один раз сразу в нужную структуру через Decode.
+type Frame struct {
+	Ver     int             `json:"ver"`
+	Cmd     int             `json:"cmd"`
+	Seq     int             `json:"seq"`
+	Opcode  enums.Opcode    `json:"opcode"`
+	Payload json.RawMessage `json:"payload"`
+
+	// handshake помечает исходящие кадры reconnect: только их можно писать
+	// в новый сокет до завершения авторизации.
+	handshake bool
+}
+
+// Описывает стандартные поля ошибки в payload ответа Max.
+type errorPayload struct {
+	Error            string `json:"error"`
+	Message          string `json:"message"`
+	Title            string `json:"title"`
+	LocalizedMessage string `json:"localizedMessage"`
+}
+
+// Собирает исходящий кадр, сериализуя payload (map или структуру с json‑тегами).
+func newFrame(seq int, opcode enums.Opcode, payload any) (*Frame, error) {
+	raw, err := encodePayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{
+		Ver:     constants.ProtocolVersion,
+		Cmd:     constants.ProtocolCommand,
+		Seq:     seq,
+		Opcode:  opcode,
+		Payload: raw,
+	}, nil
+}
+
+// Сериализует payload запроса; nil превращается в пустой объект, как ожидает сервер.
+func encodePayload(payload any) (json.RawMessage, error) {
+	switch p := payload.(type) {
+	case nil:
+		return json.RawMessage("{}"), nil
+	case json.RawMessage:
+		return p, nil
+	}
+	data, err := utils.JSONMarshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Decode декодирует payload кадра в v. Пустой payload оставляет v без изменений.
+func (f *Frame) Decode(v any) error {
+	if len(f.Payload) == 0 {
+		return nil
+	}
+	return utils.JSONUnmarshal(f.Payload, v)
+}
+
+// Err возвращает ошибку протокола из payload кадра (включая RateLimitError) или nil.
+// Аналог HandleError для типизированного кадра.
+func (f *Frame) Err() error {
+	if len(f.Payload) == 0 {
+		return &ResponseError{Message: "invalid response structure"}
+	}
+	var p errorPayload
+	if err := utils.JSONUnmarshal(f.Payload, &p); err != nil {
+		// Payload может быть не объектом (например, массивом) — это не ошибка протокола.
+		return nil
+	}
+	return newProtocolError(p)
+}
+
+// Map возвращает кадр в виде map[string]any, как он выглядит после json.Unmarshal.
+func (f *Frame) Map() map[string]any {
+	data, err := utils.JSONMarshal(f)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if err := utils.JSONUnmarshal(data, &m); err != nil {
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,128 @@
+package gomax
+
+import (
+	"context"
+	"sort"
+
+	"github.com/fresh-milkshake/gomax/enums"
+	"github.com/fresh-milkshake/gomax/internal/constants"
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Хранит позицию клиента в чате: время последнего полученного сообщения
+// и ограниченное множество уже доставленных ID для дедупликации.
+type chatCursor struct {
+	lastTime int64
+	seen     map[int64]struct{}
+	order    []int64
+}
+
+// Запоминает сообщение чата и сдвигает отметку последнего времени.
+// Возвращает false, если сообщение с таким ID уже было доставлено обработчикам.
+func (c *MaxClient) trackMessage(chatID int64, message *types.Message) bool {
+	c.gapMu.Lock()
+	defer c.gapMu.Unlock()
+
+	cur, ok := c.chatCursors[chatID]
+	if !ok {
+		cur = &chatCursor{seen: make(map[int64]struct{})}
+		c.chatCursors[chatID] = cur
+	}
+	if message.Time > cur.lastTime {
+		cur.lastTime = message.Time
+	}
+
+	if _, dup := cur.seen[message.ID]; dup {
+		return false
+	}
+	cur.seen[message.ID] = struct{}{}
+	cur.order = append(cur.order, message.ID)
+	if len(cur.order) > constants.DefaultGapSeenIDs {
+		delete(cur.seen, cur.order[0])
+		cur.order = cur.order[1:]
+	}
+	return true
+}
+
+// После успешного reconnect догружает через FetchHistory сообщения, пришедшие
+// во все известные чаты за время простоя, и доставляет их обработчикам OnMessage
+// с пометкой Replayed.
+func (c *MaxClient) recoverGap(ctx context.Context) {
+	defer c.bgWG.Done()
+
+	c.gapMu.Lock()
+	since := make(map[int64]int64, len(c.chatCursors))
+	for chatID, cur := range c.chatCursors {
+		since[chatID] = cur.lastTime
+	}
+	c.gapMu.Unlock()
+
+	if len(since) == 0 {
+		return
+	}
+	c.logger.Debug("Recovering missed messages after reconnect", "chats", len(since))
+
+	total := 0
+	for chatID, from := range since {
+		if ctx.Err() != nil {
+			return
+		}
+		n, err := c.backfillChat(ctx, chatID, from)
+		total += n
+		if err != nil {
+			c.logger.Warn("Failed to recover missed messages", "chatID", chatID, "err", err)
+		}
+	}
+	if total > 0 {
+		c.logger.Info("Recovered missed messages after reconnect", "count", total)
+	}
+}
+
+// Постранично загружает историю чата начиная с from и доставляет ещё не виденные сообщения.
+// Возвращает число доставленных сообщений.
+func (c *MaxClient) backfillChat(ctx context.Context, chatID int64, from int64) (int, error) {
+	delivered := 0
+	for page := 0; page < constants.DefaultGapMaxPages; page++ {
+		messages, err := c.FetchHistory(ctx, chatID, &from, constants.DefaultGapPageSize, 0)
+		if err != nil {
+			return delivered, err
+		}
+		sort.Slice(messages, func(i, j int) bool {
+			return messages[i].Time < messages[j].Time
+		})
+
+		next := from
+		for _, message := range messages {
+			if message.Time < from {
+				continue
+			}
+			if message.Time > next {
+				next = message.Time
+			}
+			if message.Status != nil && *message.Status == enums.MessageStatusRemoved {
+				continue
+			}
+			if message.ChatID == nil {
+				id := chatID
+				message.ChatID = &id
+			}
+			if !c.trackMessage(chatID, message) {
+				continue
+			}
+			c.countUnread(message)
+			message.Replayed = true
+			for _, h := range c.onMessageHandlers {
+				if h.filter == nil || h.filter.Match(message) {
+					go h.handler(ctx, message)
+				}
+			}
+			delivered++
+		}
+
+		if len(messages) < constants.DefaultGapPageSize || next == from {
+			break
+		}
+		from = next
+	}
+	return delivered, nil
+}
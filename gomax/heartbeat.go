@@ -0,0 +1,108 @@
+package gomax
+
+import (
+	"context"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/enums"
+)
+
+// startHeartbeat останавливает предыдущий heartbeat (если он был) и запускает новый
+// для текущего соединения. Вызывается после Start и после каждого успешного reconnect.
+func (c *MaxClient) startHeartbeat(ctx context.Context) {
+	hbCtx, cancel := context.WithCancel(ctx)
+
+	c.pingMu.Lock()
+	if c.heartbeatCancel != nil {
+		c.heartbeatCancel()
+	}
+	c.heartbeatCancel = cancel
+	c.missedPings = 0
+	c.pingMu.Unlock()
+
+	c.bgWG.Add(1)
+	go c.heartbeatLoop(hbCtx)
+}
+
+// heartbeatLoop периодически отправляет PING и измеряет время ответа.
+// Если подряд не получено MaxMissedPings ответов, соединение закрывается,
+// чтобы recvLoop обнаружил разрыв и запустил переподключение.
+func (c *MaxClient) heartbeatLoop(ctx context.Context) {
+	defer c.bgWG.Done()
+
+	ticker := time.NewTicker(c.cfg.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !c.isWsConnected() {
+			continue
+		}
+
+		rtt, err := c.ping(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			c.pingMu.Lock()
+			c.pingRTT = rtt
+			c.missedPings = 0
+			c.pingMu.Unlock()
+			c.logger.Debug("PING answered", "rtt", rtt)
+			continue
+		}
+
+		c.pingMu.Lock()
+		c.missedPings++
+		missed := c.missedPings
+		c.pingMu.Unlock()
+		c.logger.Warn("PING not answered", "err", err, "missed", missed)
+
+		if missed >= c.cfg.MaxMissedPings {
+			c.logger.Warn("Connection considered dead, forcing reconnect", "missed", missed)
+			c.connMu.Lock()
+			if c.ws != nil {
+				_ = c.ws.Close()
+			}
+			c.isConnected = false
+			c.connMu.Unlock()
+			return
+		}
+	}
+}
+
+// ping отправляет один PING и возвращает время до получения ответа.
+// Ожидание ответа ограничено интервалом heartbeat.
+func (c *MaxClient) ping(ctx context.Context) (time.Duration, error) {
+	pingCtx, cancel := context.WithTimeout(ctx, c.cfg.PingInterval)
+	defer cancel()
+
+	started := time.Now()
+	if err := c.request(pingCtx, enums.OpcodePing, map[string]any{
+		"interactive": true,
+	}, nil); err != nil {
+		return 0, err
+	}
+	return time.Since(started), nil
+}
+
+// PingRTT возвращает время ответа на последний успешный PING.
+// До первого ответа возвращает 0. Потокобезопасен.
+func (c *MaxClient) PingRTT() time.Duration {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	return c.pingRTT
+}
+
+// MissedPings возвращает число подряд неотвеченных PING для текущего соединения.
+// Потокобезопасен.
+func (c *MaxClient) MissedPings() int {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	return c.missedPings
+}
@@ -0,0 +1,206 @@
+package gomax
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/internal/constants"
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Направление обхода истории чата.
+type HistoryDirection int
+
+const (
+	// HistoryBackward — от новых сообщений к старым (по умолчанию).
+	HistoryBackward HistoryDirection = iota
+	// HistoryForward — от старых сообщений к новым.
+	HistoryForward
+)
+
+// Возвращает текстовое представление направления.
+func (d HistoryDirection) String() string {
+	switch d {
+	case HistoryBackward:
+		return "backward"
+	case HistoryForward:
+		return "forward"
+	default:
+		return "unknown"
+	}
+}
+
+// Условия остановки обхода истории. Нулевое значение — вся история чата.
+type HistoryOptions struct {
+	// Since — нижняя граница времени сообщений (включительно). Нулевое значение — без ограничения.
+	Since time.Time
+	// Until — верхняя граница времени сообщений (включительно). Нулевое значение — без ограничения.
+	Until time.Time
+	// Limit — максимальное число сообщений. 0 — без ограничения.
+	Limit int
+}
+
+// Возвращает итератор по истории чата, который сам загружает страницы через FetchHistory.
+// Сообщения отдаются строго по времени в выбранном направлении, каждое — ровно один раз,
+// даже если соседние страницы пересекаются на границе окна.
+//
+// Сервер листает историю только по времени, поэтому если в одну миллисекунду попало
+// больше pageSize сообщений, итератор запрашивает её снова окном вдвое больше, пока не
+// получит их все. Сдвиг на следующую миллисекунду делается, только если сервер перестал
+// отдавать новые сообщения даже в увеличенном окне.
+func (c *MaxClient) HistoryIterator(chatID int64, direction HistoryDirection, pageSize int, opts HistoryOptions) *Iterator[*types.Message] {
+	if pageSize <= 0 {
+		pageSize = constants.DefaultHistoryPageSize
+	}
+	w := &historyWindow{
+		client:    c,
+		chatID:    chatID,
+		direction: direction,
+		pageSize:  pageSize,
+		opts:      opts,
+		boundary:  map[int64]struct{}{},
+	}
+	return newIterator(w.fetch)
+}
+
+// Состояние постраничного обхода истории между вызовами fetch.
+type historyWindow struct {
+	client    *MaxClient
+	chatID    int64
+	direction HistoryDirection
+	pageSize  int
+	opts      HistoryOptions
+
+	// Сообщения со временем, равным текущему курсору: только они могут прийти повторно.
+	boundary map[int64]struct{}
+	yielded  int
+}
+
+// Загружает страницу, начиная с курсора marker (время в миллисекундах), и возвращает
+// ещё не отданные сообщения и курсор следующей страницы.
+func (w *historyWindow) fetch(ctx context.Context, marker *int64) ([]*types.Message, *int64, error) {
+	from := w.start()
+	if marker != nil {
+		from = *marker
+	}
+
+	page := make([]*types.Message, 0, w.pageSize)
+	for count := w.pageSize; ; count *= 2 {
+		forward, backward := 0, count
+		if w.direction == HistoryForward {
+			forward, backward = count, 0
+		}
+		messages, err := w.client.FetchHistory(ctx, w.chatID, &from, forward, backward)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		before := len(page)
+		next, stop := w.collect(messages, from, &page)
+		added := len(page) - before
+		full := len(messages) >= count
+		grown := count > w.pageSize
+
+		switch {
+		case stop:
+			return page, nil, nil
+		case next != from:
+			// Увеличенное окно могло упереться в ограничение сервера, поэтому конец
+			// истории определяем только по окну обычного размера.
+			if !full && !grown {
+				return page, nil, nil
+			}
+		case added == 0 && grown:
+			// Сервер не отдаёт больше сообщений этой миллисекунды: сдвигаем окно, иначе
+			// обход зациклится.
+			if w.direction == HistoryForward {
+				next = from + 1
+			} else {
+				next = from - 1
+			}
+			w.boundary = map[int64]struct{}{}
+		case full || grown:
+			// Вся страница пришлась на одну миллисекунду: запрашиваем её снова окном
+			// побольше, уже отданные сообщения отсеет boundary.
+			continue
+		default:
+			return page, nil, nil
+		}
+
+		if w.pastEnd(next) {
+			return page, nil, nil
+		}
+		return page, &next, nil
+	}
+}
+
+// Добавляет в page ещё не отданные сообщения ответа, начиная с курсора from, и возвращает
+// время последнего из них. stop сообщает, что обход окончен по границе времени или Limit.
+func (w *historyWindow) collect(messages []*types.Message, from int64, page *[]*types.Message) (next int64, stop bool) {
+	sort.Slice(messages, func(i, j int) bool {
+		if messages[i].Time != messages[j].Time {
+			return w.before(messages[i].Time, messages[j].Time)
+		}
+		return w.before(messages[i].ID, messages[j].ID)
+	})
+
+	next = from
+	for _, message := range messages {
+		if w.before(message.Time, from) {
+			continue
+		}
+		if w.pastEnd(message.Time) {
+			return next, true
+		}
+		if message.Time != next {
+			next = message.Time
+			w.boundary = map[int64]struct{}{}
+		}
+		if _, seen := w.boundary[message.ID]; seen {
+			continue
+		}
+		w.boundary[message.ID] = struct{}{}
+
+		if message.ChatID == nil {
+			id := w.chatID
+			message.ChatID = &id
+		}
+		*page = append(*page, message)
+		w.yielded++
+		if w.opts.Limit > 0 && w.yielded >= w.opts.Limit {
+			return next, true
+		}
+	}
+	return next, false
+}
+
+// Возвращает начальный курсор для выбранного направления.
+func (w *historyWindow) start() int64 {
+	if w.direction == HistoryForward {
+		if !w.opts.Since.IsZero() {
+			return w.opts.Since.UnixMilli()
+		}
+		return 0
+	}
+	if !w.opts.Until.IsZero() {
+		return w.opts.Until.UnixMilli()
+	}
+	return time.Now().UnixMilli()
+}
+
+// Сообщает, идёт ли a раньше b в порядке обхода.
+func (w *historyWindow) before(a, b int64) bool {
+	if w.direction == HistoryForward {
+		return a < b
+	}
+	return a > b
+}
+
+// Сообщает, вышло ли время ts за границу диапазона в направлении обхода.
+func (w *historyWindow) pastEnd(ts int64) bool {
+	if w.direction == HistoryForward {
+		return !w.opts.Until.IsZero() && ts > w.opts.Until.UnixMilli()
+	}
+	return (!w.opts.Since.IsZero() && ts < w.opts.Since.UnixMilli()) || ts < 0
+}
@@ -0,0 +1,39 @@
+//go:build go1.23
+
+package gomax
+
+import (
+	"context"
+	"iter"
+
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// All возвращает последовательность элементов для range-over-func (Go 1.23+).
+// Ошибка загрузки отдаётся последней парой (нулевое значение, err), после чего обход завершается.
+//
+//	for msg, err := range client.HistoryIterator(chatID, gomax.HistoryBackward, 100, gomax.HistoryOptions{}).All(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (it *Iterator[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for it.Next(ctx) {
+			if !yield(it.Value(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// History возвращает последовательность сообщений чата для range-over-func (Go 1.23+).
+// Эквивалентно HistoryIterator(...).All(ctx).
+func (c *MaxClient) History(ctx context.Context, chatID int64, direction HistoryDirection, pageSize int, opts HistoryOptions) iter.Seq2[*types.Message, error] {
+	return c.HistoryIterator(chatID, direction, pageSize, opts).All(ctx)
+}
@@ -0,0 +1,38 @@
+//go:build go1.23
+
+package gomax
+
+import (
+	"testing"
+
+	"github.com/fresh-milkshake/gomax/mockserver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHistory_Seq проверяет обход истории через range-over-func и досрочный выход из цикла.
+func TestHistory_Seq(t *testing.T) {
+	server := mockserver.StartMockServerWithDefaults(t)
+	serveHistory(server, []int64{100, 200, 300, 400, 500})
+
+	client := createTestClient(t, server)
+	ctx := mockserver.TestContext(t)
+
+	var ids []int64
+	for msg, err := range client.History(ctx, testChatID, HistoryForward, 2, HistoryOptions{}) {
+		require.NoError(t, err)
+		ids = append(ids, msg.ID)
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, ids)
+
+	ids = nil
+	for msg, err := range client.History(ctx, testChatID, HistoryBackward, 2, HistoryOptions{}) {
+		require.NoError(t, err)
+		ids = append(ids, msg.ID)
+		if len(ids) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []int64{5, 4}, ids)
+}
@@ -0,0 +1,270 @@
+package gomax
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/internal/constants"
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Итог импорта одного номера телефона.
+type ImportStatus int
+
+const (
+	// ImportFound — пользователь найден, в контакты не добавлялся (ImportOptions.Add == false).
+	ImportFound ImportStatus = iota
+	// ImportAdded — пользователь найден и добавлен в контакты.
+	ImportAdded
+	// ImportNotRegistered — номер не зарегистрирован в Max.
+	ImportNotRegistered
+	// ImportInvalidPhone — номер не прошёл проверку constants.PhoneRegex, запрос не отправлялся.
+	ImportInvalidPhone
+	// ImportFailed — запрос завершился ошибкой (см. ImportResult.Err).
+	ImportFailed
+)
+
+// String возвращает читаемое имя статуса для логов и отчётов.
+func (s ImportStatus) String() string {
+	switch s {
+	case ImportFound:
+		return "found"
+	case ImportAdded:
+		return "added"
+	case ImportNotRegistered:
+		return "not-registered"
+	case ImportInvalidPhone:
+		return "invalid-phone"
+	case ImportFailed:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Результат импорта одного номера.
+type ImportResult struct {
+	Phone   string
+	Status  ImportStatus
+	User    *types.User
+	Contact *types.Contact
+	Err     error
+}
+
+// Параметры ImportContacts. Нулевые значения заменяются значениями по умолчанию.
+type ImportOptions struct {
+	// Add включает добавление найденных пользователей в контакты.
+	Add bool
+	// Concurrency — число одновременно обрабатываемых номеров.
+	// По умолчанию constants.DefaultImportConcurrency.
+	Concurrency int
+	// Interval — минимальный интервал между запросами к серверу для всех воркеров вместе.
+	// По умолчанию constants.DefaultImportInterval.
+	Interval time.Duration
+	// MaxRetries — число повторов запроса после RateLimitError. По умолчанию ClientConfig.MaxRetries.
+	MaxRetries int
+	// Progress вызывается после обработки каждого номера с числом обработанных и общим числом номеров.
+	// Вызовы сериализованы.
+	Progress func(done, total int, result ImportResult)
+}
+
+// Общий для воркеров ограничитель частоты запросов, учитывающий ответы too.many.requests.
+type importThrottle struct {
+	mu       sync.Mutex
+	next     time.Time
+	interval time.Duration
+}
+
+// Ожидает своей очереди на отправку запроса.
+func (t *importThrottle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	at := t.next
+	if at.Before(now) {
+		at = now
+	}
+	t.next = at.Add(t.interval)
+	t.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Откладывает все последующие запросы минимум на d.
+func (t *importThrottle) pause(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until := time.Now().Add(d); until.After(t.next) {
+		t.next = until
+	}
+}
+
+// ImportContacts находит пользователей Max по списку номеров телефонов и при ImportOptions.Add
+// добавляет их в контакты. Возвращает отчёт в порядке входного списка; повторяющиеся номера
+// запрашиваются один раз. Запросы ограничены по частоте и числу одновременных, а при RateLimitError
+// все воркеры приостанавливаются с экспоненциальным backoff. При отмене ctx возвращает частичный
+// отчёт (необработанные номера получают ImportFailed) и ошибку контекста.
+func (c *MaxClient) ImportContacts(ctx context.Context, phones []string, opts ImportOptions) ([]ImportResult, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = constants.DefaultImportConcurrency
+	}
+	if opts.Interval == 0 {
+		opts.Interval = time.Duration(constants.DefaultImportInterval * float64(time.Second))
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = c.cfg.MaxRetries
+	}
+
+	results := make([]ImportResult, len(phones))
+	byPhone := make(map[string][]int)
+	var unique []string
+	for i, raw := range phones {
+		phone := strings.TrimSpace(raw)
+		results[i] = ImportResult{Phone: phone}
+		if !constants.PhoneRegex.MatchString(phone) {
+			results[i].Status = ImportInvalidPhone
+			results[i].Err = &InvalidPhoneError{Phone: phone}
+			continue
+		}
+		if _, seen := byPhone[phone]; !seen {
+			unique = append(unique, phone)
+		}
+		byPhone[phone] = append(byPhone[phone], i)
+	}
+
+	var progressMu sync.Mutex
+	handled := make([]bool, len(phones))
+	done := 0
+	report := func(indices []int, res ImportResult) {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		for _, i := range indices {
+			results[i] = res
+			handled[i] = true
+			done++
+			if opts.Progress != nil {
+				opts.Progress(done, len(phones), res)
+			}
+		}
+	}
+	for i := range results {
+		if results[i].Status == ImportInvalidPhone {
+			report([]int{i}, results[i])
+		}
+	}
+
+	throttle := &importThrottle{interval: opts.Interval}
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Concurrency && w < len(unique); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for phone := range jobs {
+				report(byPhone[phone], c.importPhone(ctx, phone, opts, throttle))
+			}
+		}()
+	}
+
+feed:
+	for _, phone := range unique {
+		select {
+		case jobs <- phone:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		for _, phone := range unique {
+			if idx := byPhone[phone]; !handled[idx[0]] {
+				report(idx, ImportResult{Phone: phone, Status: ImportFailed, Err: err})
+			}
+		}
+		return results, err
+	}
+	return results, nil
+}
+
+// Обрабатывает один номер: поиск пользователя и, при необходимости, добавление в контакты.
+func (c *MaxClient) importPhone(ctx context.Context, phone string, opts ImportOptions, throttle *importThrottle) ImportResult {
+	res := ImportResult{Phone: phone}
+
+	var user *types.User
+	err := c.withRateLimitRetry(ctx, opts.MaxRetries, throttle, func() error {
+		var err error
+		user, err = c.SearchByPhone(ctx, phone)
+		return err
+	})
+	switch {
+	case isNotFoundError(err) || (err == nil && (user == nil || user.ID == 0)):
+		res.Status = ImportNotRegistered
+		return res
+	case err != nil:
+		res.Status = ImportFailed
+		res.Err = err
+		return res
+	}
+	res.User = user
+	res.Status = ImportFound
+
+	if !opts.Add {
+		return res
+	}
+	err = c.withRateLimitRetry(ctx, opts.MaxRetries, throttle, func() error {
+		var err error
+		res.Contact, err = c.AddContact(ctx, user.ID)
+		return err
+	})
+	if err != nil {
+		res.Status = ImportFailed
+		res.Err = err
+		return res
+	}
+	res.Status = ImportAdded
+	return res
+}
+
+// Выполняет запрос через общий ограничитель и повторяет его после RateLimitError,
+// приостанавливая все воркеры с экспоненциальным backoff.
+func (c *MaxClient) withRateLimitRetry(ctx context.Context, maxRetries int, throttle *importThrottle, call func() error) error {
+	delay := c.cfg.RetryInitialDelay
+	for attempt := 0; ; attempt++ {
+		if err := throttle.wait(ctx); err != nil {
+			return err
+		}
+		err := call()
+		var rateErr *RateLimitError
+		if !errors.As(err, &rateErr) || attempt >= maxRetries {
+			return err
+		}
+
+		c.logger.Warn("Rate limited during contact import, backing off", "attempt", attempt+1, "delay", delay)
+		throttle.pause(delay)
+		delay = time.Duration(float64(delay) * constants.DefaultBackoffMultiplier)
+		if delay > c.cfg.RetryMaxDelay {
+			delay = c.cfg.RetryMaxDelay
+		}
+	}
+}
+
+// Проверяет, что сервер ответил ошибкой «не найдено».
+func isNotFoundError(err error) bool {
+	var maxErr *Error
+	return errors.As(err, &maxErr) && strings.Contains(maxErr.Code, "not.found")
+}
@@ -24,10 +24,26 @@ var (
 	DefaultChatMembers           = 50
 	DefaultMarker                = 0
 	DefaultPingInterval          = 30.0
+	DefaultMaxMissedPings        = 3
 	RecvLoopBackoff              = 0.5
 	DefaultMaxRetries            = 3
 	DefaultRetryInitialDelay     = 1.0
 	DefaultRetryMaxDelay         = 30.0
+	DefaultReconnectDelay        = 5.0
+	DefaultBackoffMultiplier     = 2.0
+	DefaultReconnectMaxDelay     = 60.0
+	DefaultGapPageSize           = 50
+	DefaultGapMaxPages           = 10
+	DefaultGapSeenIDs            = 512
+	DefaultEventBuffer           = 128
+	DefaultTypingInterval        = 4.0
+	DefaultPresenceOnline        = 60.0
+	DefaultImportConcurrency     = 4
+	DefaultImportInterval        = 0.2
+	DefaultSearchCount           = 30
+	DefaultHistoryPageSize       = 50
+	DefaultUploadChunkSize       = 4 << 20
+	DefaultRemoteFileLimit       = 50 << 20
 
 	// MinWebQRAppVersion минимально допустимая версия приложения для WEB авторизации по QR.
 	MinWebQRAppVersion = "25.12.13"
@@ -71,6 +87,9 @@ var (
 	// TokenTypeRegister тип токена - регистрация
 	TokenTypeRegister = "REGISTER"
 
+	// MarkTypeRead тип отметки - прочтение сообщения
+	MarkTypeRead = "READ_MESSAGE"
+
 	// TokenTypeLogin тип токена - вход
 	TokenTypeLogin = "LOGIN"
 
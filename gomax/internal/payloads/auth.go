@@ -24,6 +24,47 @@ type RegisterPayload struct {
 	TokenType enums.AuthType `json:"tokenType"`
 }
 
+// Payload с облачным паролем в рамках трека авторизации
+// (AUTH_LOGIN_CHECK_PASSWORD, AUTH_VALIDATE_PASSWORD).
+type TrackPasswordPayload struct {
+	TrackID  string `json:"trackId"`
+	Password string `json:"password"`
+}
+
+// Payload для создания трека изменения облачного пароля.
+// Password — текущий пароль, обязателен при смене и отключении.
+type CreateTrackPayload struct {
+	Type     enums.TwoFactorAction `json:"type"`
+	Password string                `json:"password,omitempty"`
+}
+
+// Payload для проверки подсказки к паролю.
+type ValidateHintPayload struct {
+	TrackID string `json:"trackId"`
+	Hint    string `json:"hint"`
+}
+
+// Payload для привязки почты восстановления: сервер отправляет на неё код.
+type VerifyEmailPayload struct {
+	TrackID string `json:"trackId"`
+	Email   string `json:"email"`
+}
+
+// Payload для подтверждения почты восстановления кодом из письма.
+type CheckEmailPayload struct {
+	TrackID    string `json:"trackId"`
+	VerifyCode string `json:"verifyCode"`
+}
+
+// Payload для применения настроек двухфакторной аутентификации.
+type Set2FAPayload struct {
+	TrackID  string `json:"trackId"`
+	Enabled  bool   `json:"enabled"`
+	Password string `json:"password,omitempty"`
+	Hint     string `json:"hint,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
 // Payload для синхронизации состояния.
 type SyncPayload struct {
 	Interactive  bool   `json:"interactive"`
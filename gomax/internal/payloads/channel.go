@@ -24,3 +24,18 @@ type SearchGroupMembersPayload struct {
 	Query  string `json:"query"`
 	ChatID int64  `json:"chatId"`
 }
+
+// Payload для поиска чатов по названию.
+type SearchChatsPayload struct {
+	Query  string `json:"query"`
+	Count  int    `json:"count"`
+	Marker *int64 `json:"marker,omitempty"`
+}
+
+// Payload для поиска по публичному каталогу каналов, групп и пользователей.
+type PublicSearchPayload struct {
+	Query  string `json:"query"`
+	Type   string `json:"type"`
+	Count  int    `json:"count"`
+	Marker *int64 `json:"marker,omitempty"`
+}
@@ -127,3 +127,24 @@ type RemoveReactionPayload struct {
 	ChatID    int64  `json:"chatId"`
 	MessageID string `json:"messageId"`
 }
+
+// Описывает payload команды MSG_TYPING — индикатора набора текста в чате.
+type TypingPayload struct {
+	ChatID int64 `json:"chatId"`
+}
+
+// Описывает payload команды CHAT_MARK — отметки о прочтении чата до указанного сообщения.
+type MarkReadPayload struct {
+	Type      string `json:"type"`
+	ChatID    int64  `json:"chatId"`
+	MessageID string `json:"messageId"`
+	Mark      int64  `json:"mark"`
+}
+
+// Описывает payload команды MSG_SEARCH — поиска сообщений по всем чатам или в одном чате.
+type SearchMessagesPayload struct {
+	Query  string `json:"query"`
+	ChatID *int64 `json:"chatId,omitempty"`
+	Count  int    `json:"count"`
+	Marker *int64 `json:"marker,omitempty"`
+}
@@ -17,3 +17,42 @@ type ContactActionPayload struct {
 	ContactID int64               `json:"contactId"`
 	Action    enums.ContactAction `json:"action"`
 }
+
+// Payload для подписки на присутствие пользователей (CONTACT_PRESENCE).
+type ContactPresencePayload struct {
+	ContactIDs []int64 `json:"contactIds"`
+}
+
+// Payload для получения списка контактов.
+type ListContactsPayload struct {
+	Status string `json:"status,omitempty"`
+}
+
+// Payload для изменения локального имени контакта.
+type UpdateContactPayload struct {
+	ContactID int64               `json:"contactId"`
+	Action    enums.ContactAction `json:"action"`
+	FirstName string              `json:"firstName"`
+	LastName  *string             `json:"lastName,omitempty"`
+}
+
+// Payload для поиска по контактам.
+type SearchContactsPayload struct {
+	Query string `json:"query"`
+	Count int    `json:"count,omitempty"`
+}
+
+// Payload для получения общих контактов с пользователем.
+type MutualContactsPayload struct {
+	UserID int64 `json:"userId"`
+}
+
+// Payload для получения фотографий контакта.
+type ContactPhotosPayload struct {
+	ContactID int64 `json:"contactId"`
+}
+
+// Payload для изменения порядка контактов.
+type SortContactsPayload struct {
+	ContactIDs []int64 `json:"contactIds"`
+}
@@ -0,0 +1,53 @@
+package gomax
+
+import (
+	"context"
+
+	"github.com/fresh-milkshake/gomax/enums"
+)
+
+type idempotentKey struct{}
+
+// WithIdempotent помечает запросы, выполняемые с этим контекстом через Invoke и InvokeInto,
+// как безопасные для повторной отправки: при обрыве соединения они будут переотправлены
+// после reconnect, а не завершены ошибкой ErrConnectionLost.
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(ctx context.Context) bool {
+	v, _ := ctx.Value(idempotentKey{}).(bool)
+	return v
+}
+
+// Invoke отправляет произвольную команду WebSocket API Max и возвращает сырой ответ сервера
+// (поля ver, cmd, seq, opcode, payload). Ошибка протокола в payload не проверяется — см. HandleError.
+// Время ожидания ограничено дедлайном ctx, а при его отсутствии — ClientConfig.RequestTimeout.
+func (c *MaxClient) Invoke(ctx context.Context, opcode enums.Opcode, payload map[string]any) (map[string]any, error) {
+	frame, err := c.roundTrip(ctx, opcode, payload, isIdempotent(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return frame.Map(), nil
+}
+
+// InvokeInto выполняет запрос как Invoke, проверяет ответ через Frame.Err и декодирует payload ответа в T.
+// payload запроса может быть map[string]any или структурой с json‑тегами.
+func InvokeInto[T any](ctx context.Context, c *MaxClient, opcode enums.Opcode, payload any) (*T, error) {
+	frame, err := c.roundTrip(ctx, opcode, payload, isIdempotent(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if err := frame.Err(); err != nil {
+		return nil, err
+	}
+	if len(frame.Payload) == 0 {
+		return nil, &ResponseStructureError{Message: "missing payload"}
+	}
+
+	var out T
+	if err := frame.Decode(&out); err != nil {
+		return nil, &ResponseStructureError{Message: err.Error()}
+	}
+	return &out, nil
+}
@@ -0,0 +1,74 @@
+package gomax
+
+import "context"
+
+// Iterator постранично обходит результаты запроса к серверу, подгружая следующую страницу
+// по мере необходимости. Типичное использование:
+//
+//	it := client.SearchMessagesIter("отчёт", nil, 50)
+//	for it.Next(ctx) {
+//		msg := it.Value()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// Iterator не потокобезопасен.
+type Iterator[T any] struct {
+	fetch     func(ctx context.Context, marker *int64) ([]T, *int64, error)
+	marker    *int64
+	buf       []T
+	cur       T
+	exhausted bool
+	err       error
+}
+
+// Создаёт итератор поверх функции загрузки страницы по маркеру.
+// Функция возвращает элементы страницы и маркер следующей (nil — страниц больше нет).
+func newIterator[T any](fetch func(ctx context.Context, marker *int64) ([]T, *int64, error)) *Iterator[T] {
+	return &Iterator[T]{fetch: fetch}
+}
+
+// Next переходит к следующему элементу, при необходимости загружая страницу.
+// Возвращает false, когда элементы закончились или произошла ошибка (см. Err).
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	for len(it.buf) == 0 {
+		if it.err != nil || it.exhausted {
+			return false
+		}
+		items, next, err := it.fetch(ctx, it.marker)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.buf = items
+		// Защита от зацикливания, если сервер вернул тот же маркер.
+		if next == nil || (it.marker != nil && *next == *it.marker) {
+			it.exhausted = true
+		}
+		it.marker = next
+	}
+
+	it.cur = it.buf[0]
+	it.buf = it.buf[1:]
+	return true
+}
+
+// Value возвращает текущий элемент после успешного Next.
+func (it *Iterator[T]) Value() T {
+	return it.cur
+}
+
+// Err возвращает ошибку, остановившую обход, или nil.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Marker возвращает маркер следующей страницы, чтобы продолжить обход позже, или nil.
+func (it *Iterator[T]) Marker() *int64 {
+	if it.exhausted {
+		return nil
+	}
+	return it.marker
+}
@@ -0,0 +1,233 @@
+package gomax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/enums"
+	"github.com/fresh-milkshake/gomax/internal/constants"
+)
+
+// Описывает запрос, ожидающий ответа от сервера.
+// Поля seq и lost защищены pendingMu, так как меняются при повторной отправке после reconnect.
+type pendingRequest struct {
+	seq        int
+	opcode     enums.Opcode
+	payload    json.RawMessage
+	idempotent bool
+	handshake  bool
+	lost       bool
+
+	respCh chan *Frame
+	errCh  chan error
+}
+
+// Регистрирует запрос в pending под новым seq и возвращает готовый к отправке кадр.
+func (c *MaxClient) registerPending(req *pendingRequest) *Frame {
+	seq := c.nextSeq()
+
+	c.pendingMu.Lock()
+	req.seq = seq
+	req.lost = false
+	c.pending[seq] = req
+	c.pendingMu.Unlock()
+
+	return req.frame()
+}
+
+// Собирает кадр запроса с текущим seq.
+func (req *pendingRequest) frame() *Frame {
+	return &Frame{
+		Ver:       constants.ProtocolVersion,
+		Cmd:       constants.ProtocolCommand,
+		Seq:       req.seq,
+		Opcode:    req.opcode,
+		Payload:   req.payload,
+		handshake: req.handshake,
+	}
+}
+
+// Удаляет запрос из pending, если он всё ещё зарегистрирован под своим seq.
+func (c *MaxClient) removePending(req *pendingRequest) {
+	c.pendingMu.Lock()
+	if c.pending[req.seq] == req {
+		delete(c.pending, req.seq)
+	}
+	c.pendingMu.Unlock()
+}
+
+// Отправляет запрос и ждёт ответный кадр по его seq. Если соединение обрывается до получения ответа,
+// идемпотентный запрос будет повторно отправлен с новым seq после reconnect,
+// а остальные запросы сразу завершатся ошибкой ConnectionLostError.
+func (c *MaxClient) roundTrip(ctx context.Context, opcode enums.Opcode, payload any, idempotent bool) (*Frame, error) {
+	raw, err := encodePayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	req := &pendingRequest{
+		opcode:     opcode,
+		payload:    raw,
+		idempotent: idempotent,
+		handshake:  isHandshake(ctx),
+		respCh:     make(chan *Frame, 1),
+		errCh:      make(chan error, 1),
+	}
+	frame := c.registerPending(req)
+	defer c.removePending(req)
+
+	// Дедлайн контекста вызова имеет приоритет над RequestTimeout из конфигурации.
+	var timeout <-chan time.Time
+	if _, ok := ctx.Deadline(); !ok {
+		timer := time.NewTimer(c.cfg.RequestTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case c.outgoing <- frame:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout:
+		return nil, fmt.Errorf("timeout waiting for response")
+	}
+
+	select {
+	case resp := <-req.respCh:
+		return resp, nil
+	case err := <-req.errCh:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout:
+		return nil, fmt.Errorf("timeout waiting for response")
+	}
+}
+
+// Выполняет запрос, проверяет ошибку протокола в ответе и декодирует payload ответа в out.
+// out может быть nil, если содержимое ответа не нужно.
+// При обрыве соединения запрос не повторяется и завершается ошибкой ConnectionLostError.
+func (c *MaxClient) request(ctx context.Context, opcode enums.Opcode, payload any, out any) error {
+	return c.doRequest(ctx, opcode, payload, out, false)
+}
+
+// Аналогичен request, но для идемпотентных команд: при обрыве соединения
+// запрос будет повторно отправлен с новым seq после успешного reconnect.
+func (c *MaxClient) requestIdempotent(ctx context.Context, opcode enums.Opcode, payload any, out any) error {
+	return c.doRequest(ctx, opcode, payload, out, true)
+}
+
+func (c *MaxClient) doRequest(ctx context.Context, opcode enums.Opcode, payload any, out any, idempotent bool) error {
+	resp, err := c.roundTrip(ctx, opcode, payload, idempotent)
+	if err != nil {
+		return err
+	}
+	if err := resp.Err(); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := resp.Decode(out); err != nil {
+		return &ResponseStructureError{Message: fmt.Sprintf("opcode %d: %v", int(opcode), err)}
+	}
+	return nil
+}
+
+// Доставляет ответ ожидающему запросу. Возвращает false, если запроса с таким seq нет.
+func (c *MaxClient) resolvePending(seq int, frame *Frame) bool {
+	c.pendingMu.Lock()
+	req, ok := c.pending[seq]
+	if ok {
+		delete(c.pending, seq)
+	}
+	c.pendingMu.Unlock()
+	if !ok {
+		return false
+	}
+
+	select {
+	case req.respCh <- frame:
+	default:
+		c.logger.Warn("Pending channel full or receiver not waiting", "seq", seq)
+	}
+	return true
+}
+
+// Обрабатывает потерю соединения для всех ожидающих запросов.
+// Если replay == true, идемпотентные запросы остаются в очереди до reconnect,
+// иначе все запросы завершаются ошибкой ConnectionLostError.
+func (c *MaxClient) failPending(cause error, replay bool) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	for seq, req := range c.pending {
+		c.failPendingLocked(seq, req, cause, replay)
+	}
+}
+
+// Обрабатывает потерю одного запроса, сообщение которого не удалось записать в сокет.
+func (c *MaxClient) failPendingSeq(seq int, cause error) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	if req, ok := c.pending[seq]; ok {
+		c.failPendingLocked(seq, req, cause, c.cfg.Reconnect)
+	}
+}
+
+func (c *MaxClient) failPendingLocked(seq int, req *pendingRequest, cause error, replay bool) {
+	if replay && req.idempotent {
+		req.lost = true
+		return
+	}
+	delete(c.pending, seq)
+	select {
+	case req.errCh <- &ConnectionLostError{Opcode: req.opcode, Err: cause}:
+	default:
+	}
+}
+
+// Завершает все ожидающие запросы заданной ошибкой без повторной отправки.
+func (c *MaxClient) abortPending(err error) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	for seq, req := range c.pending {
+		delete(c.pending, seq)
+		select {
+		case req.errCh <- err:
+		default:
+		}
+	}
+}
+
+// Повторно отправляет идемпотентные запросы, потерянные при обрыве соединения,
+// присваивая им новые seq. Вызывается после успешного reconnect.
+func (c *MaxClient) replayPending(ctx context.Context) {
+	var frames []*Frame
+
+	c.pendingMu.Lock()
+	for seq, req := range c.pending {
+		if !req.lost {
+			continue
+		}
+		delete(c.pending, seq)
+		req.seq = c.nextSeq()
+		req.lost = false
+		c.pending[req.seq] = req
+		frames = append(frames, req.frame())
+	}
+	c.pendingMu.Unlock()
+
+	if len(frames) == 0 {
+		return
+	}
+	c.logger.Info("Replaying in-flight requests after reconnect", "count", len(frames))
+
+	for _, frame := range frames {
+		select {
+		case c.outgoing <- frame:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
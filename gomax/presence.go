@@ -0,0 +1,196 @@
+package gomax
+
+import (
+	"context"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/enums"
+	"github.com/fresh-milkshake/gomax/internal/constants"
+	"github.com/fresh-milkshake/gomax/internal/payloads"
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Снимок присутствия пользователя, собранный трекером MaxClient.
+type UserPresence struct {
+	UserID int64
+	// Online — пользователь в сети. Если сервер не передал флаг явно, пользователь считается
+	// в сети, пока с LastSeen прошло не больше constants.DefaultPresenceOnline секунд.
+	Online bool
+	// LastSeen — время последней активности; нулевое, если сервер его не сообщал.
+	LastSeen time.Time
+	// UpdatedAt — время получения последнего обновления.
+	UpdatedAt time.Time
+}
+
+// Хранимое состояние присутствия одного пользователя.
+type presenceEntry struct {
+	presence  types.Presence
+	updatedAt time.Time
+}
+
+// Собирает UserPresence на момент now.
+func (e *presenceEntry) snapshot(userID int64, now time.Time) UserPresence {
+	p := UserPresence{UserID: userID, UpdatedAt: e.updatedAt}
+	if e.presence.Seen != nil && *e.presence.Seen > 0 {
+		p.LastSeen = time.Unix(*e.presence.Seen, 0)
+	}
+	if e.presence.Online != nil {
+		p.Online = *e.presence.Online
+	} else if !p.LastSeen.IsZero() {
+		window := time.Duration(constants.DefaultPresenceOnline * float64(time.Second))
+		p.Online = now.Sub(p.LastSeen) <= window
+	}
+	return p
+}
+
+// SubscribePresence подписывается на обновления присутствия пользователей
+// и заполняет хранилище их текущим состоянием. Подписка восстанавливается после reconnect.
+func (c *MaxClient) SubscribePresence(ctx context.Context, userIDs ...int64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	c.presenceMu.Lock()
+	for _, id := range userIDs {
+		c.presenceTracked[id] = struct{}{}
+	}
+	c.presenceMu.Unlock()
+
+	return c.requestPresence(ctx, userIDs)
+}
+
+// UnsubscribePresence прекращает отслеживание пользователей и удаляет их из хранилища.
+func (c *MaxClient) UnsubscribePresence(userIDs ...int64) {
+	c.presenceMu.Lock()
+	defer c.presenceMu.Unlock()
+
+	for _, id := range userIDs {
+		delete(c.presenceTracked, id)
+		delete(c.presence, id)
+	}
+}
+
+// Presence возвращает известное состояние присутствия пользователя.
+// Второе значение false, если о пользователе ещё нет данных. Потокобезопасен.
+func (c *MaxClient) Presence(userID int64) (UserPresence, bool) {
+	c.presenceMu.RLock()
+	defer c.presenceMu.RUnlock()
+
+	e, ok := c.presence[userID]
+	if !ok {
+		return UserPresence{}, false
+	}
+	return e.snapshot(userID, time.Now()), true
+}
+
+// LastSeen возвращает время последней активности пользователя.
+// Второе значение false, если время неизвестно. Потокобезопасен.
+func (c *MaxClient) LastSeen(userID int64) (time.Time, bool) {
+	p, ok := c.Presence(userID)
+	if !ok || p.LastSeen.IsZero() {
+		return time.Time{}, false
+	}
+	return p.LastSeen, true
+}
+
+// Регистрирует обработчик изменения присутствия пользователя (вход в сеть, выход, новая активность).
+func (c *MaxClient) OnPresenceChange(handler func(context.Context, UserPresence)) {
+	c.onPresenceHandlers = append(c.onPresenceHandlers, handler)
+}
+
+// Запрашивает CONTACT_PRESENCE и применяет полученные состояния.
+func (c *MaxClient) requestPresence(ctx context.Context, userIDs []int64) error {
+	pl := payloads.ContactPresencePayload{
+		ContactIDs: userIDs,
+	}
+	var resp struct {
+		Presence map[int64]*types.Presence `json:"presence"`
+	}
+	if err := c.requestIdempotent(ctx, enums.OpcodeContactPresence, pl, &resp); err != nil {
+		return err
+	}
+
+	for userID, p := range resp.Presence {
+		if p != nil {
+			c.applyPresence(ctx, userID, *p)
+		}
+	}
+	return nil
+}
+
+// Разбирает NOTIF_PRESENCE и обновляет хранилище.
+func (c *MaxClient) handlePresence(ctx context.Context, frame *Frame) {
+	var payload struct {
+		UserID   int64          `json:"userId"`
+		Presence types.Presence `json:"presence"`
+	}
+	if err := frame.Decode(&payload); err != nil {
+		c.logger.Warn("Failed to decode NOTIF_PRESENCE", "err", err)
+		return
+	}
+	c.applyPresence(ctx, payload.UserID, payload.Presence)
+}
+
+// Сохраняет присутствие отслеживаемых участников из списка участников чата.
+func (c *MaxClient) trackMembersPresence(ctx context.Context, members []*types.Member) {
+	for _, m := range members {
+		if m != nil && m.Presence != nil {
+			c.applyPresence(ctx, m.Contact.ID, *m.Presence)
+		}
+	}
+}
+
+// Объединяет обновление с сохранённым состоянием и вызывает OnPresenceChange, если оно изменилось.
+// Обновления для пользователей без подписки SubscribePresence игнорируются,
+// чтобы хранилище не росло за счёт всех встреченных пользователей.
+func (c *MaxClient) applyPresence(ctx context.Context, userID int64, p types.Presence) {
+	now := time.Now()
+
+	c.presenceMu.Lock()
+	if _, tracked := c.presenceTracked[userID]; !tracked {
+		c.presenceMu.Unlock()
+		return
+	}
+	prev, existed := c.presence[userID]
+	var before UserPresence
+	if existed {
+		before = prev.snapshot(userID, now)
+		// Частичное обновление не должно стирать уже известные поля.
+		if p.Seen == nil {
+			p.Seen = prev.presence.Seen
+		}
+		if p.Online == nil {
+			p.Online = prev.presence.Online
+		}
+	}
+	entry := &presenceEntry{presence: p, updatedAt: now}
+	c.presence[userID] = entry
+	after := entry.snapshot(userID, now)
+	c.presenceMu.Unlock()
+
+	if existed && before.Online == after.Online && before.LastSeen.Equal(after.LastSeen) {
+		return
+	}
+	for _, handler := range c.onPresenceHandlers {
+		go handler(ctx, after)
+	}
+}
+
+// Повторно подписывается на присутствие отслеживаемых пользователей после reconnect.
+func (c *MaxClient) resubscribePresence(ctx context.Context) {
+	defer c.bgWG.Done()
+
+	c.presenceMu.RLock()
+	ids := make([]int64, 0, len(c.presenceTracked))
+	for id := range c.presenceTracked {
+		ids = append(ids, id)
+	}
+	c.presenceMu.RUnlock()
+
+	if len(ids) == 0 {
+		return
+	}
+	if err := c.requestPresence(ctx, ids); err != nil {
+		c.logger.Warn("Failed to restore presence subscription", "err", err)
+	}
+}
@@ -0,0 +1,147 @@
+package gomax
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mdp/qrterminal/v3"
+	"rsc.io/qr"
+)
+
+// Описывает этап QR‑авторизации, о котором сообщает QRProvider.
+type QRStatus int
+
+const (
+	// QRStatusNew — получен новый QR‑код (в том числе после перевыпуска истёкшего), его нужно показать.
+	QRStatusNew QRStatus = iota
+	// QRStatusPending — очередной опрос статуса: код ещё не отсканирован.
+	QRStatusPending
+	// QRStatusExpired — срок действия кода истёк, сразу за ним последует QRStatusNew.
+	QRStatusExpired
+	// QRStatusConfirmed — вход подтверждён, клиент получает токен.
+	QRStatusConfirmed
+)
+
+// Возвращает строковое имя этапа для логов.
+func (s QRStatus) String() string {
+	switch s {
+	case QRStatusNew:
+		return "new"
+	case QRStatusPending:
+		return "pending"
+	case QRStatusExpired:
+		return "expired"
+	case QRStatusConfirmed:
+		return "confirmed"
+	default:
+		return fmt.Sprintf("QRStatus(%d)", int(s))
+	}
+}
+
+// Состояние QR‑авторизации, передаваемое в QRProvider.
+type QRState struct {
+	Status    QRStatus
+	Link      string
+	TrackID   string
+	ExpiresAt time.Time
+	// Attempt — порядковый номер QR‑кода, начиная с 1; увеличивается при каждом перевыпуске.
+	Attempt int
+}
+
+// Получает уведомления о ходе QR‑авторизации. Ошибка прерывает Login.
+type QRProvider func(ctx context.Context, state QRState) error
+
+// Кодирует ссылку в QR‑код и возвращает PNG (8 px на модуль, с полями).
+func QRCodePNG(link string) ([]byte, error) {
+	code, err := qr.Encode(link, qr.M)
+	if err != nil {
+		return nil, err
+	}
+	return code.PNG(), nil
+}
+
+// Кодирует ссылку в QR‑код и возвращает его как SVG‑строку,
+// пригодную для встраивания в HTML без дополнительных ресурсов.
+func QRCodeSVG(link string) (string, error) {
+	code, err := qr.Encode(link, qr.M)
+	if err != nil {
+		return "", err
+	}
+
+	const quiet = 4
+	size := code.Size + 2*quiet
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, size, size)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+quiet, y+quiet)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return b.String(), nil
+}
+
+// Возвращает QRProvider, который печатает QR‑код и ссылку в w.
+// Используется по умолчанию (с os.Stdout), если QRProvider не задан.
+func TerminalQRPresenter(w io.Writer) QRProvider {
+	cfg := qrterminal.Config{
+		Level:      qrterminal.M,
+		Writer:     w,
+		BlackChar:  "██",
+		WhiteChar:  "░░", // видимый «пробел», чтобы терминал не обрезал края
+		HalfBlocks: false,
+		QuietZone:  1,
+	}
+	return func(ctx context.Context, state QRState) error {
+		switch state.Status {
+		case QRStatusNew:
+			fmt.Fprintln(w, "Scan this QR code to login (or use the link below):")
+			qrterminal.GenerateWithConfig(state.Link, cfg)
+			fmt.Fprintf(w, "QR link: %s\n", state.Link)
+		case QRStatusExpired:
+			fmt.Fprintln(w, "QR code expired, requesting a new one...")
+		}
+		return nil
+	}
+}
+
+// Возвращает QRProvider, который записывает актуальный QR‑код в PNG‑файл path,
+// перезаписывая его при перевыпуске. Подходит для окружений без терминала.
+func PNGFileQRPresenter(path string) QRProvider {
+	return func(ctx context.Context, state QRState) error {
+		if state.Status != QRStatusNew {
+			return nil
+		}
+		data, err := QRCodePNG(state.Link)
+		if err != nil {
+			return err
+		}
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	}
+}
+
+// Возвращает QRProvider, который передаёт SVG‑представление каждого нового QR‑кода в show,
+// например для отрисовки на веб‑странице.
+func SVGQRPresenter(show func(ctx context.Context, svg string, state QRState) error) QRProvider {
+	return func(ctx context.Context, state QRState) error {
+		if state.Status != QRStatusNew {
+			return nil
+		}
+		svg, err := QRCodeSVG(state.Link)
+		if err != nil {
+			return err
+		}
+		return show(ctx, svg, state)
+	}
+}
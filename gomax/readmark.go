@@ -0,0 +1,104 @@
+package gomax
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/enums"
+	"github.com/fresh-milkshake/gomax/internal/constants"
+	"github.com/fresh-milkshake/gomax/internal/payloads"
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Отмечает чат прочитанным до сообщения messageID включительно
+// и обнуляет счётчик непрочитанных в кэше чатов.
+func (c *MaxClient) MarkRead(ctx context.Context, chatID int64, messageID int64) error {
+	pl := payloads.MarkReadPayload{
+		Type:      constants.MarkTypeRead,
+		ChatID:    chatID,
+		MessageID: strconv.FormatInt(messageID, 10),
+		Mark:      time.Now().UnixMilli(),
+	}
+	if err := c.request(ctx, enums.OpcodeChatMark, pl, nil); err != nil {
+		return err
+	}
+
+	c.updateUnread(chatID, func(unread *int) {
+		*unread = 0
+	})
+	return nil
+}
+
+// Регистрирует обработчик уведомлений о прочтении чатов участниками,
+// в том числе текущим пользователем на других устройствах.
+func (c *MaxClient) OnReadMark(handler func(context.Context, *types.ReadMark)) {
+	c.onReadMarkHandlers = append(c.onReadMarkHandlers, handler)
+}
+
+// Разбирает NOTIF_MARK, синхронизирует счётчик непрочитанных при прочтении
+// текущим пользователем и вызывает обработчики OnReadMark.
+func (c *MaxClient) handleReadMark(ctx context.Context, frame *Frame) {
+	mark := &types.ReadMark{}
+	if err := frame.Decode(mark); err != nil {
+		c.logger.Warn("Failed to decode NOTIF_MARK", "err", err)
+		return
+	}
+
+	if c.isMe(mark.UserID) {
+		unread := 0
+		if mark.Unread != nil {
+			unread = *mark.Unread
+		}
+		c.updateUnread(mark.ChatID, func(count *int) {
+			*count = unread
+		})
+	}
+
+	for _, handler := range c.onReadMarkHandlers {
+		go handler(ctx, mark)
+	}
+}
+
+// Увеличивает счётчик непрочитанных для нового входящего сообщения.
+func (c *MaxClient) countUnread(message *types.Message) {
+	if message.ChatID == nil || message.Status != nil {
+		return
+	}
+	if message.Sender != nil && c.isMe(*message.Sender) {
+		return
+	}
+	c.updateUnread(*message.ChatID, func(unread *int) {
+		*unread++
+	})
+}
+
+// Проверяет, принадлежит ли userID текущему пользователю.
+func (c *MaxClient) isMe(userID int64) bool {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return c.Me != nil && c.Me.ID == userID
+}
+
+// Применяет изменение к счётчику непрочитанных чата, канала или личного диалога
+// из кэша клиента, если он там есть.
+func (c *MaxClient) updateUnread(chatID int64, update func(unread *int)) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	for i := range c.Chats {
+		if c.Chats[i].ID == chatID {
+			update(&c.Chats[i].NewMessages)
+		}
+	}
+	for i := range c.Channels {
+		if c.Channels[i].ID == chatID {
+			update(&c.Channels[i].NewMessages)
+		}
+	}
+	for i := range c.Dialogs {
+		if c.Dialogs[i].ID == chatID {
+			update(&c.Dialogs[i].NewMessages)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package gomax
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/internal/constants"
+)
+
+// Задаёт стратегию переподключения WebSocket: экспоненциальный backoff с jitter
+// и ограничения на число попыток и общее время. Нулевые поля заменяются значениями по умолчанию.
+type ReconnectPolicy struct {
+	// InitialDelay — задержка перед первой попыткой.
+	// Если не указана, используется ClientConfig.ReconnectDelay.
+	InitialDelay time.Duration
+
+	// Multiplier — множитель задержки после каждой неудачной попытки (по умолчанию 2.0).
+	Multiplier float64
+
+	// MaxDelay — верхняя граница задержки между попытками.
+	MaxDelay time.Duration
+
+	// Jitter — доля случайного отклонения задержки в диапазоне [0, 1].
+	// Например, 0.2 даёт разброс ±20%. 0 отключает jitter.
+	Jitter float64
+
+	// MaxAttempts — максимальное число попыток подряд; 0 — без ограничения.
+	MaxAttempts int
+
+	// MaxElapsedTime — максимальное время с момента обрыва соединения; 0 — без ограничения.
+	MaxElapsedTime time.Duration
+}
+
+// Заполняет незаданные поля политики значениями по умолчанию.
+func fillReconnectPolicyDefaults(p *ReconnectPolicy, reconnectDelay time.Duration) {
+	if p.InitialDelay == 0 {
+		p.InitialDelay = reconnectDelay
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = constants.DefaultBackoffMultiplier
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 1
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = time.Duration(constants.DefaultReconnectMaxDelay * float64(time.Second))
+	}
+	if p.MaxDelay < p.InitialDelay {
+		p.MaxDelay = p.InitialDelay
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+}
+
+// Возвращает задержку перед попыткой с номером attempt (начиная с 1)
+// с учётом множителя, верхней границы и jitter.
+func (p ReconnectPolicy) delay(attempt int) time.Duration {
+	d := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(attempt-1))
+	if d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(rand.Float64()*2-1)
+	}
+	return time.Duration(d)
+}
+
+type handshakeKey struct{}
+
+// Помечает запросы, выполняемые с этим контекстом, как часть handshake reconnect.
+func withHandshake(ctx context.Context) context.Context {
+	return context.WithValue(ctx, handshakeKey{}, true)
+}
+
+func isHandshake(ctx context.Context) bool {
+	v, _ := ctx.Value(handshakeKey{}).(bool)
+	return v
+}
+
+// Повторяет попытки reconnect согласно ReconnectPolicy, пока соединение не будет восстановлено,
+// контекст не будет отменён или политика не будет исчерпана. В последнем случае клиент
+// останавливается, а ошибка становится доступна через Err.
+func (c *MaxClient) reconnectLoop(ctx context.Context, cause error) {
+	defer c.bgWG.Done()
+	defer func() {
+		c.reconnectMu.Lock()
+		c.reconnecting = false
+		c.reconnectMu.Unlock()
+	}()
+
+	policy := c.cfg.ReconnectPolicy
+	started := time.Now()
+	lastErr := cause
+
+	for attempt := 1; ; attempt++ {
+		wait := policy.delay(attempt)
+		elapsed := time.Since(started)
+		if (policy.MaxAttempts > 0 && attempt > policy.MaxAttempts) ||
+			(policy.MaxElapsedTime > 0 && elapsed+wait > policy.MaxElapsedTime) {
+			err := &ReconnectError{Attempts: attempt - 1, Elapsed: elapsed, Err: lastErr}
+			c.logger.Error("Reconnect policy exhausted, stopping client", "err", err)
+			c.failPending(err, false)
+			c.finish(err)
+			c.setState(ctx, StateClosed)
+			if c.bgCancel != nil {
+				c.bgCancel()
+			}
+			return
+		}
+
+		c.logger.Info("Attempting to reconnect", "attempt", attempt, "delay", wait)
+		select {
+		case <-ctx.Done():
+			c.logger.Debug("Reconnection cancelled due to context cancellation")
+			return
+		case <-time.After(wait):
+		}
+
+		if err := c.reconnect(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			lastErr = err
+			c.logger.Warn("Reconnection attempt failed", "attempt", attempt, "err", err)
+			c.setState(ctx, StateReconnecting)
+			continue
+		}
+
+		c.logger.Info("Reconnection successful", "attempts", attempt)
+		c.fireReconnect(ctx)
+		c.bgWG.Add(2)
+		go c.recoverGap(ctx)
+		go c.resubscribePresence(ctx)
+		return
+	}
+}
+
+// Фиксирует терминальную ошибку клиента и закрывает канал Done. Повторные вызовы игнорируются.
+func (c *MaxClient) finish(err error) {
+	c.doneOnce.Do(func() {
+		c.connStateMu.Lock()
+		c.termErr = err
+		c.connStateMu.Unlock()
+		close(c.done)
+		c.closeSubscriptions()
+	})
+}
+
+// Done возвращает канал, который закрывается, когда клиент окончательно остановлен:
+// после Close, после исчерпания ReconnectPolicy или при обрыве соединения с выключенным Reconnect.
+func (c *MaxClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err возвращает причину остановки клиента после закрытия Done и nil, пока клиент работает.
+// После исчерпания политики переподключения возвращает *ReconnectError.
+func (c *MaxClient) Err() error {
+	c.connStateMu.Lock()
+	defer c.connStateMu.Unlock()
+	return c.termErr
+}
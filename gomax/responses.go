@@ -0,0 +1,217 @@
+package gomax
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/fresh-milkshake/gomax/enums"
+	"github.com/fresh-milkshake/gomax/internal/utils"
+	"github.com/fresh-milkshake/gomax/types"
+
+	"github.com/charmbracelet/log"
+)
+
+// Ответ с одиночным токеном (AUTH_REQUEST, AUTH_CONFIRM).
+type tokenResponse struct {
+	Token string `json:"token"`
+}
+
+// Ответ с набором токенов по типам (AUTH, LOGIN_BY_QR, AUTH_LOGIN_CHECK_PASSWORD).
+// Вместо токена входа AUTH может вернуть запрос облачного пароля.
+type tokenAttrsResponse struct {
+	TokenAttrs map[string]struct {
+		Token string `json:"token"`
+	} `json:"tokenAttrs"`
+	PasswordChallenge *types.PasswordChallenge `json:"passwordChallenge"`
+}
+
+// Возвращает токен указанного типа или пустую строку.
+func (r *tokenAttrsResponse) token(tokenType string) string {
+	return r.TokenAttrs[tokenType].Token
+}
+
+// Ответ AUTH_CREATE_TRACK.
+type trackResponse struct {
+	TrackID string `json:"trackId"`
+}
+
+// Ответ со страницей сообщений (CHAT_HISTORY). Сообщения разбираются по одному,
+// чтобы одно нераспознанное сообщение не ломало всю страницу.
+type messagesResponse struct {
+	Messages []json.RawMessage `json:"messages"`
+}
+
+// Возвращает разобранные сообщения (не nil), пропуская и логируя нераспознанные.
+func (r *messagesResponse) list(logger *log.Logger, chatID int64) []*types.Message {
+	messages := make([]*types.Message, 0, len(r.Messages))
+	for _, raw := range r.Messages {
+		msg := &types.Message{}
+		if err := utils.JSONUnmarshal(raw, msg); err != nil {
+			logger.Warn("Skipping undecodable message", "chatId", chatID, "err", err)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// Ответ GET_QR с параметрами QR‑авторизации.
+type qrResponse struct {
+	PollingInterval float64 `json:"pollingInterval"`
+	QRLink          string  `json:"qrLink"`
+	TrackID         string  `json:"trackId"`
+	ExpiresAt       float64 `json:"expiresAt"`
+}
+
+// Ответ GET_QR_STATUS.
+type qrStatusResponse struct {
+	Status *struct {
+		LoginAvailable bool    `json:"loginAvailable"`
+		ExpiresAt      float64 `json:"expiresAt"`
+	} `json:"status"`
+}
+
+// Ответ на запрос слота загрузки файла или видео.
+type uploadSlotResponse struct {
+	Info []struct {
+		URL     string `json:"url"`
+		FileID  int64  `json:"fileId"`
+		VideoID int64  `json:"videoId"`
+		Token   string `json:"token"`
+	} `json:"info"`
+}
+
+// Ответ со списком контактов.
+type contactsResponse struct {
+	Contacts []*types.Contact `json:"contacts"`
+}
+
+// Возвращает непустой срез контактов без nil‑элементов.
+func (r *contactsResponse) list() []*types.Contact {
+	contacts := make([]*types.Contact, 0, len(r.Contacts))
+	for _, contact := range r.Contacts {
+		if contact != nil {
+			contacts = append(contacts, contact)
+		}
+	}
+	return contacts
+}
+
+// Ответ на добавление или снятие реакции.
+type reactionResponse struct {
+	ReactionInfo *types.ReactionInfo `json:"reactionInfo"`
+}
+
+// Возвращает информацию о реакциях; при её отсутствии — пустую структуру.
+func (r *reactionResponse) info() *types.ReactionInfo {
+	if r.ReactionInfo == nil {
+		return &types.ReactionInfo{}
+	}
+	return r.ReactionInfo
+}
+
+// Страница участников чата с маркером продолжения.
+type membersResponse struct {
+	Members []*types.Member `json:"members"`
+	Marker  int             `json:"marker"`
+}
+
+// Возвращает участников и маркер следующей страницы (nil, если страниц больше нет).
+func (r *membersResponse) page() ([]*types.Member, *int) {
+	members := r.Members
+	if members == nil {
+		members = []*types.Member{}
+	}
+	if r.Marker > 0 {
+		marker := r.Marker
+		return members, &marker
+	}
+	return members, nil
+}
+
+// Ответ LINK_INFO. Пользователь может прийти как в поле user, так и в contact.
+type linkInfoResponse struct {
+	Chat    *types.Chat    `json:"chat"`
+	User    *types.User    `json:"user"`
+	Contact *types.User    `json:"contact"`
+	Message *types.Message `json:"message"`
+}
+
+// Собирает LinkInfo и определяет тип ссылки по составу ответа.
+func (r *linkInfoResponse) info(link string) *types.LinkInfo {
+	info := &types.LinkInfo{
+		Link:    link,
+		Chat:    r.Chat,
+		User:    r.User,
+		Message: r.Message,
+	}
+	if info.User == nil {
+		info.User = r.Contact
+	}
+
+	switch {
+	case info.Message != nil:
+		info.Type = enums.LinkTypeMessage
+		if info.Message.ChatID == nil && info.Chat != nil {
+			id := info.Chat.ID
+			info.Message.ChatID = &id
+		}
+	case info.Chat != nil && info.Chat.Type == enums.ChatTypeChannel:
+		info.Type = enums.LinkTypeChannel
+	case info.Chat != nil:
+		info.Type = enums.LinkTypeChat
+	case info.User != nil:
+		info.Type = enums.LinkTypeUser
+	}
+	return info
+}
+
+// Ответ PUBLIC_SEARCH: смешанный список чатов и пользователей.
+type publicSearchResponse struct {
+	Result []struct {
+		Chat    *types.Chat `json:"chat"`
+		User    *types.User `json:"user"`
+		Contact *types.User `json:"contact"`
+	} `json:"result"`
+	Marker *int64 `json:"marker"`
+}
+
+// Раскладывает найденные объекты по типам; срезы всегда непустые (не nil).
+func (r *publicSearchResponse) result() *types.PublicSearchResult {
+	res := &types.PublicSearchResult{
+		Chats:    []*types.Chat{},
+		Channels: []*types.Chat{},
+		Users:    []*types.User{},
+		Marker:   nextMarker(r.Marker),
+	}
+	for _, item := range r.Result {
+		switch {
+		case item.Chat != nil && item.Chat.Type == enums.ChatTypeChannel:
+			res.Channels = append(res.Channels, item.Chat)
+		case item.Chat != nil:
+			res.Chats = append(res.Chats, item.Chat)
+		case item.User != nil:
+			res.Users = append(res.Users, item.User)
+		case item.Contact != nil:
+			res.Users = append(res.Users, item.Contact)
+		}
+	}
+	return res
+}
+
+// Ответ, содержащий обновлённый объект чата.
+type chatResponse struct {
+	Chat *types.Chat `json:"chat"`
+}
+
+// Выполняет запрос, изменяющий чат, и обновляет кэш чатов, если сервер вернул объект чата.
+func (c *MaxClient) requestChatUpdate(ctx context.Context, opcode enums.Opcode, payload any) error {
+	var resp chatResponse
+	if err := c.request(ctx, opcode, payload, &resp); err != nil {
+		return err
+	}
+	if resp.Chat != nil {
+		c.updateChatCache(resp.Chat)
+	}
+	return nil
+}
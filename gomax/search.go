@@ -0,0 +1,147 @@
+package gomax
+
+import (
+	"context"
+
+	"github.com/fresh-milkshake/gomax/enums"
+	"github.com/fresh-milkshake/gomax/internal/constants"
+	"github.com/fresh-milkshake/gomax/internal/payloads"
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Параметры страницы серверного поиска.
+type Pagination struct {
+	// Marker — маркер страницы из предыдущего ответа; nil — первая страница.
+	Marker *int64
+	// Count — размер страницы. По умолчанию constants.DefaultSearchCount.
+	Count int
+}
+
+// Ищет сообщения на сервере по всем чатам или, если chatID задан, в одном чате.
+// Возвращает найденные сообщения и маркер следующей страницы (nil, если страниц больше нет).
+func (c *MaxClient) SearchMessages(ctx context.Context, query string, chatID *int64, page Pagination) ([]*types.Message, *int64, error) {
+	if page.Count <= 0 {
+		page.Count = constants.DefaultSearchCount
+	}
+
+	pl := payloads.SearchMessagesPayload{
+		Query:  query,
+		ChatID: chatID,
+		Count:  page.Count,
+		Marker: page.Marker,
+	}
+
+	var resp struct {
+		Result []struct {
+			ChatID  int64          `json:"chatId"`
+			Message *types.Message `json:"message"`
+		} `json:"result"`
+		Marker *int64 `json:"marker"`
+	}
+	if err := c.requestIdempotent(ctx, enums.OpcodeMsgSearch, pl, &resp); err != nil {
+		return nil, nil, err
+	}
+
+	messages := make([]*types.Message, 0, len(resp.Result))
+	for _, r := range resp.Result {
+		if r.Message == nil {
+			continue
+		}
+		if r.Message.ChatID == nil && r.ChatID != 0 {
+			id := r.ChatID
+			r.Message.ChatID = &id
+		}
+		messages = append(messages, r.Message)
+	}
+	return messages, nextMarker(resp.Marker), nil
+}
+
+// Ищет чаты и каналы по названию. Возвращает найденные чаты и маркер следующей страницы.
+func (c *MaxClient) SearchChats(ctx context.Context, query string, page Pagination) ([]*types.Chat, *int64, error) {
+	if page.Count <= 0 {
+		page.Count = constants.DefaultSearchCount
+	}
+
+	pl := payloads.SearchChatsPayload{
+		Query:  query,
+		Count:  page.Count,
+		Marker: page.Marker,
+	}
+
+	var resp struct {
+		Chats  []*types.Chat `json:"chats"`
+		Marker *int64        `json:"marker"`
+	}
+	if err := c.requestIdempotent(ctx, enums.OpcodeChatSearch, pl, &resp); err != nil {
+		return nil, nil, err
+	}
+
+	chats := make([]*types.Chat, 0, len(resp.Chats))
+	for _, chat := range resp.Chats {
+		if chat != nil {
+			chats = append(chats, chat)
+		}
+	}
+	return chats, nextMarker(resp.Marker), nil
+}
+
+// SearchMessagesIter возвращает итератор по всем страницам SearchMessages.
+func (c *MaxClient) SearchMessagesIter(query string, chatID *int64, pageSize int) *Iterator[*types.Message] {
+	return newIterator(func(ctx context.Context, marker *int64) ([]*types.Message, *int64, error) {
+		return c.SearchMessages(ctx, query, chatID, Pagination{Marker: marker, Count: pageSize})
+	})
+}
+
+// SearchChatsIter возвращает итератор по всем страницам SearchChats.
+func (c *MaxClient) SearchChatsIter(query string, pageSize int) *Iterator[*types.Chat] {
+	return newIterator(func(ctx context.Context, marker *int64) ([]*types.Chat, *int64, error) {
+		return c.SearchChats(ctx, query, Pagination{Marker: marker, Count: pageSize})
+	})
+}
+
+// Ищет по публичному каталогу Max каналы, группы и пользователей.
+// kind ограничивает категорию; пустое значение равносильно enums.PublicSearchAll.
+// Следующая страница запрашивается с маркером из Marker предыдущего результата.
+func (c *MaxClient) PublicSearch(ctx context.Context, query string, kind enums.PublicSearchKind, page Pagination) (*types.PublicSearchResult, error) {
+	if kind == "" {
+		kind = enums.PublicSearchAll
+	}
+	if page.Count <= 0 {
+		page.Count = constants.DefaultSearchCount
+	}
+
+	pl := payloads.PublicSearchPayload{
+		Query:  query,
+		Type:   string(kind),
+		Count:  page.Count,
+		Marker: page.Marker,
+	}
+
+	var resp publicSearchResponse
+	if err := c.requestIdempotent(ctx, enums.OpcodePublicSearch, pl, &resp); err != nil {
+		return nil, err
+	}
+	return resp.result(), nil
+}
+
+// Разрешает ссылку Max и возвращает, на что она указывает, не вступая в чат или канал.
+// Если сервер не вернул ни чата, ни пользователя, ни сообщения, Type равен enums.LinkTypeUnknown.
+func (c *MaxClient) ResolveLink(ctx context.Context, link string) (*types.LinkInfo, error) {
+	pl := payloads.ResolveLinkPayload{
+		Link: link,
+	}
+
+	var resp linkInfoResponse
+	if err := c.requestIdempotent(ctx, enums.OpcodeLinkInfo, pl, &resp); err != nil {
+		return nil, err
+	}
+	return resp.info(link), nil
+}
+
+// Нормализует маркер ответа: отсутствующий или нулевой маркер означает конец выдачи.
+func nextMarker(marker *int64) *int64 {
+	if marker == nil || *marker == 0 {
+		return nil
+	}
+	return marker
+}
@@ -0,0 +1,6 @@
+//go:build cgo
+
+package sessionstore
+
+// SQLite‑драйвер требует cgo; в такой сборке он используется по умолчанию.
+const cgoEnabled = true
@@ -0,0 +1,139 @@
+package sessionstore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	encMagic      = "GMXSESS1"
+	encSaltSize   = 16
+	encKeySize    = 32
+	encIterations = 600_000
+)
+
+// ErrWrongPassphrase возвращается, если зашифрованный файл не удалось расшифровать:
+// пароль неверный или файл повреждён.
+var ErrWrongPassphrase = errors.New("sessionstore: wrong passphrase or corrupted session file")
+
+// Хранит сессии в файле, зашифрованном AES‑256‑GCM. Ключ выводится из пароля
+// через PBKDF2‑HMAC‑SHA256 со случайной солью, которая хранится в заголовке файла.
+type EncryptedFileStore struct {
+	accountsFile
+	passphrase []byte
+
+	// salt и key защищены accountsFile.mu: seal и open вызываются только под ним.
+	salt []byte
+	key  []byte
+}
+
+// Создаёт зашифрованное хранилище в файле path с указанным паролем.
+func NewEncryptedFile(path string, passphrase string) (*EncryptedFileStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("session file path is empty")
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("session passphrase is empty")
+	}
+	s := &EncryptedFileStore{passphrase: []byte(passphrase)}
+	s.accountsFile = newAccountsFile(path)
+	s.seal, s.open = s.encrypt, s.decrypt
+	return s, nil
+}
+
+// Расшифровывает содержимое файла: заголовок, соль, nonce и шифртекст.
+func (s *EncryptedFileStore) decrypt(raw []byte) ([]byte, error) {
+	header := len(encMagic) + encSaltSize
+	if len(raw) < header || string(raw[:len(encMagic)]) != encMagic {
+		return nil, ErrWrongPassphrase
+	}
+	aead, err := s.cipher(raw[len(encMagic):header])
+	if err != nil {
+		return nil, err
+	}
+	body := raw[header:]
+	if len(body) < aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	plain, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], raw[:header])
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+// Шифрует содержимое файла со свежим nonce; соль сохраняется между записями.
+func (s *EncryptedFileStore) encrypt(plain []byte) ([]byte, error) {
+	salt := s.salt
+	if salt == nil {
+		salt = make([]byte, encSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+	}
+	aead, err := s.cipher(salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteString(encMagic)
+	out.Write(salt)
+	header := append([]byte(nil), out.Bytes()...)
+	out.Write(nonce)
+	out.Write(aead.Seal(nil, nonce, plain, header))
+	return out.Bytes(), nil
+}
+
+// Возвращает AEAD для соли; выведенный ключ кэшируется, пока соль не меняется.
+func (s *EncryptedFileStore) cipher(salt []byte) (cipher.AEAD, error) {
+	if s.key == nil || !bytes.Equal(s.salt, salt) {
+		s.salt = append([]byte(nil), salt...)
+		s.key = pbkdf2SHA256(s.passphrase, s.salt, encIterations, encKeySize)
+	}
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Реализует PBKDF2 (RFC 8018) с HMAC‑SHA256.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	key := make([]byte, 0, blocks*hashLen)
+	var counter [4]byte
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Write(counter[:])
+		u = prf.Sum(u[:0])
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,215 @@
+package sessionstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Текущая версия формата файла сессий. Версия 1 — одна сессия без ключа аккаунта.
+const fileVersion = 2
+
+// Содержимое файла сессий версии 2.
+type fileContents struct {
+	Version  int              `json:"version"`
+	Accounts map[string]*Data `json:"accounts"`
+}
+
+// Хранит сессии в JSON‑файле (права 0600). Не требует cgo. Один файл можно безопасно
+// делить между несколькими хранилищами и процессами: изменения сериализуются блокировкой
+// соседнего файла path + ".lock".
+type FileStore struct {
+	accountsFile
+}
+
+// Создаёт хранилище в файле path; файл появится при первом сохранении.
+func NewFile(path string) (*FileStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("session file path is empty")
+	}
+	return &FileStore{newAccountsFile(path)}, nil
+}
+
+// Файл с сессиями нескольких аккаунтов, общий для FileStore и EncryptedFileStore.
+// Каждое изменение под блокировкой перечитывает и атомарно перезаписывает файл целиком,
+// поэтому хранилища одного файла в разных клиентах и процессах не теряют записи друг друга.
+type accountsFile struct {
+	path string
+	// mu общий для всех хранилищ этого файла в процессе.
+	mu *sync.Mutex
+
+	// seal и open преобразуют содержимое файла при записи и чтении (например, шифруют).
+	seal func(plain []byte) ([]byte, error)
+	open func(raw []byte) ([]byte, error)
+}
+
+// Мьютексы файлов сессий по абсолютному пути.
+var (
+	fileLocksMu sync.Mutex
+	fileLocks   = make(map[string]*sync.Mutex)
+)
+
+func newAccountsFile(path string) accountsFile {
+	key := path
+	if abs, err := filepath.Abs(path); err == nil {
+		key = abs
+	}
+	fileLocksMu.Lock()
+	defer fileLocksMu.Unlock()
+	mu, ok := fileLocks[key]
+	if !ok {
+		mu = new(sync.Mutex)
+		fileLocks[key] = mu
+	}
+	return accountsFile{path: path, mu: mu}
+}
+
+// Захватывает файл для изменения: мьютекс процесса и межпроцессную блокировку path + ".lock".
+// Чтениям достаточно мьютекса: файл всегда заменяется атомарно.
+func (f *accountsFile) lock() (unlock func(), err error) {
+	f.mu.Lock()
+	release, err := lockFile(f.path + ".lock")
+	if err != nil {
+		f.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		release()
+		f.mu.Unlock()
+	}, nil
+}
+
+// Возвращает сессию аккаунта из файла.
+func (f *accountsFile) Load(ctx context.Context, account string) (*Data, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	accounts, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	return accounts[account], nil
+}
+
+// Сохраняет сессию аккаунта, не затрагивая остальные.
+func (f *accountsFile) Save(ctx context.Context, account string, data *Data) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	accounts, err := f.read()
+	if err != nil {
+		return err
+	}
+	accounts[account] = data
+	return f.write(accounts)
+}
+
+// Удаляет сессию аккаунта из файла.
+func (f *accountsFile) Delete(ctx context.Context, account string) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	accounts, err := f.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := accounts[account]; !ok {
+		return nil
+	}
+	delete(accounts, account)
+	return f.write(accounts)
+}
+
+// Возвращает список аккаунтов с сохранённой сессией.
+func (f *accountsFile) Accounts(ctx context.Context) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	accounts, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	return sortedAccounts(accounts), nil
+}
+
+// Ничего не делает: файл открывается только на время чтения и записи.
+func (f *accountsFile) Close() error {
+	return nil
+}
+
+// Читает все сессии; отсутствие файла означает пустой набор.
+// Файл версии 1 читается как единственная сессия под LegacyAccount.
+func (f *accountsFile) read() (map[string]*Data, error) {
+	raw, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]*Data), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.open != nil {
+		if raw, err = f.open(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	var probe struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, fmt.Errorf("failed to decode session file %s: %w", f.path, err)
+	}
+	switch {
+	case probe.Version == 0:
+		var legacy Data
+		if err := json.Unmarshal(raw, &legacy); err != nil {
+			return nil, fmt.Errorf("failed to decode session file %s: %w", f.path, err)
+		}
+		return map[string]*Data{LegacyAccount: &legacy}, nil
+	case probe.Version > fileVersion:
+		return nil, fmt.Errorf("session file %s has unsupported version %d", f.path, probe.Version)
+	}
+
+	var contents fileContents
+	if err := json.Unmarshal(raw, &contents); err != nil {
+		return nil, fmt.Errorf("failed to decode session file %s: %w", f.path, err)
+	}
+	if contents.Accounts == nil {
+		contents.Accounts = make(map[string]*Data)
+	}
+	return contents.Accounts, nil
+}
+
+// Записывает все сессии в формате текущей версии.
+func (f *accountsFile) write(accounts map[string]*Data) error {
+	raw, err := json.Marshal(fileContents{Version: fileVersion, Accounts: accounts})
+	if err != nil {
+		return err
+	}
+	if f.seal != nil {
+		if raw, err = f.seal(raw); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(f.path, raw)
+}
+
+// Возвращает отсортированные ключи аккаунтов без LegacyAccount.
+func sortedAccounts(accounts map[string]*Data) []string {
+	list := make([]string, 0, len(accounts))
+	for account := range accounts {
+		if account != LegacyAccount {
+			list = append(list, account)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package sessionstore
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Захватывает эксклюзивную блокировку flock на файле path, создавая его при необходимости.
+// Блокировка снимается ядром и при аварийном завершении процесса.
+func lockFile(path string) (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
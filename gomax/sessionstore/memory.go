@@ -0,0 +1,53 @@
+package sessionstore
+
+import (
+	"context"
+	"sync"
+)
+
+// Хранит сессии в памяти процесса. Подходит для тестов и stateless‑контейнеров,
+// где токен передаётся через ClientConfig.Token или загружается извне.
+type MemoryStore struct {
+	mu       sync.Mutex
+	accounts map[string]*Data
+}
+
+// Создаёт пустое хранилище в памяти.
+func NewMemory() *MemoryStore {
+	return &MemoryStore{accounts: make(map[string]*Data)}
+}
+
+// Возвращает копию сессии аккаунта.
+func (s *MemoryStore) Load(ctx context.Context, account string) (*Data, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accounts[account].Clone(), nil
+}
+
+// Сохраняет копию сессии аккаунта.
+func (s *MemoryStore) Save(ctx context.Context, account string, data *Data) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[account] = data.Clone()
+	return nil
+}
+
+// Удаляет сессию аккаунта.
+func (s *MemoryStore) Delete(ctx context.Context, account string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.accounts, account)
+	return nil
+}
+
+// Возвращает список аккаунтов с сохранённой сессией.
+func (s *MemoryStore) Accounts(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedAccounts(s.accounts), nil
+}
+
+// Ничего не делает: хранилищу в памяти нечего закрывать.
+func (s *MemoryStore) Close() error {
+	return nil
+}
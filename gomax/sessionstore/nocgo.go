@@ -0,0 +1,6 @@
+//go:build !cgo
+
+package sessionstore
+
+// Без cgo SQLite‑драйвер недоступен, по умолчанию используется JSON‑файл.
+const cgoEnabled = false
@@ -0,0 +1,262 @@
+package sessionstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testData возвращает заполненную сессию со state.
+func testData() *Data {
+	return &Data{
+		DeviceID:   "0b4d3c52-7f5e-4a43-9d8e-3c1a3b1f2e10",
+		Token:      "secret-token",
+		DeviceType: "DESKTOP",
+		State:      map[string]json.RawMessage{"chats": json.RawMessage(`{"marker":42}`)},
+	}
+}
+
+// TestStores_Accounts проверяет для всех реализаций загрузку, сохранение, перезапись,
+// изоляцию аккаунтов, список и удаление.
+func TestStores_Accounts(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	open := map[string]func() (Store, error){
+		"memory": func() (Store, error) { return NewMemory(), nil },
+		"file":   func() (Store, error) { return NewFile(filepath.Join(dir, "session.json")) },
+		"encrypted": func() (Store, error) {
+			return NewEncryptedFile(filepath.Join(dir, "session.enc"), "passphrase")
+		},
+		"sqlite": func() (Store, error) { return NewSQLite(filepath.Join(dir, "session.db")) },
+	}
+
+	for name, newStore := range open {
+		t.Run(name, func(t *testing.T) {
+			store, err := newStore()
+			require.NoError(t, err)
+			defer store.Close()
+
+			loaded, err := store.Load(ctx, "+79990000001")
+			require.NoError(t, err)
+			assert.Nil(t, loaded)
+			accounts, err := store.Accounts(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, accounts)
+
+			data := testData()
+			require.NoError(t, store.Save(ctx, "+79990000001", data))
+			data.State["chats"][0] = 'x' // хранилище не должно делить память с вызывающим
+			require.NoError(t, store.Save(ctx, "+79990000002", &Data{DeviceID: "second", Token: "other"}))
+
+			loaded, err = store.Load(ctx, "+79990000001")
+			require.NoError(t, err)
+			assert.Equal(t, testData(), loaded)
+
+			loaded.Token = "rotated"
+			loaded.State = nil
+			require.NoError(t, store.Save(ctx, "+79990000001", loaded))
+			again, err := store.Load(ctx, "+79990000001")
+			require.NoError(t, err)
+			assert.Equal(t, "rotated", again.Token)
+			assert.Empty(t, again.State)
+
+			other, err := store.Load(ctx, "+79990000002")
+			require.NoError(t, err)
+			assert.Equal(t, "other", other.Token)
+
+			accounts, err = store.Accounts(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"+79990000001", "+79990000002"}, accounts)
+
+			require.NoError(t, store.Delete(ctx, "+79990000001"))
+			require.NoError(t, store.Delete(ctx, "+79990000003"))
+			loaded, err = store.Load(ctx, "+79990000001")
+			require.NoError(t, err)
+			assert.Nil(t, loaded)
+			accounts, err = store.Accounts(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"+79990000002"}, accounts)
+		})
+	}
+}
+
+// TestFile_LegacyFormat проверяет чтение файла версии 1 как LegacyAccount
+// и запись в формате с версией.
+func TestFile_LegacyFormat(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"deviceId":"legacy-device","token":"legacy-token","deviceType":"WEB"}`), 0600))
+
+	store, err := NewFile(path)
+	require.NoError(t, err)
+
+	legacy, err := store.Load(ctx, LegacyAccount)
+	require.NoError(t, err)
+	assert.Equal(t, &Data{DeviceID: "legacy-device", Token: "legacy-token", DeviceType: "WEB"}, legacy)
+	accounts, err := store.Accounts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+
+	require.NoError(t, store.Save(ctx, "+79990000001", legacy))
+	require.NoError(t, store.Delete(ctx, LegacyAccount))
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var contents fileContents
+	require.NoError(t, json.Unmarshal(raw, &contents))
+	assert.Equal(t, fileVersion, contents.Version)
+	assert.Equal(t, map[string]*Data{"+79990000001": legacy}, contents.Accounts)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version":99,"accounts":{}}`), 0600))
+	_, err = store.Load(ctx, LegacyAccount)
+	assert.Error(t, err)
+}
+
+// TestFile_ConcurrentWriters проверяет, что хранилища одного файла в разных клиентах
+// и процессах не теряют сессии друг друга при одновременной записи.
+func TestFile_ConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	const perWriter = 20
+
+	var cmds []*exec.Cmd
+	for _, prefix := range []string{"+7100", "+7200"} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestFile_WriterProcess$")
+		cmd.Env = append(os.Environ(), "SESSIONSTORE_WRITER_PATH="+path, "SESSIONSTORE_WRITER_PREFIX="+prefix)
+		require.NoError(t, cmd.Start())
+		cmds = append(cmds, cmd)
+	}
+
+	var wg sync.WaitGroup
+	for _, prefix := range []string{"+7300", "+7400"} {
+		store, err := NewFile(path)
+		require.NoError(t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, writeAccounts(store, prefix, perWriter))
+		}()
+	}
+	wg.Wait()
+	for _, cmd := range cmds {
+		require.NoError(t, cmd.Wait())
+	}
+
+	store, err := NewFile(path)
+	require.NoError(t, err)
+	accounts, err := store.Accounts(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, accounts, 4*perWriter)
+}
+
+// TestFile_WriterProcess — вспомогательный процесс для TestFile_ConcurrentWriters.
+func TestFile_WriterProcess(t *testing.T) {
+	path := os.Getenv("SESSIONSTORE_WRITER_PATH")
+	if path == "" {
+		t.Skip("helper process")
+	}
+	store, err := NewFile(path)
+	require.NoError(t, err)
+	require.NoError(t, writeAccounts(store, os.Getenv("SESSIONSTORE_WRITER_PREFIX"), 20))
+}
+
+// writeAccounts сохраняет count сессий с номерами prefix000…
+func writeAccounts(store Store, prefix string, count int) error {
+	for i := 0; i < count; i++ {
+		if err := store.Save(context.Background(), fmt.Sprintf("%s%03d", prefix, i), testData()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TestEncryptedFile проверяет, что файл не содержит токена в открытом виде
+// и не читается с неверным паролем.
+func TestEncryptedFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.enc")
+
+	store, err := NewEncryptedFile(path, "correct horse")
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, "+79990000001", testData()))
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret-token")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	reopened, err := NewEncryptedFile(path, "correct horse")
+	require.NoError(t, err)
+	loaded, err := reopened.Load(ctx, "+79990000001")
+	require.NoError(t, err)
+	assert.Equal(t, "secret-token", loaded.Token)
+
+	wrong, err := NewEncryptedFile(path, "battery staple")
+	require.NoError(t, err)
+	_, err = wrong.Load(ctx, "+79990000001")
+	assert.ErrorIs(t, err, ErrWrongPassphrase)
+
+	_, err = NewEncryptedFile(path, "")
+	assert.Error(t, err)
+}
+
+// TestSQLite_LegacySchema проверяет миграцию session.db без версии схемы:
+// первая строка становится LegacyAccount, лишние строки удаляются.
+func TestSQLite_LegacySchema(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.db")
+	conn, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = conn.Exec(`CREATE TABLE auth (id INTEGER PRIMARY KEY AUTOINCREMENT, device_id TEXT NOT NULL, token TEXT, device_type TEXT);
+INSERT INTO auth(device_id, token, device_type) VALUES('legacy-device', 'legacy-token', 'WEB');
+INSERT INTO auth(device_id, token, device_type) VALUES('unused-device', '', 'WEB');`)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	store, err := NewSQLite(path)
+	require.NoError(t, err)
+
+	var version int
+	require.NoError(t, store.db.QueryRow(`PRAGMA user_version`).Scan(&version))
+	assert.Equal(t, len(sqliteMigrations), version)
+
+	loaded, err := store.Load(ctx, LegacyAccount)
+	require.NoError(t, err)
+	assert.Equal(t, &Data{DeviceID: "legacy-device", Token: "legacy-token", DeviceType: "WEB"}, loaded)
+	accounts, err := store.Accounts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+	require.NoError(t, store.Close())
+
+	// Повторное открытие не применяет миграции заново.
+	store, err = NewSQLite(path)
+	require.NoError(t, err)
+	defer store.Close()
+	loaded, err = store.Load(ctx, LegacyAccount)
+	require.NoError(t, err)
+	assert.Equal(t, "legacy-token", loaded.Token)
+}
+
+// TestPBKDF2 проверяет вывод ключа по тестовому вектору RFC 7914 (PBKDF2‑HMAC‑SHA256).
+func TestPBKDF2(t *testing.T) {
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	assert.Equal(t, strings.Join([]string{
+		"55ac046e56e3089fec1691c22544b605",
+		"f94185216dde0465e68b9d57c20dacbc",
+		"49ca9cccf179b645991664b39d77ef31",
+		"7c71b845b1e30bd509112041d3a19783",
+	}, ""), hex.EncodeToString(key))
+}
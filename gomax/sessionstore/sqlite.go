@@ -0,0 +1,192 @@
+package sessionstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Хранит сессии в SQLite‑базе (таблица auth), совместимой с session.db прежних версий.
+// Драйвер go-sqlite3 требует cgo.
+type SQLiteStore struct {
+	path string
+	db   *sql.DB
+}
+
+// Открывает или создаёт SQLite‑базу по пути path и выполняет миграции схемы.
+func NewSQLite(path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create work directory: %w", err)
+	}
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	s := &SQLiteStore{path: path, db: conn}
+	if err := s.migrate(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Миграции схемы по порядку; номер версии схемы — индекс миграции плюс один.
+// Версия хранится в PRAGMA user_version. Базы, созданные до версионирования,
+// имеют user_version = 0, поэтому миграции обязаны быть идемпотентными.
+var sqliteMigrations = []func(tx *sql.Tx) error{
+	// 1: исходная таблица с единственной сессией.
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+CREATE TABLE IF NOT EXISTS auth (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  device_id TEXT NOT NULL,
+  token TEXT,
+  device_type TEXT
+);
+`)
+		return err
+	},
+	// 2: кэшированное состояние клиента.
+	func(tx *sql.Tx) error {
+		return addColumn(tx, "state", "TEXT")
+	},
+	// 3: ключ аккаунта; существующая строка становится сессией LegacyAccount.
+	func(tx *sql.Tx) error {
+		if err := addColumn(tx, "phone", "TEXT"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE auth SET phone = ? WHERE phone IS NULL`, LegacyAccount); err != nil {
+			return err
+		}
+		// Прежние версии читали только первую строку; остальные никогда не использовались.
+		if _, err := tx.Exec(`DELETE FROM auth WHERE phone = ? AND id <> (SELECT MIN(id) FROM auth WHERE phone = ?)`, LegacyAccount, LegacyAccount); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS auth_phone ON auth(phone)`)
+		return err
+	},
+}
+
+// Применяет недостающие миграции, каждую в своей транзакции вместе с обновлением версии.
+func (s *SQLiteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("session database %s has unsupported schema version %d", s.path, version)
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := sqliteMigrations[i](tx); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("session database migration %d failed: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Добавляет колонку в таблицу auth, если её ещё нет.
+func addColumn(tx *sql.Tx, name, typ string) error {
+	var exists bool
+	if err := tx.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('auth') WHERE name = ?`, name).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	_, err := tx.Exec(fmt.Sprintf(`ALTER TABLE auth ADD COLUMN %s %s`, name, typ))
+	return err
+}
+
+// Возвращает сессию аккаунта или (nil, nil), если строки нет.
+func (s *SQLiteStore) Load(ctx context.Context, account string) (*Data, error) {
+	var deviceID string
+	var token, deviceType, state sql.NullString
+	err := s.db.QueryRowContext(ctx, `SELECT device_id, token, device_type, state FROM auth WHERE phone = ?`, account).
+		Scan(&deviceID, &token, &deviceType, &state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Data{DeviceID: deviceID, Token: token.String, DeviceType: deviceType.String}
+	if state.Valid && state.String != "" {
+		if err := json.Unmarshal([]byte(state.String), &data.State); err != nil {
+			return nil, fmt.Errorf("failed to decode session state: %w", err)
+		}
+	}
+	return data, nil
+}
+
+// Создаёт или обновляет строку сессии аккаунта.
+func (s *SQLiteStore) Save(ctx context.Context, account string, data *Data) error {
+	var state sql.NullString
+	if len(data.State) > 0 {
+		raw, err := json.Marshal(data.State)
+		if err != nil {
+			return err
+		}
+		state = sql.NullString{String: string(raw), Valid: true}
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+INSERT INTO auth(phone, device_id, token, device_type, state) VALUES(?,?,?,?,?)
+ON CONFLICT(phone) DO UPDATE SET
+  device_id = excluded.device_id,
+  token = excluded.token,
+  device_type = excluded.device_type,
+  state = excluded.state`,
+		account, data.DeviceID, data.Token, data.DeviceType, state,
+	)
+	return err
+}
+
+// Удаляет строку сессии аккаунта.
+func (s *SQLiteStore) Delete(ctx context.Context, account string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM auth WHERE phone = ?`, account)
+	return err
+}
+
+// Возвращает список аккаунтов с сохранённой сессией.
+func (s *SQLiteStore) Accounts(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT phone FROM auth WHERE phone <> ? ORDER BY phone`, LegacyAccount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []string{}
+	for rows.Next() {
+		var phone string
+		if err := rows.Scan(&phone); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, phone)
+	}
+	return accounts, rows.Err()
+}
+
+// Закрывает подключение к базе данных.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,94 @@
+// Пакет sessionstore описывает хранилище сессии MaxClient (идентификатор устройства,
+// токен авторизации, тип устройства и кэшированное состояние) и его реализации:
+// SQLite, JSON‑файл, память процесса и зашифрованный паролем файл.
+package sessionstore
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Данные сессии, которые MaxClient сохраняет между запусками.
+type Data struct {
+	DeviceID   string `json:"deviceId"`
+	Token      string `json:"token,omitempty"`
+	DeviceType string `json:"deviceType,omitempty"`
+	// State — кэшированное состояние клиента по ключам; хранилище сохраняет его как есть.
+	State map[string]json.RawMessage `json:"state,omitempty"`
+}
+
+// Возвращает глубокую копию данных, чтобы хранилища не делили срезы и карты с вызывающим кодом.
+func (d *Data) Clone() *Data {
+	if d == nil {
+		return nil
+	}
+	clone := *d
+	if d.State != nil {
+		clone.State = make(map[string]json.RawMessage, len(d.State))
+		for k, v := range d.State {
+			clone.State[k] = append(json.RawMessage(nil), v...)
+		}
+	}
+	return &clone
+}
+
+// Хранилище сессий, ключом которых служит аккаунт (номер телефона). Одно хранилище
+// может обслуживать несколько MaxClient с разными номерами. Реализации должны быть
+// безопасны для конкурентного использования. Собственное хранилище (например, поверх
+// менеджера секретов) достаточно реализовать этим интерфейсом и передать
+// в ClientConfig.SessionStore.
+//
+// Сессия, записанная версиями без поддержки нескольких аккаунтов, доступна под ключом
+// LegacyAccount; MaxClient переносит её на свой номер при первом запуске.
+type Store interface {
+	// Load возвращает сессию аккаунта или (nil, nil), если её нет.
+	Load(ctx context.Context, account string) (*Data, error)
+	// Save полностью заменяет сессию аккаунта.
+	Save(ctx context.Context, account string, data *Data) error
+	// Delete удаляет сессию аккаунта; отсутствие сессии ошибкой не считается.
+	Delete(ctx context.Context, account string) error
+	// Accounts возвращает отсортированный список аккаунтов с сохранённой сессией
+	// (без LegacyAccount).
+	Accounts(ctx context.Context) ([]string, error)
+	// Close освобождает ресурсы хранилища.
+	Close() error
+}
+
+// LegacyAccount — ключ сессии, сохранённой до появления нескольких аккаунтов в одном хранилище.
+const LegacyAccount = ""
+
+// Открывает хранилище по умолчанию в рабочей директории: SQLite‑базу session.db,
+// а в сборках без cgo — JSON‑файл session.json.
+func Default(workdir string) (Store, error) {
+	if cgoEnabled {
+		return NewSQLite(filepath.Join(workdir, "session.db"))
+	}
+	return NewFile(filepath.Join(workdir, "session.json"))
+}
+
+// Атомарно записывает файл: сначала во временный файл рядом, затем rename.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
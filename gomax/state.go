@@ -0,0 +1,109 @@
+package gomax
+
+import (
+	"context"
+)
+
+// Описывает этап жизненного цикла соединения MaxClient.
+type ConnectionState int
+
+const (
+	// StateIdle — клиент создан, но Start ещё не вызывался.
+	StateIdle ConnectionState = iota
+	// StateConnecting — устанавливается WebSocket‑соединение.
+	StateConnecting
+	// StateHandshaking — выполняется SESSION_INIT.
+	StateHandshaking
+	// StateAuthenticating — выполняется логин или регистрация.
+	StateAuthenticating
+	// StateSyncing — выполняется первичный SYNC профиля и чатов.
+	StateSyncing
+	// StateReady — клиент подключён и готов к работе.
+	StateReady
+	// StateReconnecting — соединение потеряно, клиент ожидает следующей попытки переподключения.
+	StateReconnecting
+	// StateClosed — клиент остановлен и больше не переподключается.
+	StateClosed
+)
+
+// String возвращает читаемое имя состояния для логов.
+func (s ConnectionState) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateConnecting:
+		return "connecting"
+	case StateHandshaking:
+		return "handshaking"
+	case StateAuthenticating:
+		return "authenticating"
+	case StateSyncing:
+		return "syncing"
+	case StateReady:
+		return "ready"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// State возвращает текущее состояние соединения. Потокобезопасен.
+func (c *MaxClient) State() ConnectionState {
+	c.connStateMu.Lock()
+	defer c.connStateMu.Unlock()
+	return c.connState
+}
+
+// WaitReady блокируется, пока клиент не перейдёт в StateReady.
+// Если клиент закрыт, возвращает причину остановки (см. Err) или ClientClosedError,
+// при отмене контекста — ошибку контекста.
+func (c *MaxClient) WaitReady(ctx context.Context) error {
+	for {
+		c.connStateMu.Lock()
+		state := c.connState
+		changed := c.connStateCh
+		termErr := c.termErr
+		c.connStateMu.Unlock()
+
+		switch state {
+		case StateReady:
+			return nil
+		case StateClosed:
+			if termErr != nil {
+				return termErr
+			}
+			return &ClientClosedError{}
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// setState переводит клиента в новое состояние, будит ожидающих WaitReady
+// и асинхронно вызывает обработчики OnStateChange.
+func (c *MaxClient) setState(ctx context.Context, state ConnectionState) {
+	c.connStateMu.Lock()
+	prev := c.connState
+	if prev == state {
+		c.connStateMu.Unlock()
+		return
+	}
+	c.connState = state
+	close(c.connStateCh)
+	c.connStateCh = make(chan struct{})
+	handlers := c.onStateChangeHandlers
+	c.connStateMu.Unlock()
+
+	c.logger.Debug("Connection state changed", "from", prev, "to", state)
+
+	for _, h := range handlers {
+		go h(ctx, prev, state)
+	}
+}
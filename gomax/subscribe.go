@@ -0,0 +1,178 @@
+package gomax
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/enums"
+)
+
+// Определяет поведение подписки, буфер которой заполнен.
+type OverflowPolicy int
+
+const (
+	// OverflowDropNewest отбрасывает новое событие, если подписчик не успевает читать.
+	OverflowDropNewest OverflowPolicy = iota
+	// OverflowDropOldest вытесняет самое старое событие из буфера в пользу нового.
+	OverflowDropOldest
+	// OverflowBlock приостанавливает чтение из сокета, пока подписчик не освободит место.
+	// Медленный подписчик задерживает все обработчики и ответы на запросы.
+	OverflowBlock
+)
+
+// String возвращает читаемое имя политики для логов.
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropNewest:
+		return "drop-newest"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowBlock:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+// Event — серверное уведомление, доставляемое подписчикам Subscribe.
+// Payload декодируется через Decode в нужную структуру.
+type Event struct {
+	Frame
+	ReceivedAt time.Time
+}
+
+// Описывает одну подписку на поток событий.
+type subscription struct {
+	opcodes map[enums.Opcode]struct{}
+	ch      chan Event
+	done    chan struct{}
+	once    sync.Once
+	dropped int
+}
+
+// Проверяет, интересует ли подписчика событие с данным opcode.
+func (s *subscription) wants(opcode enums.Opcode) bool {
+	if len(s.opcodes) == 0 {
+		return true
+	}
+	_, ok := s.opcodes[opcode]
+	return ok
+}
+
+// Subscribe подписывается на серверные уведомления с указанными opcode
+// (без аргументов — на все). Ответы на запросы клиента в поток не попадают.
+//
+// Каждая подписка имеет собственный буфер размером ClientConfig.EventBuffer;
+// при его переполнении действует ClientConfig.EventOverflow. Канал закрывается
+// вызовом unsubscribe или при окончательной остановке клиента (см. Done).
+func (c *MaxClient) Subscribe(opcodes ...enums.Opcode) (<-chan Event, func()) {
+	sub := &subscription{
+		ch:   make(chan Event, c.cfg.EventBuffer),
+		done: make(chan struct{}),
+	}
+	if len(opcodes) > 0 {
+		sub.opcodes = make(map[enums.Opcode]struct{}, len(opcodes))
+		for _, op := range opcodes {
+			sub.opcodes[op] = struct{}{}
+		}
+	}
+
+	c.subsMu.Lock()
+	select {
+	case <-c.done:
+		c.subsMu.Unlock()
+		sub.close()
+		return sub.ch, func() {}
+	default:
+	}
+	c.subs[sub] = struct{}{}
+	c.subsMu.Unlock()
+
+	return sub.ch, func() { c.unsubscribe(sub) }
+}
+
+// Удаляет подписку и закрывает её канал. Повторный вызов безопасен.
+func (c *MaxClient) unsubscribe(sub *subscription) {
+	sub.once.Do(func() {
+		// Сначала снимаем возможную блокировку publish, затем ждём его выхода из‑под subsMu.
+		close(sub.done)
+		c.subsMu.Lock()
+		delete(c.subs, sub)
+		close(sub.ch)
+		c.subsMu.Unlock()
+	})
+}
+
+// Закрывает канал подписки, не зарегистрированной в клиенте.
+func (s *subscription) close() {
+	s.once.Do(func() {
+		close(s.done)
+		close(s.ch)
+	})
+}
+
+// Закрывает все подписки при окончательной остановке клиента.
+func (c *MaxClient) closeSubscriptions() {
+	c.subsMu.Lock()
+	subs := make([]*subscription, 0, len(c.subs))
+	for sub := range c.subs {
+		subs = append(subs, sub)
+	}
+	c.subsMu.Unlock()
+
+	for _, sub := range subs {
+		c.unsubscribe(sub)
+	}
+}
+
+// Доставляет кадр уведомления всем подходящим подписчикам с учётом OverflowPolicy.
+func (c *MaxClient) publish(ctx context.Context, frame *Frame) {
+	c.subsMu.RLock()
+	defer c.subsMu.RUnlock()
+	if len(c.subs) == 0 {
+		return
+	}
+
+	ev := Event{Frame: *frame, ReceivedAt: time.Now()}
+	for sub := range c.subs {
+		if !sub.wants(frame.Opcode) {
+			continue
+		}
+		select {
+		case <-sub.done:
+			continue
+		default:
+		}
+
+		switch c.cfg.EventOverflow {
+		case OverflowBlock:
+			select {
+			case sub.ch <- ev:
+			case <-sub.done:
+			case <-ctx.Done():
+				return
+			}
+		case OverflowDropOldest:
+			for delivered := false; !delivered; {
+				select {
+				case sub.ch <- ev:
+					delivered = true
+				default:
+					select {
+					case <-sub.ch:
+						sub.dropped++
+					default:
+					}
+				}
+			}
+		default:
+			select {
+			case sub.ch <- ev:
+			default:
+				sub.dropped++
+				c.logger.Debug("Subscriber buffer is full, event dropped", "opcode", frame.Opcode, "dropped", sub.dropped)
+			}
+		}
+	}
+}
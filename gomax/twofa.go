@@ -0,0 +1,131 @@
+package gomax
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fresh-milkshake/gomax/enums"
+	"github.com/fresh-milkshake/gomax/internal/payloads"
+)
+
+// Задаёт новый облачный пароль для EnableTwoFactor и ChangeTwoFactor.
+type TwoFactorOptions struct {
+	// Password — новый облачный пароль. Обязателен.
+	Password string
+	// Hint — необязательная подсказка к паролю, показывается при входе.
+	Hint string
+	// Email — необязательная почта для восстановления пароля.
+	// Если задана, сервер отправит на неё код, который вернёт EmailCodeProvider.
+	Email string
+	// EmailCodeProvider предоставляет код из письма; обязателен, если задан Email.
+	EmailCodeProvider func(ctx context.Context, email string) (string, error)
+}
+
+// Включает двухфакторную аутентификацию с облачным паролем.
+func (c *MaxClient) EnableTwoFactor(ctx context.Context, opts TwoFactorOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	trackID, err := c.createTrack(ctx, enums.TwoFactorEnable, "")
+	if err != nil {
+		return err
+	}
+	return c.setPassword(ctx, trackID, opts)
+}
+
+// Меняет облачный пароль (и при необходимости подсказку и почту),
+// подтверждая операцию текущим паролем.
+func (c *MaxClient) ChangeTwoFactor(ctx context.Context, currentPassword string, opts TwoFactorOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	trackID, err := c.createTrack(ctx, enums.TwoFactorChange, currentPassword)
+	if err != nil {
+		return err
+	}
+	return c.setPassword(ctx, trackID, opts)
+}
+
+// Отключает двухфакторную аутентификацию, подтверждая операцию текущим паролем.
+func (c *MaxClient) DisableTwoFactor(ctx context.Context, currentPassword string) error {
+	trackID, err := c.createTrack(ctx, enums.TwoFactorDisable, currentPassword)
+	if err != nil {
+		return err
+	}
+	return c.request(ctx, enums.OpcodeAuthSet2FA, payloads.Set2FAPayload{
+		TrackID: trackID,
+		Enabled: false,
+	}, nil)
+}
+
+// Проверяет обязательные поля до отправки запросов на сервер.
+func (o TwoFactorOptions) validate() error {
+	if o.Password == "" {
+		return fmt.Errorf("2FA password is empty")
+	}
+	if o.Email != "" && o.EmailCodeProvider == nil {
+		return fmt.Errorf("EmailCodeProvider is required when Email is set")
+	}
+	return nil
+}
+
+// Создаёт трек изменения облачного пароля и возвращает его идентификатор.
+func (c *MaxClient) createTrack(ctx context.Context, action enums.TwoFactorAction, currentPassword string) (string, error) {
+	var resp trackResponse
+	if err := c.request(ctx, enums.OpcodeAuthCreateTrack, payloads.CreateTrackPayload{
+		Type:     action,
+		Password: currentPassword,
+	}, &resp); err != nil {
+		return "", err
+	}
+	if resp.TrackID == "" {
+		return "", &ResponseError{Message: "trackId not received"}
+	}
+	return resp.TrackID, nil
+}
+
+// Проходит шаги трека: проверка пароля, подсказки, подтверждение почты и применение настроек.
+func (c *MaxClient) setPassword(ctx context.Context, trackID string, opts TwoFactorOptions) error {
+	if err := c.request(ctx, enums.OpcodeAuthValidatePassword, payloads.TrackPasswordPayload{
+		TrackID:  trackID,
+		Password: opts.Password,
+	}, nil); err != nil {
+		return err
+	}
+
+	if opts.Hint != "" {
+		if err := c.request(ctx, enums.OpcodeAuthValidateHint, payloads.ValidateHintPayload{
+			TrackID: trackID,
+			Hint:    opts.Hint,
+		}, nil); err != nil {
+			return err
+		}
+	}
+
+	if opts.Email != "" {
+		if err := c.request(ctx, enums.OpcodeAuthVerifyEmail, payloads.VerifyEmailPayload{
+			TrackID: trackID,
+			Email:   opts.Email,
+		}, nil); err != nil {
+			return err
+		}
+		code, err := opts.EmailCodeProvider(ctx, opts.Email)
+		if err != nil {
+			return err
+		}
+		if err := c.request(ctx, enums.OpcodeAuthCheckEmail, payloads.CheckEmailPayload{
+			TrackID:    trackID,
+			VerifyCode: code,
+		}, nil); err != nil {
+			return err
+		}
+	}
+
+	return c.request(ctx, enums.OpcodeAuthSet2FA, payloads.Set2FAPayload{
+		TrackID:  trackID,
+		Enabled:  true,
+		Password: opts.Password,
+		Hint:     opts.Hint,
+		Email:    opts.Email,
+	}, nil)
+}
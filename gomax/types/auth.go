@@ -0,0 +1,11 @@
+package types
+
+// Описывает запрос облачного пароля, который сервер возвращает после проверки кода,
+// если у аккаунта включена двухфакторная аутентификация.
+type PasswordChallenge struct {
+	TrackID string `json:"trackId"`
+	// Hint — подсказка к паролю, заданная пользователем (может быть пустой).
+	Hint string `json:"hint,omitempty"`
+	// Email — маскированный адрес почты для восстановления (может быть пустым).
+	Email string `json:"email,omitempty"`
+}
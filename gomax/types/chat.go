@@ -27,6 +27,7 @@ type Chat struct {
 	PrevMessageID         *string          `json:"prevMessageId,omitempty"`
 	Restrictions          *int             `json:"restrictions,omitempty"`
 	Status                string           `json:"status"`
+	NewMessages           int              `json:"newMessages"` // число непрочитанных, поддерживается клиентом по NOTIF_MESSAGE и NOTIF_MARK
 }
 
 // Личный диалог.
@@ -46,6 +47,7 @@ type Dialog struct {
 	Modified              int64          `json:"modified"`
 	LastEventTime         int64          `json:"lastEventTime"`
 	Status                string         `json:"status"`
+	NewMessages           int            `json:"newMessages"` // число непрочитанных, поддерживается клиентом по NOTIF_MESSAGE и NOTIF_MARK
 }
 
 // Специализированный тип чата.
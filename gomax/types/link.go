@@ -0,0 +1,23 @@
+package types
+
+import "github.com/fresh-milkshake/gomax/enums"
+
+// Описывает результат разрешения ссылки Max (LINK_INFO).
+// Заполнены только поля, относящиеся к Type: для ссылки на сообщение — Message и, если сервер
+// его передал, Chat, в котором оно опубликовано.
+type LinkInfo struct {
+	Type    enums.LinkType `json:"type"`
+	Link    string         `json:"link"`
+	Chat    *Chat          `json:"chat,omitempty"`
+	User    *User          `json:"user,omitempty"`
+	Message *Message       `json:"message,omitempty"`
+}
+
+// Результат публичного поиска, разложенный по типам найденных объектов.
+type PublicSearchResult struct {
+	Chats    []*Chat `json:"chats"`
+	Channels []*Chat `json:"channels"`
+	Users    []*User `json:"users"`
+	// Marker — маркер следующей страницы или nil, если страниц больше нет.
+	Marker *int64 `json:"marker,omitempty"`
+}
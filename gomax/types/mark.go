@@ -0,0 +1,11 @@
+package types
+
+// Описывает уведомление о прочтении чата пользователем до отметки Mark.
+type ReadMark struct {
+	ChatID int64 `json:"chatId"`
+	UserID int64 `json:"userId"`
+	// Mark — время (в миллисекундах), до которого включительно сообщения прочитаны.
+	Mark int64 `json:"mark"`
+	// Unread — число непрочитанных сообщений, если сервер его передал.
+	Unread *int `json:"unread,omitempty"`
+}
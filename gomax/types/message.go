@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/json"
 	"strconv"
+	"strings"
 
 	"github.com/fresh-milkshake/gomax/enums"
 )
@@ -41,6 +42,10 @@ type Message struct {
 	Reactions *ReactionInfo        `json:"reactionInfo,omitempty"`
 	Link      *MessageLink         `json:"link,omitempty"`
 	Options   *int                 `json:"options,omitempty"`
+
+	// Replayed равен true, если сообщение не пришло уведомлением,
+	// а было догружено через историю после переподключения.
+	Replayed bool `json:"-"`
 }
 
 // UnmarshalJSON кастомно парсит Message, обрабатывая ID как строку или число.
@@ -48,7 +53,7 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 
 	type Alias Message
 	aux := &struct {
-		ID interface{} `json:"id"`
+		ID json.RawMessage `json:"id"`
 		*Alias
 	}{
 		Alias: (*Alias)(m),
@@ -58,23 +63,21 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	switch v := aux.ID.(type) {
-	case string:
-		id, err := strconv.ParseInt(v, 10, 64)
-		if err != nil {
+	// ID разбирается из исходного текста, чтобы не терять точность int64 на float64.
+	raw := strings.Trim(string(aux.ID), `"`)
+	if raw == "" || raw == "null" {
+		m.ID = 0
+		return nil
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		f, ferr := strconv.ParseFloat(raw, 64)
+		if ferr != nil {
 			return err
 		}
-		m.ID = id
-	case float64:
-		m.ID = int64(v)
-	case int64:
-		m.ID = v
-	case int:
-		m.ID = int64(v)
-	default:
-
-		m.ID = 0
+		id = int64(f)
 	}
+	m.ID = id
 
 	return nil
 }
@@ -1,9 +1,10 @@
 package types
 
 // Описывает информацию о присутствии пользователя (последнее время онлайн).
-// Структура соответствует Presence из PyMax.
+// Структура соответствует Presence из PyMax; Seen — Unix‑время в секундах.
 type Presence struct {
-	Seen *int64 `json:"seen,omitempty"`
+	Seen   *int64 `json:"seen,omitempty"`
+	Online *bool  `json:"online,omitempty"`
 }
 
 // Описывает одно представление имени пользователя, возвращаемое API Max.
@@ -0,0 +1,8 @@
+package types
+
+// Описывает уведомление о наборе текста собеседником в чате.
+type TypingEvent struct {
+	ChatID int64 `json:"chatId"`
+	UserID int64 `json:"userId"`
+	Typing bool  `json:"typing"`
+}
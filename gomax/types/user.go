@@ -38,6 +38,14 @@ type Contact struct {
 	UpdateTime    int64    `json:"updateTime"`
 }
 
+// Фотография профиля контакта.
+type ContactPhoto struct {
+	PhotoID    int64   `json:"photoId"`
+	BaseURL    string  `json:"baseUrl"`
+	BaseRawURL *string `json:"baseRawUrl,omitempty"`
+	Time       int64   `json:"time"`
+}
+
 // Участник чата.
 type Member struct {
 	Contact  Contact   `json:"contact"`
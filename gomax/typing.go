@@ -0,0 +1,129 @@
+package gomax
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/enums"
+	"github.com/fresh-milkshake/gomax/internal/constants"
+	"github.com/fresh-milkshake/gomax/internal/payloads"
+	"github.com/fresh-milkshake/gomax/types"
+)
+
+// Поддерживает индикатор набора текста в одном чате до остановки.
+type typingKeeper struct {
+	stop chan struct{}
+	once sync.Once
+}
+
+// Останавливает поддержку индикатора. Повторный вызов безопасен.
+func (k *typingKeeper) cancel() {
+	k.once.Do(func() { close(k.stop) })
+}
+
+// Отправляет в чат индикатор «печатает…». Индикатор гаснет сам через несколько секунд,
+// для длительных операций используйте KeepTyping.
+func (c *MaxClient) SendTyping(ctx context.Context, chatID int64) error {
+	pl := payloads.TypingPayload{
+		ChatID: chatID,
+	}
+	return c.request(ctx, enums.OpcodeMsgTyping, pl, nil)
+}
+
+// KeepTyping периодически отправляет индикатор набора текста в чат, пока не будет вызвана
+// возвращённая функция stop, не отменён ctx или пока SendMessage не отправит сообщение в этот чат.
+// Удобно для обработчиков, которые долго готовят ответ:
+//
+//	stop := client.KeepTyping(ctx, chatID)
+//	defer stop()
+//	reply := generateReply(ctx, msg)
+//	client.SendMessage(ctx, reply, chatID, true, nil, nil, nil)
+func (c *MaxClient) KeepTyping(ctx context.Context, chatID int64) (stop func()) {
+	k := &typingKeeper{stop: make(chan struct{})}
+
+	c.typingMu.Lock()
+	keepers, ok := c.typingKeepers[chatID]
+	if !ok {
+		keepers = make(map[*typingKeeper]struct{})
+		c.typingKeepers[chatID] = keepers
+	}
+	keepers[k] = struct{}{}
+	c.typingMu.Unlock()
+
+	go c.keepTyping(ctx, chatID, k)
+
+	return func() {
+		k.cancel()
+		c.removeTypingKeeper(chatID, k)
+	}
+}
+
+// Цикл повторной отправки индикатора набора текста.
+func (c *MaxClient) keepTyping(ctx context.Context, chatID int64, k *typingKeeper) {
+	defer c.removeTypingKeeper(chatID, k)
+
+	interval := time.Duration(constants.DefaultTypingInterval * float64(time.Second))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sendCtx, cancel := context.WithTimeout(ctx, interval)
+		if err := c.SendTyping(sendCtx, chatID); err != nil && ctx.Err() == nil {
+			c.logger.Debug("Failed to send typing indicator", "chatID", chatID, "err", err)
+		}
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-k.stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Удаляет поддержку индикатора из реестра клиента.
+func (c *MaxClient) removeTypingKeeper(chatID int64, k *typingKeeper) {
+	c.typingMu.Lock()
+	defer c.typingMu.Unlock()
+
+	keepers := c.typingKeepers[chatID]
+	delete(keepers, k)
+	if len(keepers) == 0 {
+		delete(c.typingKeepers, chatID)
+	}
+}
+
+// Останавливает все KeepTyping для чата; вызывается после отправки сообщения.
+func (c *MaxClient) stopTyping(chatID int64) {
+	c.typingMu.Lock()
+	keepers := c.typingKeepers[chatID]
+	delete(c.typingKeepers, chatID)
+	c.typingMu.Unlock()
+
+	for k := range keepers {
+		k.cancel()
+	}
+}
+
+// Регистрирует обработчик уведомлений о наборе текста собеседниками.
+func (c *MaxClient) OnTyping(handler func(context.Context, *types.TypingEvent)) {
+	c.onTypingHandlers = append(c.onTypingHandlers, handler)
+}
+
+// Разбирает NOTIF_TYPING и вызывает обработчики OnTyping.
+func (c *MaxClient) handleTyping(ctx context.Context, frame *Frame) {
+	event := &types.TypingEvent{}
+	if err := frame.Decode(event); err != nil {
+		c.logger.Warn("Failed to decode NOTIF_TYPING", "err", err)
+		return
+	}
+
+	for _, handler := range c.onTypingHandlers {
+		go handler(ctx, event)
+	}
+}
@@ -0,0 +1,215 @@
+package gomax
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fresh-milkshake/gomax/files"
+	"github.com/fresh-milkshake/gomax/internal/constants"
+)
+
+// Потоково отправляет содержимое file на url частями по UploadChunkSize байт
+// с заголовком Content-Range для каждой части. В памяти держится только текущая часть.
+//
+// Каждая часть повторяется по правилам doHTTPRequestWithRetry. Если сервер подтверждает
+// приём заголовком Range (например, "0-1048575"), следующая отправка начинается с первого
+// неподтверждённого байта, а не с конца отправленной части.
+func (c *MaxClient) uploadStream(ctx context.Context, url string, file files.BaseFile) error {
+	size, err := fileSize(ctx, file)
+	if err != nil {
+		return err
+	}
+	if size <= 0 {
+		return fmt.Errorf("cannot upload empty file %s", file.FileName())
+	}
+
+	r, err := openFile(ctx, file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	chunkSize := int64(c.cfg.UploadChunkSize)
+	if chunkSize <= 0 {
+		chunkSize = int64(constants.DefaultUploadChunkSize)
+	}
+	buf := make([]byte, min(chunkSize, size))
+
+	var (
+		offset int64 // первый неподтверждённый сервером байт
+		filled int   // байт в buf, начиная с offset
+		stalls int
+	)
+	for offset < size {
+		want := int(min(int64(len(buf)), size-offset))
+		if filled < want {
+			n, err := io.ReadFull(r, buf[filled:want])
+			filled += n
+			if err != nil {
+				return fmt.Errorf("read %s at offset %d: %w", file.FileName(), offset+int64(filled), err)
+			}
+		}
+
+		acked, err := c.uploadChunk(ctx, url, file.FileName(), buf[:filled], offset, size)
+		if err != nil {
+			return err
+		}
+		if acked <= offset {
+			stalls++
+			if stalls > c.cfg.MaxRetries {
+				return &ResponseError{Message: fmt.Sprintf("upload of %s stalled at offset %d", file.FileName(), offset)}
+			}
+			continue
+		}
+		stalls = 0
+
+		consumed := int(acked - offset)
+		copy(buf, buf[consumed:filled])
+		filled -= consumed
+		offset = acked
+	}
+	return nil
+}
+
+// Отправляет одну часть файла и возвращает смещение, до которого сервер подтвердил приём.
+func (c *MaxClient) uploadChunk(ctx context.Context, url, fileName string, chunk []byte, offset, total int64) (int64, error) {
+	end := offset + int64(len(chunk))
+	contentRange := fmt.Sprintf("%d-%d/%d", offset, end-1, total)
+
+	resp, err := c.doHTTPRequestWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(chunk))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+		req.Header.Set("Content-Range", contentRange)
+		return req, nil
+	})
+	if err != nil {
+		return offset, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if acked, ok := parseAckedRange(resp.Header.Get("Range")); ok && acked < end {
+		return acked, nil
+	}
+	return end, nil
+}
+
+// Разбирает подтверждённый диапазон вида "0-1023" или "bytes=0-1023"
+// и возвращает смещение первого неподтверждённого байта.
+func parseAckedRange(header string) (int64, bool) {
+	header = strings.TrimPrefix(strings.TrimSpace(header), "bytes=")
+	i := strings.IndexByte(header, '-')
+	if i < 0 {
+		return 0, false
+	}
+	last, err := strconv.ParseInt(header[i+1:], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return last + 1, true
+}
+
+// Готовит потоковое multipart‑тело с единственным полем "file" вокруг содержимого file.
+// Возвращает функцию, открывающую тело заново для каждой попытки запроса.
+func multipartFileBody(ctx context.Context, file files.BaseFile, fileName string) (open func() (io.ReadCloser, error), length int64, contentType string, err error) {
+	size, err := fileSize(ctx, file)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	var head bytes.Buffer
+	writer := multipart.NewWriter(&head)
+	if _, err := writer.CreateFormFile("file", fileName); err != nil {
+		return nil, 0, "", err
+	}
+	headLen := head.Len()
+	if err := writer.Close(); err != nil {
+		return nil, 0, "", err
+	}
+	prefix := append([]byte(nil), head.Bytes()[:headLen]...)
+	suffix := append([]byte(nil), head.Bytes()[headLen:]...)
+
+	open = func() (io.ReadCloser, error) {
+		r, err := openFile(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(prefix), r, bytes.NewReader(suffix)), r}, nil
+	}
+	return open, int64(len(prefix)) + size + int64(len(suffix)), writer.FormDataContentType(), nil
+}
+
+// Файл, чтение которого учитывает контекст, например files.File по URL.
+type contextFile interface {
+	OpenContext(ctx context.Context) (io.ReadCloser, error)
+	SizeContext(ctx context.Context) (int64, error)
+}
+
+// Файл, который запрашивается до загрузки, например files.File по URL.
+type remoteFile interface {
+	Fetch(ctx context.Context) error
+	Release()
+}
+
+// Открывает file с учётом ctx, если реализация это поддерживает.
+func openFile(ctx context.Context, file files.BaseFile) (io.ReadCloser, error) {
+	if f, ok := file.(contextFile); ok {
+		return f.OpenContext(ctx)
+	}
+	return file.Open()
+}
+
+// Возвращает размер file с учётом ctx, если реализация это поддерживает.
+func fileSize(ctx context.Context, file files.BaseFile) (int64, error) {
+	if f, ok := file.(contextFile); ok {
+		return f.SizeContext(ctx)
+	}
+	return file.Size()
+}
+
+// Регистрирует ожидание NOTIF_ATTACH для загружаемого файла или видео.
+// Регистрация делается до отправки данных, чтобы не пропустить быстрое уведомление.
+func (c *MaxClient) expectAttach(id int64) chan *Frame {
+	ch := make(chan *Frame, 1)
+	c.fileUploadWaitersMu.Lock()
+	c.fileUploadWaiters[id] = ch
+	c.fileUploadWaitersMu.Unlock()
+	return ch
+}
+
+// Снимает ожидание NOTIF_ATTACH, если уведомление так и не пришло.
+func (c *MaxClient) cancelAttach(id int64) {
+	c.fileUploadWaitersMu.Lock()
+	if ch, ok := c.fileUploadWaiters[id]; ok {
+		delete(c.fileUploadWaiters, id)
+		close(ch)
+	}
+	c.fileUploadWaitersMu.Unlock()
+}
+
+// Ждёт подтверждения обработки загруженного вложения сервером.
+func (c *MaxClient) awaitAttach(ctx context.Context, id int64, ch chan *Frame, kind string) error {
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		c.cancelAttach(id)
+		return ctx.Err()
+	case <-time.After(time.Duration(constants.DefaultTimeout * float64(time.Second))):
+		c.cancelAttach(id)
+		return fmt.Errorf("timeout waiting for %s processing", kind)
+	}
+}
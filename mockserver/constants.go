@@ -5,7 +5,9 @@ const (
 	ProtocolCommand = 0
 	StatusOK        = "ok"
 
-	ChatTypeChat = "CHAT"
+	ChatTypeChat    = "CHAT"
+	ChatTypeChannel = "CHANNEL"
+	ChatTypeDialog  = "DIALOG"
 
 	ReactionTypeEmoji = "EMOJI"
 
@@ -1,6 +1,7 @@
 package mockserver
 
 const (
+	OpcodePing                     = 1
 	OpcodeSessionInit              = 6
 	OpcodeProfile                  = 16
 	OpcodeAuthRequest              = 17
@@ -12,16 +13,27 @@ const (
 	OpcodeAuthConfirm              = 23
 	OpcodeContactInfo              = 32
 	OpcodeContactUpdate            = 34
+	OpcodeContactPresence          = 35
+	OpcodeContactList              = 36
+	OpcodeContactSearch            = 37
+	OpcodeContactMutual            = 38
+	OpcodeContactPhotos            = 39
+	OpcodeContactSort              = 40
 	OpcodeContactInfoByPhone       = 46
 	OpcodeChatInfo                 = 48
 	OpcodeChatHistory              = 49
+	OpcodeChatMark                 = 50
 	OpcodeChatUpdate               = 55
 	OpcodeChatJoin                 = 57
 	OpcodeChatMembers              = 59
+	OpcodePublicSearch             = 60
+	OpcodeChatSearch               = 68
 	OpcodeChatMembersUpdate        = 77
 	OpcodeMsgSend                  = 64
+	OpcodeMsgTyping                = 65
 	OpcodeMsgEdit                  = 67
 	OpcodeMsgDelete                = 66
+	OpcodeMsgSearch                = 73
 	OpcodeMsgReaction              = 178
 	OpcodeMsgCancelReaction        = 179
 	OpcodeMsgGetReactions          = 180
@@ -37,12 +49,23 @@ const (
 	OpcodeLinkInfo                 = 89
 	OpcodeNotifMessage             = 128
 	OpcodeNotifTyping              = 129
+	OpcodeNotifMark                = 130
 	OpcodeNotifChat                = 135
 	OpcodeNotifAttach              = 136
 	OpcodeNotifContact             = 131
+	OpcodeNotifPresence            = 132
 	OpcodeNotifMsgDelete           = 142
 	OpcodeNotifMsgReactionsChanged = 155
 
+	// 2FA opcodes
+	OpcodeAuthValidatePassword   = 107
+	OpcodeAuthValidateHint       = 108
+	OpcodeAuthVerifyEmail        = 109
+	OpcodeAuthCheckEmail         = 110
+	OpcodeAuthSet2FA             = 111
+	OpcodeAuthCreateTrack        = 112
+	OpcodeAuthLoginCheckPassword = 115
+
 	// QR login opcodes
 	OpcodeGetQR       = 288
 	OpcodeGetQRStatus = 289
@@ -159,15 +159,5 @@ func buildTypingEventFromMap(req map[string]any) map[string]any {
 	userID, _ := req["userId"].(float64)
 	typing, _ := req["typing"].(bool)
 
-	return map[string]any{
-		"ver":    ProtocolVersion,
-		"cmd":    ProtocolCommand,
-		"seq":    0,
-		"opcode": OpcodeNotifTyping,
-		"payload": map[string]any{
-			"chatId": int64(chatID),
-			"userId": int64(userID),
-			"typing": typing,
-		},
-	}
+	return NotifTypingResponse(int64(chatID), int64(userID), typing)
 }
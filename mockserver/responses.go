@@ -1,6 +1,9 @@
 package mockserver
 
-import "time"
+import (
+	"strconv"
+	"time"
+)
 
 // SessionInitResponse создаёт ответ на SESSION_INIT.
 func SessionInitResponse(seq int) map[string]any {
@@ -15,6 +18,17 @@ func SessionInitResponse(seq int) map[string]any {
 	}
 }
 
+// PingResponse создаёт ответ на PING.
+func PingResponse(seq int) map[string]any {
+	return map[string]any{
+		"ver":     ProtocolVersion,
+		"cmd":     ProtocolCommand,
+		"seq":     seq,
+		"opcode":  OpcodePing,
+		"payload": map[string]any{},
+	}
+}
+
 // AuthRequestResponse создаёт ответ на AUTH_REQUEST с временным токеном.
 func AuthRequestResponse(seq int, tempToken string) map[string]any {
 	return map[string]any{
@@ -182,6 +196,94 @@ func PinMessageResponse(seq int) map[string]any {
 	}
 }
 
+// TypingResponse создаёт ответ на MSG_TYPING.
+func TypingResponse(seq int) map[string]any {
+	return map[string]any{
+		"ver":     ProtocolVersion,
+		"cmd":     ProtocolCommand,
+		"seq":     seq,
+		"opcode":  OpcodeMsgTyping,
+		"payload": map[string]any{},
+	}
+}
+
+// ChatMarkResponse создаёт ответ на CHAT_MARK.
+func ChatMarkResponse(seq int) map[string]any {
+	return map[string]any{
+		"ver":     ProtocolVersion,
+		"cmd":     ProtocolCommand,
+		"seq":     seq,
+		"opcode":  OpcodeChatMark,
+		"payload": map[string]any{},
+	}
+}
+
+// ContactsResponse создаёт ответ со списком контактов на CONTACT_LIST,
+// CONTACT_SEARCH или CONTACT_MUTUAL (opcode задаётся явно).
+func ContactsResponse(seq int, opcode int, contacts []map[string]any) map[string]any {
+	if contacts == nil {
+		contacts = []map[string]any{}
+	}
+
+	return map[string]any{
+		"ver":    ProtocolVersion,
+		"cmd":    ProtocolCommand,
+		"seq":    seq,
+		"opcode": opcode,
+		"payload": map[string]any{
+			"contacts": contacts,
+		},
+	}
+}
+
+// ContactPhotosResponse создаёт ответ на CONTACT_PHOTOS.
+func ContactPhotosResponse(seq int, photos []map[string]any) map[string]any {
+	if photos == nil {
+		photos = []map[string]any{}
+	}
+
+	return map[string]any{
+		"ver":    ProtocolVersion,
+		"cmd":    ProtocolCommand,
+		"seq":    seq,
+		"opcode": OpcodeContactPhotos,
+		"payload": map[string]any{
+			"photos": photos,
+		},
+	}
+}
+
+// ContactSortResponse создаёт ответ на CONTACT_SORT.
+func ContactSortResponse(seq int) map[string]any {
+	return map[string]any{
+		"ver":     ProtocolVersion,
+		"cmd":     ProtocolCommand,
+		"seq":     seq,
+		"opcode":  OpcodeContactSort,
+		"payload": map[string]any{},
+	}
+}
+
+// ContactPresenceResponse создаёт ответ на CONTACT_PRESENCE.
+// presence сопоставляет ID пользователя с объектом присутствия (seen, online).
+func ContactPresenceResponse(seq int, presence map[int64]map[string]any) map[string]any {
+	byID := make(map[string]any, len(presence))
+	for id, p := range presence {
+		byID[strconv.FormatInt(id, 10)] = p
+	}
+
+	return map[string]any{
+		"ver":    ProtocolVersion,
+		"cmd":    ProtocolCommand,
+		"seq":    seq,
+		"opcode": OpcodeContactPresence,
+		"payload": map[string]any{
+			"presence": byID,
+			"time":     time.Now().UnixMilli(),
+		},
+	}
+}
+
 // AddReactionResponse создаёт ответ на MSG_REACTION.
 func AddReactionResponse(seq int, messageID string, reaction string) map[string]any {
 	return map[string]any{
@@ -271,6 +373,80 @@ func LoginByQRResponse(seq int, loginToken string) map[string]any {
 	}
 }
 
+// LoginByQRPasswordChallengeResponse создаёт ответ на LOGIN_BY_QR для аккаунта
+// с облачным паролем: вместо токена входа сервер возвращает трек проверки пароля.
+func LoginByQRPasswordChallengeResponse(seq int, trackID, hint string) map[string]any {
+	resp := AuthPasswordChallengeResponse(seq, trackID, hint, "")
+	resp["opcode"] = OpcodeLoginByQR
+	return resp
+}
+
+// AuthPasswordChallengeResponse создаёт ответ на AUTH для аккаунта с облачным паролем:
+// вместо токена входа сервер возвращает трек проверки пароля.
+func AuthPasswordChallengeResponse(seq int, trackID, hint, email string) map[string]any {
+	challenge := map[string]any{
+		"trackId": trackID,
+	}
+	if hint != "" {
+		challenge["hint"] = hint
+	}
+	if email != "" {
+		challenge["email"] = email
+	}
+
+	return map[string]any{
+		"ver":    ProtocolVersion,
+		"cmd":    ProtocolCommand,
+		"seq":    seq,
+		"opcode": OpcodeAuth,
+		"payload": map[string]any{
+			"passwordChallenge": challenge,
+		},
+	}
+}
+
+// CheckPasswordResponse создаёт ответ на AUTH_LOGIN_CHECK_PASSWORD.
+func CheckPasswordResponse(seq int, loginToken string) map[string]any {
+	return map[string]any{
+		"ver":    ProtocolVersion,
+		"cmd":    ProtocolCommand,
+		"seq":    seq,
+		"opcode": OpcodeAuthLoginCheckPassword,
+		"payload": map[string]any{
+			"tokenAttrs": map[string]any{
+				TokenTypeLogin: map[string]any{
+					"token": loginToken,
+				},
+			},
+		},
+	}
+}
+
+// CreateTrackResponse создаёт ответ на AUTH_CREATE_TRACK.
+func CreateTrackResponse(seq int, trackID string) map[string]any {
+	return map[string]any{
+		"ver":    ProtocolVersion,
+		"cmd":    ProtocolCommand,
+		"seq":    seq,
+		"opcode": OpcodeAuthCreateTrack,
+		"payload": map[string]any{
+			"trackId": trackID,
+		},
+	}
+}
+
+// TwoFactorStepResponse создаёт пустой успешный ответ на шаг трека 2FA
+// (AUTH_VALIDATE_PASSWORD, AUTH_VALIDATE_HINT, AUTH_VERIFY_EMAIL, AUTH_CHECK_EMAIL, AUTH_SET_2FA).
+func TwoFactorStepResponse(seq int, opcode int) map[string]any {
+	return map[string]any{
+		"ver":     ProtocolVersion,
+		"cmd":     ProtocolCommand,
+		"seq":     seq,
+		"opcode":  opcode,
+		"payload": map[string]any{},
+	}
+}
+
 // GetReactionsResponse создаёт ответ на MSG_GET_REACTIONS.
 func GetReactionsResponse(seq int, messagesReactions map[string]any) map[string]any {
 	if messagesReactions == nil {
@@ -698,6 +874,66 @@ func NotifReactionChangedResponse(chatID int64, messageID string, totalCount int
 	}
 }
 
+// NotifTypingResponse создаёт уведомление NOTIF_TYPING.
+func NotifTypingResponse(chatID int64, userID int64, typing bool) map[string]any {
+	return map[string]any{
+		"ver":    ProtocolVersion,
+		"cmd":    ProtocolCommand,
+		"seq":    0,
+		"opcode": OpcodeNotifTyping,
+		"payload": map[string]any{
+			"chatId": chatID,
+			"userId": userID,
+			"typing": typing,
+		},
+	}
+}
+
+// NotifMarkResponse создаёт уведомление NOTIF_MARK о прочтении чата пользователем.
+func NotifMarkResponse(chatID int64, userID int64, mark int64) map[string]any {
+	return map[string]any{
+		"ver":    ProtocolVersion,
+		"cmd":    ProtocolCommand,
+		"seq":    0,
+		"opcode": OpcodeNotifMark,
+		"payload": map[string]any{
+			"chatId": chatID,
+			"userId": userID,
+			"mark":   mark,
+		},
+	}
+}
+
+// NotifContactResponse создаёт уведомление NOTIF_CONTACT.
+func NotifContactResponse(contact map[string]any) map[string]any {
+	return map[string]any{
+		"ver":    ProtocolVersion,
+		"cmd":    ProtocolCommand,
+		"seq":    0,
+		"opcode": OpcodeNotifContact,
+		"payload": map[string]any{
+			"contact": contact,
+		},
+	}
+}
+
+// NotifPresenceResponse создаёт уведомление NOTIF_PRESENCE.
+func NotifPresenceResponse(userID int64, seen int64, online bool) map[string]any {
+	return map[string]any{
+		"ver":    ProtocolVersion,
+		"cmd":    ProtocolCommand,
+		"seq":    0,
+		"opcode": OpcodeNotifPresence,
+		"payload": map[string]any{
+			"userId": userID,
+			"presence": map[string]any{
+				"seen":   seen,
+				"online": online,
+			},
+		},
+	}
+}
+
 // ErrorResponse создаёт ответ с ошибкой.
 func ErrorResponse(seq int, opcode int, errorCode string, errorMessage string) map[string]any {
 	return map[string]any{
@@ -750,3 +986,97 @@ func ResolveLinkResponse(seq int) map[string]any {
 		},
 	}
 }
+
+// SearchMessagesResponse создаёт ответ на MSG_SEARCH.
+// Каждое сообщение оборачивается в элемент результата с chatId из поля сообщения.
+// marker <= 0 означает последнюю страницу.
+func SearchMessagesResponse(seq int, messages []map[string]any, marker int64) map[string]any {
+	result := make([]map[string]any, 0, len(messages))
+	for _, msg := range messages {
+		result = append(result, map[string]any{
+			"chatId":  msg["chatId"],
+			"message": msg,
+		})
+	}
+
+	payload := map[string]any{
+		"result": result,
+	}
+	if marker > 0 {
+		payload["marker"] = marker
+	}
+
+	return map[string]any{
+		"ver":     ProtocolVersion,
+		"cmd":     ProtocolCommand,
+		"seq":     seq,
+		"opcode":  OpcodeMsgSearch,
+		"payload": payload,
+	}
+}
+
+// SearchChatsResponse создаёт ответ на CHAT_SEARCH. marker <= 0 означает последнюю страницу.
+func SearchChatsResponse(seq int, chats []map[string]any, marker int64) map[string]any {
+	if chats == nil {
+		chats = []map[string]any{}
+	}
+
+	payload := map[string]any{
+		"chats": chats,
+	}
+	if marker > 0 {
+		payload["marker"] = marker
+	}
+
+	return map[string]any{
+		"ver":     ProtocolVersion,
+		"cmd":     ProtocolCommand,
+		"seq":     seq,
+		"opcode":  OpcodeChatSearch,
+		"payload": payload,
+	}
+}
+
+// LinkInfoResponse создаёт ответ на LINK_INFO с разрешённым объектом.
+// Непустые аргументы попадают в payload как chat, user и message соответственно.
+func LinkInfoResponse(seq int, chat, user, message map[string]any) map[string]any {
+	payload := map[string]any{}
+	if chat != nil {
+		payload["chat"] = chat
+	}
+	if user != nil {
+		payload["user"] = user
+	}
+	if message != nil {
+		payload["message"] = message
+	}
+
+	return map[string]any{
+		"ver":     ProtocolVersion,
+		"cmd":     ProtocolCommand,
+		"seq":     seq,
+		"opcode":  OpcodeLinkInfo,
+		"payload": payload,
+	}
+}
+
+// PublicSearchResponse создаёт ответ на PUBLIC_SEARCH: сначала чаты, затем пользователи.
+func PublicSearchResponse(seq int, chats, users []map[string]any) map[string]any {
+	result := make([]map[string]any, 0, len(chats)+len(users))
+	for _, chat := range chats {
+		result = append(result, map[string]any{"chat": chat})
+	}
+	for _, user := range users {
+		result = append(result, map[string]any{"user": user})
+	}
+
+	return map[string]any{
+		"ver":    ProtocolVersion,
+		"cmd":    ProtocolCommand,
+		"seq":    seq,
+		"opcode": OpcodePublicSearch,
+		"payload": map[string]any{
+			"result": result,
+		},
+	}
+}
@@ -255,6 +255,10 @@ func (s *MockServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 // DefaultHandlers устанавливает стандартные обработчики для базовых операций.
 func (s *MockServer) DefaultHandlers() {
+	s.SetHandler(OpcodePing, func(msg map[string]any) map[string]any {
+		return PingResponse(0)
+	})
+
 	s.SetHandler(6, func(msg map[string]any) map[string]any {
 		return SessionInitResponse(0)
 	})